@@ -0,0 +1,498 @@
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"strings"
+	"time"
+
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// archiveFormat 备份包格式
+type archiveFormat string
+
+const (
+	formatZip    archiveFormat = "zip"
+	formatTarZst archiveFormat = "tar.zst"
+	formatTarGz  archiveFormat = "tar.gz"
+)
+
+var archiveFormats = []archiveFormat{formatZip, formatTarZst, formatTarGz}
+
+// errStopWalk 用于提前结束遍历
+var errStopWalk = errors.New("stop walk")
+
+// parseArchiveFormat 解析格式名称
+func parseArchiveFormat(s string) (archiveFormat, error) {
+	for _, f := range archiveFormats {
+		if string(f) == s {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf(tr("不支持的备份格式: %s (可选 zip, tar.zst, tar.gz)"), s)
+}
+
+// formatFromPath 根据文件扩展名推断格式，无法识别时返回zip
+func formatFromPath(path string) archiveFormat {
+	switch {
+	case strings.HasSuffix(path, ".tar.zst"), strings.HasSuffix(path, ".tzst"):
+		return formatTarZst
+	case strings.HasSuffix(path, ".tar.gz"), strings.HasSuffix(path, ".tgz"):
+		return formatTarGz
+	default:
+		return formatZip
+	}
+}
+
+// detectArchiveFormat 根据文件头魔数识别备份包格式
+func detectArchiveFormat(r io.Reader) (archiveFormat, error) {
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return "", fmt.Errorf(tr("读取文件头失败: %w"), err)
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, []byte("PK\x03\x04")), bytes.HasPrefix(magic, []byte("PK\x05\x06")):
+		return formatZip, nil
+	case bytes.Equal(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}), bytes.Equal(magic, zstdSkippableMagic):
+		return formatTarZst, nil
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return formatTarGz, nil
+	default:
+		return "", errors.New(tr("无法识别的备份文件格式"))
+	}
+}
+
+// entryHeader 备份包条目信息
+type entryHeader struct {
+	Name    string
+	Size    int64
+	Mode    fs.FileMode
+	ModTime time.Time
+	Method  uint16 // zip压缩方法，其他格式忽略
+	Level   int    // zip条目压缩级别，0 表示默认值
+
+	// Linkname 符号链接目标，条目内容同样是链接目标，tar格式写入头部
+	Linkname string
+}
+
+// archiveWriter 备份包写入接口，同一时间只能写入一个条目
+type archiveWriter interface {
+	// Create 创建条目并返回写入器，写入器在下一次 Create 或 Close 前有效
+	Create(hdr *entryHeader) (io.Writer, error)
+	Close() error
+}
+
+// zstdSkippableMagic zstd可跳过帧的魔数，解压时忽略帧内容
+var zstdSkippableMagic = []byte{0x50, 0x2a, 0x4d, 0x18}
+
+// newArchiveWriter 按格式创建备份包写入器，level 用于tar格式的整体压缩。
+// extra 非空时写入归档开头允许附加数据的位置，读取时不影响内容，用于记录开始时间和预留分卷数量字段
+func newArchiveWriter(w io.Writer, format archiveFormat, level int, extra []byte) (archiveWriter, error) {
+	switch format {
+	case formatZip, "":
+		a := &zipArchiveWriter{zw: zip.NewWriter(w)}
+		if len(extra) > 0 {
+			// 第一个条目的扩展字段，标识为 "BT"
+			a.extra = binary.LittleEndian.AppendUint16([]byte("BT"), uint16(len(extra)))
+			a.extra = append(a.extra, extra...)
+		}
+		a.zw.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
+			return flate.NewWriter(w, deflateLevel(a.level))
+		})
+		a.zw.RegisterCompressor(zstd.ZipMethodWinZip, func(w io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(w, zstd.WithEncoderLevel(zstdLevel(a.level)), zstd.WithEncoderConcurrency(1))
+		})
+		return a, nil
+	case formatTarZst:
+		if len(extra) > 0 {
+			frame := binary.LittleEndian.AppendUint32(bytes.Clone(zstdSkippableMagic), uint32(len(extra)))
+			if _, err := w.Write(append(frame, extra...)); err != nil {
+				return nil, err
+			}
+		}
+		enc, err := zstd.NewWriter(w, zstd.WithEncoderLevel(zstdLevel(level)))
+		if err != nil {
+			return nil, fmt.Errorf(tr("创建zstd压缩器失败: %w"), err)
+		}
+		return &tarArchiveWriter{tw: tar.NewWriter(enc), comp: enc}, nil
+	case formatTarGz:
+		gw, err := gzip.NewWriterLevel(w, gzipLevel(level))
+		if err != nil {
+			return nil, fmt.Errorf(tr("创建gzip压缩器失败: %w"), err)
+		}
+		if len(extra) > 0 {
+			gw.Extra = binary.LittleEndian.AppendUint16([]byte("BT"), uint16(len(extra)))
+			gw.Extra = append(gw.Extra, extra...)
+		}
+		return &tarArchiveWriter{tw: tar.NewWriter(gw), comp: gw}, nil
+	default:
+		return nil, fmt.Errorf(tr("不支持的备份格式: %s"), format)
+	}
+}
+
+type zipArchiveWriter struct {
+	zw    *zip.Writer
+	level int    // 当前条目的压缩级别，压缩器在 CreateHeader 时读取
+	extra []byte // 写入第一个条目的扩展字段
+}
+
+func (a *zipArchiveWriter) Create(hdr *entryHeader) (io.Writer, error) {
+	a.level = hdr.Level
+	fh := &zip.FileHeader{
+		Name:   hdr.Name,
+		Method: hdr.Method,
+	}
+	if hdr.Mode.IsDir() {
+		fh.Name = strings.TrimSuffix(hdr.Name, "/") + "/"
+		fh.Method = zip.Store
+	}
+	if !hdr.ModTime.IsZero() {
+		fh.Modified = hdr.ModTime
+	}
+	if hdr.Mode != 0 {
+		fh.SetMode(hdr.Mode)
+	}
+	fh.Extra, a.extra = a.extra, nil
+	return a.zw.CreateHeader(fh)
+}
+
+func (a *zipArchiveWriter) Close() error {
+	return a.zw.Close()
+}
+
+type tarArchiveWriter struct {
+	tw   *tar.Writer
+	comp io.WriteCloser
+
+	// 当前条目剩余未写入的字节数，源文件在读取期间变短时用零补齐，保证归档结构完整
+	remaining int64
+}
+
+func (a *tarArchiveWriter) Create(hdr *entryHeader) (io.Writer, error) {
+	if err := a.pad(); err != nil {
+		return nil, err
+	}
+
+	mode := hdr.Mode
+	if mode == 0 {
+		mode = 0644
+	}
+	modTime := hdr.ModTime
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
+
+	th := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     hdr.Name,
+		Size:     hdr.Size,
+		Mode:     int64(mode.Perm()),
+		ModTime:  modTime,
+		Format:   tar.FormatPAX,
+	}
+	switch {
+	case mode.IsDir():
+		th.Typeflag = tar.TypeDir
+		th.Name = strings.TrimSuffix(hdr.Name, "/") + "/"
+		th.Size = 0
+	case mode&fs.ModeSymlink != 0:
+		th.Typeflag = tar.TypeSymlink
+		th.Linkname = hdr.Linkname
+		th.Size = 0
+	}
+	if err := a.tw.WriteHeader(th); err != nil {
+		return nil, err
+	}
+
+	a.remaining = th.Size
+	return a, nil
+}
+
+func (a *tarArchiveWriter) Write(p []byte) (int, error) {
+	n := len(p)
+	if int64(len(p)) > a.remaining {
+		p = p[:a.remaining] // 源文件在读取期间变长，截断到头部声明的大小
+	}
+	m, err := a.tw.Write(p)
+	a.remaining -= int64(m)
+	if err != nil {
+		return m, err
+	}
+	return n, nil
+}
+
+func (a *tarArchiveWriter) pad() error {
+	if a.remaining <= 0 {
+		return nil
+	}
+	_, err := io.CopyN(a.tw, zeroReader{}, a.remaining)
+	a.remaining = 0
+	return err
+}
+
+func (a *tarArchiveWriter) Close() error {
+	if err := a.pad(); err != nil {
+		return err
+	}
+	if err := a.tw.Close(); err != nil {
+		return err
+	}
+	return a.comp.Close()
+}
+
+type zeroReader struct{}
+
+func (zeroReader) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// archiveEntry 备份包中的条目
+type archiveEntry struct {
+	entryHeader
+	open func() (io.ReadCloser, error)
+	zf   *zip.File // zip 条目，用于原样复制压缩数据
+}
+
+// Open 打开条目内容，流式格式只能在遍历回调内调用
+func (e *archiveEntry) Open() (io.ReadCloser, error) {
+	return e.open()
+}
+
+// archiveReader 备份包读取接口
+type archiveReader interface {
+	// Walk 依次遍历所有条目，返回 errStopWalk 可提前结束
+	Walk(fn func(e *archiveEntry) error) error
+	// ReadFile 读取指定条目的完整内容
+	ReadFile(name string) ([]byte, error)
+	// RandomAccess 条目能否在遍历回调外并发读取
+	RandomAccess() bool
+	Format() archiveFormat
+	Close() error
+}
+
+// openArchive 打开备份包并自动识别格式，支持分卷
+func openArchive(path string) (archiveReader, error) {
+	src, err := openArchiveSource(path)
+	if err != nil {
+		return nil, err
+	}
+
+	format, err := detectArchiveFormat(io.NewSectionReader(src, 0, src.Size()))
+	if err != nil {
+		src.Close()
+		return nil, fmt.Errorf(tr("打开备份文件失败 (%s): %w"), path, err)
+	}
+
+	switch format {
+	case formatZip:
+		r, err := zip.NewReader(src, src.Size())
+		if err != nil {
+			src.Close()
+			if src.Volumes() > 1 {
+				return nil, fmt.Errorf(tr("打开备份文件失败 (%s): %w，分卷可能不完整"), path, err)
+			}
+			return nil, fmt.Errorf(tr("打开备份文件失败 (%s): %w"), path, err)
+		}
+		r.RegisterDecompressor(zip.Deflate, func(r io.Reader) io.ReadCloser {
+			return flate.NewReader(r)
+		})
+		r.RegisterDecompressor(zstd.ZipMethodWinZip, zstd.ZipDecompressor())
+		return &zipArchiveReader{r: r, src: src}, nil
+	default:
+		return &tarArchiveReader{path: path, src: src, format: format}, nil
+	}
+}
+
+type zipArchiveReader struct {
+	r   *zip.Reader
+	src *archiveSource
+}
+
+func (a *zipArchiveReader) Walk(fn func(e *archiveEntry) error) error {
+	for _, f := range a.r.File {
+		e := &archiveEntry{
+			entryHeader: entryHeader{
+				Name:    strings.TrimSuffix(f.Name, "/"),
+				Size:    int64(f.UncompressedSize64),
+				Mode:    f.Mode(),
+				ModTime: f.Modified,
+				Method:  f.Method,
+			},
+			open: f.Open,
+			zf:   f,
+		}
+		// 旧版本写入的条目没有记录 Unix 权限和修改时间，权限为 0 表示未知
+		if f.ExternalAttrs>>16 == 0 {
+			e.Mode &^= fs.ModePerm
+		}
+		if f.ModifiedDate == 0 && f.ModifiedTime == 0 {
+			e.ModTime = time.Time{}
+		}
+		if err := fn(e); err != nil {
+			if errors.Is(err, errStopWalk) {
+				return nil
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *zipArchiveReader) ReadFile(name string) ([]byte, error) {
+	for _, f := range a.r.File {
+		if f.Name == name {
+			return readEntry(f.Open)
+		}
+	}
+	return nil, fmt.Errorf(tr("备份文件中未找到 %s"), name)
+}
+
+func (a *zipArchiveReader) RandomAccess() bool    { return true }
+func (a *zipArchiveReader) Format() archiveFormat { return formatZip }
+func (a *zipArchiveReader) Close() error          { return a.src.Close() }
+
+// tarArchiveReader 每次遍历都从头顺序解压
+type tarArchiveReader struct {
+	path   string
+	src    *archiveSource
+	format archiveFormat
+}
+
+func (a *tarArchiveReader) Walk(fn func(e *archiveEntry) error) error {
+	f := io.NewSectionReader(a.src, 0, a.src.Size())
+
+	var r io.Reader
+	switch a.format {
+	case formatTarZst:
+		dec, err := zstd.NewReader(f)
+		if err != nil {
+			return fmt.Errorf(tr("创建zstd解压器失败: %w"), err)
+		}
+		defer dec.Close()
+		r = dec
+	case formatTarGz:
+		gr, err := gzip.NewReader(f)
+		if err != nil {
+			return fmt.Errorf(tr("创建gzip解压器失败: %w"), err)
+		}
+		defer gr.Close()
+		r = gr
+	}
+
+	tarReader := tar.NewReader(r)
+	for {
+		th, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			if a.src.Volumes() > 1 {
+				return fmt.Errorf(tr("读取备份文件失败 (%s): %w，分卷可能不完整"), a.path, err)
+			}
+			return fmt.Errorf(tr("读取备份文件失败 (%s): %w"), a.path, err)
+		}
+		if th.Typeflag != tar.TypeReg && th.Typeflag != tar.TypeDir && th.Typeflag != tar.TypeSymlink {
+			continue
+		}
+
+		e := &archiveEntry{
+			entryHeader: entryHeader{
+				Name:    strings.TrimSuffix(th.Name, "/"),
+				Size:    th.Size,
+				Mode:    th.FileInfo().Mode(),
+				ModTime: th.ModTime,
+			},
+			open: func() (io.ReadCloser, error) {
+				return io.NopCloser(tarReader), nil
+			},
+		}
+		if th.Typeflag == tar.TypeSymlink {
+			// 与zip一致，符号链接条目的内容为链接目标
+			linkname := th.Linkname
+			e.Size = int64(len(linkname))
+			e.Linkname = linkname
+			e.open = func() (io.ReadCloser, error) {
+				return io.NopCloser(strings.NewReader(linkname)), nil
+			}
+		}
+		if err := fn(e); err != nil {
+			if errors.Is(err, errStopWalk) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func (a *tarArchiveReader) ReadFile(name string) ([]byte, error) {
+	var data []byte
+	err := a.Walk(func(e *archiveEntry) error {
+		if e.Name != name {
+			return nil
+		}
+		var err error
+		if data, err = readEntry(e.Open); err != nil {
+			return err
+		}
+		return errStopWalk
+	})
+	if err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf(tr("备份文件中未找到 %s"), name)
+	}
+	return data, nil
+}
+
+func (a *tarArchiveReader) RandomAccess() bool    { return false }
+func (a *tarArchiveReader) Format() archiveFormat { return a.format }
+func (a *tarArchiveReader) Close() error          { return a.src.Close() }
+
+// readEntry 读取条目的完整内容
+func readEntry(open func() (io.ReadCloser, error)) ([]byte, error) {
+	rc, err := open()
+	if err != nil {
+		return nil, fmt.Errorf(tr("打开条目失败: %w"), err)
+	}
+	defer rc.Close()
+
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf(tr("读取条目失败: %w"), err)
+	}
+	return data, nil
+}
+
+// readArchiveFiles 一次遍历读取多个条目，适用于流式格式
+func readArchiveFiles(ar archiveReader, names ...string) (map[string][]byte, error) {
+	result := make(map[string][]byte, len(names))
+	err := ar.Walk(func(e *archiveEntry) error {
+		for _, name := range names {
+			if e.Name != name {
+				continue
+			}
+			data, err := readEntry(e.Open)
+			if err != nil {
+				return fmt.Errorf(tr("读取 %s 失败: %w"), name, err)
+			}
+			result[name] = data
+		}
+		if len(result) == len(names) {
+			return errStopWalk
+		}
+		return nil
+	})
+	return result, err
+}
@@ -2,28 +2,29 @@ package main
 
 import (
 	"archive/zip"
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
-	"runtime"
-	"strings"
+	"slices"
 	"sync"
-	"sync/atomic"
 	"time"
 
 	"github.com/goccy/go-yaml"
-	"github.com/klauspost/compress/flate"
 	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/cobra"
 )
 
 const (
-	backupDataDirName = "data" // 备份数据目录名称
-	backupConfigName  = "backup_config.yaml"
-	backupFileMapName = "file_map.yaml"
+	backupDataDirName  = "data" // 备份数据目录名称
+	backupConfigName   = "backup_config.yaml"
+	backupFileMapName  = "file_map.yaml"
+	backupManifestName = "manifest.json"
 )
 
 var backupCmd = &cobra.Command{
@@ -32,15 +33,80 @@ var backupCmd = &cobra.Command{
 	PreRunE: checkRoot,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		configPath, _ := cmd.Flags().GetString("config")
+		profile, _ := cmd.Flags().GetString("profile")
 
-		cfg, configBytes, err := loadConfig(configPath)
+		cfg, configBytes, err := loadConfig(configPath, profile)
 		if err != nil {
-			return fmt.Errorf("加载配置失败: %w", err)
+			return fmt.Errorf(tr("加载配置失败: %w"), err)
+		}
+
+		formatName, _ := cmd.Flags().GetString("format")
+		format, err := parseArchiveFormat(formatName)
+		if err != nil {
+			return err
 		}
 
 		outputPath, _ := cmd.Flags().GetString("output")
+		if !cmd.Flags().Changed("output") {
+			outputPath = fmt.Sprintf("backup_%s.%s", time.Now().Format("20060102150405"), format)
+		}
 		quiet, _ := cmd.Flags().GetBool("quiet")
-		if err := backup(cmd.Context(), cfg, configBytes, outputPath, quiet); err != nil {
+
+		if cmd.Flags().Changed("compression") {
+			cfg.Compression.Algorithm, _ = cmd.Flags().GetString("compression")
+		}
+		if cmd.Flags().Changed("compression-level") {
+			cfg.Compression.Level, _ = cmd.Flags().GetInt("compression-level")
+		}
+		if err := cfg.validate(); err != nil {
+			return fmt.Errorf(tr("配置无效:\n%w"), err)
+		}
+
+		volumeSize, _ := cmd.Flags().GetString("volume-size")
+		size, err := parseSize(volumeSize)
+		if err != nil {
+			return fmt.Errorf(tr("无效的分卷大小: %w"), err)
+		}
+
+		resume, _ := cmd.Flags().GetBool("resume")
+		if resume && !cmd.Flags().Changed("output") {
+			return errors.New(tr("续传需要通过 --output 指定上次中断的备份文件"))
+		}
+
+		tagValues, _ := cmd.Flags().GetStringArray("tag")
+		tags, err := parseTags(tagValues)
+		if err != nil {
+			return err
+		}
+
+		throttle, err := throttleFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		priority, err := priorityFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		if err := priority.apply(); err != nil {
+			cmd.SilenceUsage = true
+			return err
+		}
+
+		metricsPath, _ := cmd.Flags().GetString("metrics-textfile")
+		metrics := newRunMetrics(operationBackup, profile)
+
+		opts := backupOptions{Format: format, VolumeSize: size, Resume: resume, Tags: tags, Quiet: quiet, Metrics: metrics, Throttle: throttle}
+		err = backup(cmd.Context(), cfg, configBytes, outputPath, opts)
+		metrics.finish(err)
+		result.Input, result.Output = configPath, outputPath
+		result.setRun(metrics)
+		if metricsPath != "" {
+			if merr := recordMetricsTextfile(metricsPath, metrics); merr != nil {
+				slog.Error(tr("写入指标文件失败"), "path", metricsPath, "error", merr)
+			}
+		}
+		sendNotifications(cmd.Context(), cfg.Notify, newNotifyEvent(metrics, outputPath, err))
+		if err != nil {
 			cmd.SilenceUsage = true
 			return err
 		}
@@ -51,115 +117,307 @@ var backupCmd = &cobra.Command{
 
 func init() {
 	backupCmd.Flags().StringP("config", "c", "config.yaml", "备份配置文件路径")
-	backupCmd.Flags().StringP("output", "o", fmt.Sprintf("backup_%s.zip", time.Now().Format("20060102150405")), "备份输出路径")
+	backupCmd.Flags().String("profile", "", "使用配置文件中的命名配置")
+	backupCmd.Flags().StringP("output", "o", "backup_时间戳.zip", "备份输出路径")
+	backupCmd.Flags().StringP("format", "f", string(formatZip), "备份格式 (zip|tar.zst|tar.gz)")
+	backupCmd.Flags().Bool("resume", false, "从上次中断的检查点继续备份")
+	backupCmd.Flags().String("volume-size", "", "分卷大小，如 4G、700M，为空表示不分卷")
+	backupCmd.Flags().String("compression", compressionDeflate, "zip条目压缩算法 (deflate|zstd)，覆盖配置文件")
+	backupCmd.Flags().Int("compression-level", 0, "压缩级别，0 表示算法默认值，覆盖配置文件")
+	backupCmd.Flags().StringArray("tag", nil, "写入备份清单的标签，格式 key=value，可重复指定")
+	backupCmd.Flags().String("metrics-textfile", "", "备份结束后写入 Prometheus 指标的文件，供 node_exporter textfile collector 读取")
+	addThrottleFlags(backupCmd)
 
 	rootCmd.AddCommand(backupCmd)
 }
 
 type Config struct {
-	BackupPaths  []string `yaml:"backup_paths"`
-	ExcludeDirs  []string `yaml:"exclude_dirs,omitempty"`
-	ExcludeFiles []string `yaml:"exclude_files,omitempty"`
+	Include  []string          `yaml:"include,omitempty"`  // 先合并的其他配置文件，支持通配符
+	Profiles map[string]Config `yaml:"profiles,omitempty"` // 命名配置，通过 --profile 合并到基础配置
+
+	BackupPaths  []BackupPath `yaml:"backup_paths"`
+	ExcludeDirs  []string     `yaml:"exclude_dirs,omitempty"`
+	ExcludeFiles []string     `yaml:"exclude_files,omitempty"`
+
+	Rules     []string            `yaml:"rules,omitempty"`      // 有序排除规则，gitignore 语法，以 / 开头表示绝对路径
+	PathRules map[string][]string `yaml:"path_rules,omitempty"` // 针对单个备份路径的规则，相对该路径匹配
+
+	MaxFileSize    string   `yaml:"max_file_size,omitempty"`   // 跳过大于该大小的文件，如 1G
+	MinFileSize    string   `yaml:"min_file_size,omitempty"`   // 跳过小于该大小的文件
+	ModifiedWithin string   `yaml:"modified_within,omitempty"` // 只备份该时长内修改过的文件，如 7d
+	OlderThan      string   `yaml:"older_than,omitempty"`      // 只备份早于该时长修改的文件
+	FileTypes      []string `yaml:"file_types,omitempty"`      // 备份的条目类型 regular|symlink|dir，默认 regular 和 symlink
+	OneFileSystem  bool     `yaml:"one_file_system,omitempty"` // 不跨越文件系统
+
+	Compression CompressionConfig `yaml:"compression,omitempty"`
 
 	BeforeScript string `yaml:"before_script,omitempty"`
 	AfterScript  string `yaml:"after_script,omitempty"`
+
+	Snapshot []SnapshotConfig `yaml:"snapshot,omitempty"` // 备份前创建的快照，备份从快照中读取文件
+	Notify   NotifyConfig     `yaml:"notify,omitempty"`   // 备份结束后的通知
 }
 
 type FileMap map[string]string // key: 压缩包内路径, value: 原绝对路径
 
+// backupOptions 备份选项
+type backupOptions struct {
+	Format     archiveFormat     // 备份格式，为空时使用zip
+	VolumeSize int64             // 分卷大小，0 表示不分卷
+	Resume     bool              // 从上次中断的检查点继续
+	Tags       map[string]string // 写入清单的用户标签
+	Quiet      bool
+	Metrics    *runMetrics // 不为 nil 时记录本次备份的统计
+	Throttle   ioThrottle  // 并发数和读写限速
+
+	// DiscardInterrupted 中断时删除备份包和检查点日志，用于之后不会续传的备份
+	DiscardInterrupted bool
+}
+
 type fileTask struct {
-	absPath string
-	relPath string
+	absPath     string
+	srcPath     string // 实际读取的路径，使用快照时为快照中的路径，否则与 absPath 相同
+	relPath     string
+	isDir       bool // 目录条目，只记录目录本身
+	compression *CompressionConfig
+	noFollow    bool // 符号链接按链接本身备份
 }
 
-var (
-	skippedFiles atomic.Int64 // 跳过文件计数
-	skippedDirs  atomic.Int64 // 跳过文件夹计数
-)
+// backupSession 一次备份过程中worker共享的状态
+type backupSession struct {
+	aw       archiveWriter
+	mu       sync.Mutex // 保护 aw、fileMap、journal 和 manifest
+	fileMap  FileMap
+	manifest *Manifest
+	skipped  skipStats
+	journal  *journal
+	reused   map[string]bool // 续传时从检查点复用的条目，只读
+	throttle ioThrottle
+	mounts   snapshotMounts // 备份期间的快照，只读
+}
 
 // backup 执行备份操作
-func backup(ctx context.Context, cfg *Config, configBytes []byte, outputPath string, quiet bool) error {
-	var err error
-	defer func() {
-		if err != nil {
-			os.Remove(outputPath)
+func backup(ctx context.Context, cfg *Config, configBytes []byte, outputPath string, opts backupOptions) error {
+	// 创建快照，备份结束后删除
+	mounts, release, err := createSnapshots(cfg.Snapshot)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	// 续传时读取上次中断留下的检查点
+	var cp *checkpoint
+	if opts.Resume {
+		if cp, err = openCheckpoint(outputPath); err != nil {
+			return err
 		}
-	}()
+		if cp != nil && cp.ar != nil {
+			opts.Format = cp.ar.Format()
+		}
+	}
 
-	// 创建备份文件
-	zipWriter, outFile, err := createBackupFile(outputPath)
+	// 创建备份文件，头部记录清单中的开始时间
+	manifest := newManifest(opts.Format, opts.Tags)
+	aw, outFile, err := createBackupFile(outputPath, opts, cfg.Compression.Level, manifest.StartTime)
 	if err != nil {
+		if cp != nil {
+			cp.rollback()
+		}
 		return err
 	}
-	defer outFile.Close()
-	defer zipWriter.Close()
 
-	zipWriter.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
-		return flate.NewWriter(w, flate.BestCompression)
-	})
+	s := &backupSession{
+		aw:       aw,
+		fileMap:  make(FileMap),
+		manifest: manifest,
+		throttle: opts.Throttle,
+		mounts:   mounts,
+	}
+	if opts.Metrics != nil {
+		defer s.recordMetrics(opts.Metrics)
+	}
+	if s.journal, err = createJournal(outputPath); err != nil {
+		outFile.Remove()
+		if cp != nil {
+			cp.rollback()
+		}
+		return err
+	}
 
-	var (
-		mu      sync.Mutex
-		fileMap = make(FileMap)
-	)
+	interrupted := false
+	defer func() {
+		switch {
+		case interrupted:
+			// 中断时保留已关闭的备份包和检查点日志，供 --resume 使用
+			if cp != nil {
+				cp.discard()
+			}
+		case err != nil:
+			outFile.Remove()
+			s.journal.Remove()
+			if cp != nil {
+				cp.rollback()
+			}
+		default:
+			s.journal.Remove()
+			if cp != nil {
+				cp.discard()
+			}
+		}
+	}()
+	defer outFile.Close()
+	defer aw.Close()
 
 	// 写入配置文件到备份包
-	if err = writeZipFile(zipWriter, backupConfigName, configBytes, &mu); err != nil {
+	if err = writeArchiveFile(aw, backupConfigName, configBytes, &s.mu); err != nil {
 		return err
 	}
 
+	// 复用检查点中未变化的条目
+	if cp != nil {
+		s.reused, err = cp.reuse(ctx, s)
+	}
+
 	// 处理文件备份
-	if err = processBackupFiles(ctx, cfg, zipWriter, &mu, fileMap, quiet); err != nil {
+	if err == nil {
+		err = processBackupFiles(ctx, cfg, s, opts.Quiet)
+	}
+	if err != nil && ctx.Err() != nil && !opts.DiscardInterrupted {
+		if cerr := closeInterruptedBackup(s, outFile); cerr != nil {
+			err = errors.Join(err, cerr)
+			return err
+		}
+		interrupted = true
+		return fmt.Errorf(tr("备份已中断，已保存检查点，可使用 --resume 继续: %w"), err)
+	}
+	if err != nil {
 		return err
 	}
 
-	// 写入文件映射到备份包
-	if err = writeFileMapToZip(zipWriter, fileMap, &mu); err != nil {
+	// 写入文件映射和清单到备份包
+	if err = writeFileMapToArchive(aw, s.fileMap, &s.mu); err != nil {
 		return err
 	}
+	if err = writeManifestToArchive(s); err != nil {
+		return err
+	}
+
+	if err = aw.Close(); err != nil {
+		return fmt.Errorf(tr("写入备份文件失败: %w"), err)
+	}
+	if err = outFile.Close(); err != nil {
+		return fmt.Errorf(tr("写入备份文件失败: %w"), err)
+	}
 
-	fmt.Printf("\n备份完成: %s ", outputPath)
-	fmt.Printf("跳过 %d个文件 %d个文件夹\n", skippedFiles.Load(), skippedDirs.Load())
+	if opts.Metrics != nil {
+		opts.Metrics.ArchiveSize = archiveSize(outputPath, outFile)
+		if v, ok := outFile.(*volumeWriter); ok {
+			opts.Metrics.Volumes = v.Volumes()
+		}
+	}
+
+	if v, ok := outFile.(*volumeWriter); ok {
+		slog.Info(tr("已写入分卷"), "count", len(v.Volumes()), "volumes", v.Volumes())
+	}
+
+	slog.Info(tr("备份完成"), "output", outputPath,
+		"files", s.manifest.Files, "bytes", s.manifest.Bytes, "failed", s.manifest.FailedCount,
+		slog.Group("skipped",
+			"files", s.skipped.files.Load(), "dirs", s.skipped.dirs.Load(),
+			"size", s.skipped.bySize.Load(), "mtime", s.skipped.byAge.Load(),
+			"type", s.skipped.byType.Load(), "mount", s.skipped.mounts.Load()))
 	return nil
 }
 
-// createBackupFile 创建备份文件并返回zip writer
-func createBackupFile(outputPath string) (*zip.Writer, *os.File, error) {
+// recordMetrics 将清单中的计数和跳过统计写入指标
+func (s *backupSession) recordMetrics(m *runMetrics) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	m.Files, m.Bytes, m.Failed = s.manifest.Files, s.manifest.Bytes, s.manifest.FailedCount
+	m.SkippedFiles, m.SkippedDirs = s.skipped.files.Load(), s.skipped.dirs.Load()
+	m.Failures = slices.Clone(s.manifest.Failures)
+}
+
+// archiveSize 返回备份包大小，分卷时为所有分卷之和
+func archiveSize(outputPath string, outFile outputFile) int64 {
+	paths := []string{outputPath}
+	if v, ok := outFile.(*volumeWriter); ok {
+		paths = v.Volumes()
+	}
+	var size int64
+	for _, p := range paths {
+		if info, err := os.Stat(p); err == nil {
+			size += info.Size()
+		}
+	}
+	return size
+}
+
+// closeInterruptedBackup 中断后写入已完成部分的文件映射并正常关闭备份包
+func closeInterruptedBackup(s *backupSession, outFile outputFile) error {
+	if err := writeFileMapToArchive(s.aw, s.fileMap, &s.mu); err != nil {
+		return err
+	}
+	if err := s.aw.Close(); err != nil {
+		return fmt.Errorf(tr("写入备份文件失败: %w"), err)
+	}
+	if err := outFile.Close(); err != nil {
+		return fmt.Errorf(tr("写入备份文件失败: %w"), err)
+	}
+	if err := s.journal.markClosed(); err != nil {
+		return fmt.Errorf(tr("写入检查点日志失败: %w"), err)
+	}
+	return s.journal.Close()
+}
+
+// createBackupFile 创建备份文件并返回对应格式的writer，头部写入开始时间 start
+func createBackupFile(outputPath string, opts backupOptions, level int, start time.Time) (archiveWriter, outputFile, error) {
 	dir := filepath.Dir(outputPath)
 
 	if err := os.MkdirAll(dir, 0755); err != nil {
-		return nil, nil, fmt.Errorf("创建输出目录失败: %w", err)
+		return nil, nil, fmt.Errorf(tr("创建输出目录失败: %w"), err)
 	}
 
-	outFile, err := os.Create(outputPath)
+	outFile, err := createOutputFile(outputPath, opts.VolumeSize)
 	if err != nil {
-		return nil, nil, fmt.Errorf("创建输出文件失败: %w", err)
+		return nil, nil, fmt.Errorf(tr("创建输出文件失败: %w"), err)
 	}
 
-	return zip.NewWriter(outFile), outFile, nil
+	extra := startTimeField(start)
+	if opts.VolumeSize > 0 {
+		extra = append(extra, volumeCountField()...)
+	}
+	aw, err := newArchiveWriter(opts.Throttle.Write.writer(outFile), opts.Format, level, extra)
+	if err != nil {
+		outFile.Remove()
+		return nil, nil, err
+	}
+
+	return aw, outFile, nil
 }
 
 // processBackupFiles 处理文件备份过程
-func processBackupFiles(ctx context.Context, cfg *Config, zipWriter *zip.Writer, mu *sync.Mutex, fileMap FileMap, quiet bool) error {
+func processBackupFiles(ctx context.Context, cfg *Config, s *backupSession, quiet bool) error {
 	// 统计总文件数
-	totalFiles, err := countTotalFiles(cfg)
+	totalFiles, err := countTotalFiles(cfg, s.mounts)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("共 %d 个文件待备份\n", totalFiles)
+	slog.Info(tr("开始备份"), "files", totalFiles)
 
 	// 初始化进度条
-	bar := newProgressBar(int64(totalFiles), quiet, "正在备份")
+	bar := newProgressBar(int64(totalFiles), quiet, tr("正在备份"))
 
 	// 创建任务通道和worker池
 	tasks := make(chan fileTask, 1000)
 	var wg sync.WaitGroup
 
 	// 启动worker处理文件
-	startWorkers(ctx, zipWriter, mu, fileMap, bar, tasks, &wg, runtime.NumCPU())
+	startWorkers(ctx, s, bar, tasks, &wg, s.throttle.workers())
 
 	// 遍历备份路径并分发任务
-	processBackupPaths(ctx, cfg, tasks)
+	processBackupPaths(ctx, cfg, s.mounts, &s.skipped, tasks)
 
 	// 等待所有任务完成
 	close(tasks)
@@ -169,30 +427,36 @@ func processBackupFiles(ctx context.Context, cfg *Config, zipWriter *zip.Writer,
 }
 
 // startWorkers 启动worker协程处理文件任务
-func startWorkers(ctx context.Context, zipWriter *zip.Writer, mu *sync.Mutex, fileMap FileMap,
-	bar *progressbar.ProgressBar, tasks chan fileTask, wg *sync.WaitGroup, workerCount int) {
+func startWorkers(ctx context.Context, s *backupSession, bar *progressbar.ProgressBar,
+	tasks chan fileTask, wg *sync.WaitGroup, workerCount int) {
 
 	for i := 0; i < workerCount; i++ {
 		wg.Go(func() {
-			processFileTasks(ctx, zipWriter, mu, fileMap, bar, tasks)
+			processFileTasks(ctx, s, bar, tasks)
 		})
 	}
 }
 
-// processFileTasks 处理文件任务队列
-func processFileTasks(ctx context.Context, zipWriter *zip.Writer, mu *sync.Mutex, fileMap FileMap,
-	bar *progressbar.ProgressBar, tasks chan fileTask) {
-
+// processFileTasks 处理文件任务队列，取消后继续消费队列以免阻塞遍历
+func processFileTasks(ctx context.Context, s *backupSession, bar *progressbar.ProgressBar, tasks chan fileTask) {
 	for task := range tasks {
 		select {
 		case <-ctx.Done():
-			return
+			continue
 		default:
-			bar.Describe(fmt.Sprintf("备份 %s", filepath.Base(task.absPath)))
+			if s.reused[task.relPath] {
+				bar.Add(1)
+				continue
+			}
 
-			if err := processSingleFile(zipWriter, task, mu, fileMap); err != nil {
-				log.Printf("备份文件失败 (%s): %v", task.absPath, err)
-				bar.Describe(fmt.Sprintf("文件处理失败: %s", filepath.Base(task.absPath)))
+			bar.Describe(fmt.Sprintf(tr("备份 %s"), filepath.Base(task.absPath)))
+
+			if err := processSingleFile(s, task); err != nil {
+				slog.Warn(tr("备份文件失败"), "path", task.absPath, "error", err)
+				s.mu.Lock()
+				s.manifest.addFailure(task.absPath, err)
+				s.mu.Unlock()
+				bar.Describe(fmt.Sprintf(tr("文件处理失败: %s"), filepath.Base(task.absPath)))
 
 				continue
 			}
@@ -203,247 +467,355 @@ func processFileTasks(ctx context.Context, zipWriter *zip.Writer, mu *sync.Mutex
 }
 
 // processSingleFile 处理单个文件
-func processSingleFile(zipWriter *zip.Writer, task fileTask, mu *sync.Mutex, fileMap FileMap) error {
-	if err := addFileToZip(zipWriter, task.absPath, task.relPath, mu); err != nil {
+func processSingleFile(s *backupSession, task fileTask) error {
+	info, err := addFileToArchive(s.aw, task, &s.mu, s.throttle.Read)
+	if err != nil {
 		return err
 	}
 
-	mu.Lock()
-	fileMap[task.relPath] = task.absPath
-	mu.Unlock()
+	s.mu.Lock()
+	defer s.mu.Unlock()
 
-	return nil
+	s.fileMap[task.relPath] = task.absPath
+	if info.Mode().IsRegular() {
+		s.manifest.addFile(info.Size())
+	} else {
+		s.manifest.addFile(0)
+	}
+	return s.journal.record(journalRecord{
+		Name:    task.relPath,
+		Path:    task.absPath,
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	})
 }
 
 // processBackupPaths 处理所有备份路径
-func processBackupPaths(ctx context.Context, cfg *Config, tasks chan<- fileTask) {
-	for _, path := range cfg.BackupPaths {
+func processBackupPaths(ctx context.Context, cfg *Config, mounts snapshotMounts, skipped *skipStats, tasks chan<- fileTask) {
+	for _, bp := range cfg.BackupPaths {
 		select {
 		case <-ctx.Done():
 			return
 		default:
-			if err := processSinglePath(cfg, path, tasks); err != nil {
-				log.Printf("处理备份路径失败 (%s): %v", path, err)
+			if err := processSinglePath(cfg, bp, mounts, skipped, tasks); err != nil {
+				slog.Error(tr("处理备份路径失败"), "path", bp.Path, "error", err)
 			}
 		}
 	}
 }
 
 // processSinglePath 处理单个备份路径
-func processSinglePath(cfg *Config, path string, tasks chan<- fileTask) error {
-	info, err := os.Stat(path)
-	if err != nil {
-		return err
+func processSinglePath(cfg *Config, bp BackupPath, mounts snapshotMounts, skipped *skipStats, tasks chan<- fileTask) error {
+	if absRoot, err := filepath.Abs(bp.Path); err == nil {
+		for _, p := range mounts.nested(absRoot) {
+			slog.Warn(tr("快照目录位于备份路径之内，其中的文件仍从原路径读取"), "path", bp.Path, "snapshot", p)
+		}
 	}
 
-	if info.IsDir() {
-		return walkDirAndPushTasks(cfg, path, tasks)
-	} else {
-		return processSingleFileTask(cfg, path, info, tasks)
-	}
+	compression := bp.compression(cfg)
+	return walkBackupPath(cfg, bp, mounts, skipped, func(absPath, relPath string, isDir bool) error {
+		tasks <- fileTask{
+			absPath:     absPath,
+			srcPath:     mounts.source(absPath),
+			relPath:     relPath,
+			isDir:       isDir,
+			compression: compression,
+			noFollow:    !bp.followSymlinks(),
+		}
+		return nil
+	})
 }
 
-// processSingleFileTask 处理单个文件任务
-func processSingleFileTask(cfg *Config, path string, info os.FileInfo, tasks chan<- fileTask) error {
-	if shouldExcludeFile(cfg, info.Name()) {
-		skippedFiles.Add(1)
-		return nil
+// writeFileMapToArchive 将文件映射写入备份包
+func writeFileMapToArchive(aw archiveWriter, fileMap FileMap, mu *sync.Mutex) error {
+	mapBytes, err := yaml.Marshal(fileMap)
+	if err != nil {
+		return fmt.Errorf(tr("序列化文件映射失败: %w"), err)
 	}
+	return writeArchiveFile(aw, backupFileMapName, mapBytes, mu)
+}
 
-	absPath, err := filepath.Abs(path)
+// walkBackupPath 按排除规则和过滤器遍历单个备份路径，对每个需要备份的条目调用 fn。
+// relPath 为条目在备份包内的路径，跳过的条目计入 skipped，为 nil 时不统计。
+// 备份路径位于快照中时遍历快照，规则仍按原路径匹配，fn 收到的也是原路径。
+func walkBackupPath(cfg *Config, bp BackupPath, mounts snapshotMounts, skipped *skipStats, fn func(absPath, relPath string, isDir bool) error) error {
+	absRoot, err := filepath.Abs(bp.Path)
 	if err != nil {
-		return fmt.Errorf("获取绝对路径失败 (%s): %w", path, err)
+		return fmt.Errorf(tr("获取绝对路径失败 (%s): %w"), bp.Path, err)
 	}
+	srcRoot := mounts.source(absRoot)
 
-	tasks <- fileTask{
-		absPath: absPath,
-		relPath: filepath.Join(backupDataDirName, filepath.Base(path)),
+	rules, err := rootIgnoreRules(cfg, bp)
+	if err != nil {
+		return err
+	}
+	includes, err := parseIgnoreRules(absRoot, bp.Includes, true)
+	if err != nil {
+		return err
 	}
-	return nil
-}
 
-// writeFileMapToZip 将文件映射写入zip
-func writeFileMapToZip(zipWriter *zip.Writer, fileMap FileMap, mu *sync.Mutex) error {
-	mapBytes, err := yaml.Marshal(fileMap)
+	filter, err := newFileFilter(cfg, srcRoot, time.Now())
 	if err != nil {
-		return fmt.Errorf("序列化文件映射失败: %w", err)
+		return err
+	}
+	filter.followSymlinks = bp.followSymlinks()
+	prefix := bp.prefix(absRoot)
+
+	skip := func(path string, isDir bool, reason skipReason) {
+		if skipped != nil {
+			skipped.add(isDir, reason)
+			slog.Debug(tr("跳过"), "path", path, "dir", isDir, "reason", reason)
+		}
 	}
-	return writeZipFile(zipWriter, backupFileMapName, mapBytes, mu)
-}
 
-// walkDirAndPushTasks 遍历目录并将文件任务推送到通道
-func walkDirAndPushTasks(cfg *Config, dirPath string, tasks chan<- fileTask) error {
-	return filepath.WalkDir(dirPath, func(path string, d os.DirEntry, err error) error {
+	archivePath := func(path string) (string, error) {
+		// 单个文件直接放在数据目录下，指定 archive_prefix 时放在该目录下
+		if path == absRoot {
+			if bp.ArchivePrefix != "" {
+				return filepath.Join(backupDataDirName, prefix, filepath.Base(path)), nil
+			}
+			return filepath.Join(backupDataDirName, filepath.Base(path)), nil
+		}
+		rel, err := filepath.Rel(absRoot, path)
 		if err != nil {
-			return fmt.Errorf("遍历目录失败 (%s): %w", path, err)
+			return "", fmt.Errorf(tr("获取相对路径失败 (%s): %w"), path, err)
 		}
+		return filepath.Join(backupDataDirName, prefix, filepath.ToSlash(rel)), nil
+	}
+
+	// 每个目录生效的规则：父目录规则加上该目录下 .backtrackignore 中的规则
+	dirRules := make(map[string]ignoreRules)
 
-		// 排除目录
-		if d.IsDir() && shouldExcludeDir(cfg, d.Name()) {
-			skippedDirs.Add(1)
+	return filepath.WalkDir(srcRoot, func(srcPath string, d os.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf(tr("遍历目录失败 (%s): %w"), srcPath, err)
+		}
+		if d.IsDir() && srcPath != srcRoot && mounts.isRoot(srcPath) {
 			return filepath.SkipDir
 		}
+		path := filepath.Join(absRoot, srcPath[len(srcRoot):])
 
-		// 排除文件
-		if !d.IsDir() && shouldExcludeFile(cfg, d.Name()) {
-			skippedFiles.Add(1)
+		parentRules, ok := dirRules[filepath.Dir(path)]
+		if !ok {
+			parentRules = rules
+		}
+
+		// 排除目录和文件
+		if parentRules.excluded(path, d.IsDir()) {
+			skip(path, d.IsDir(), keepEntry)
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
 			return nil
 		}
 
-		// 处理文件
-		if !d.IsDir() {
-			rel, err := filepath.Rel(dirPath, path)
+		if d.IsDir() {
+			info, err := d.Info()
 			if err != nil {
-				return fmt.Errorf("获取相对路径失败 (%s): %w", path, err)
+				return fmt.Errorf(tr("获取目录信息失败 (%s): %w"), path, err)
+			}
+			if reason := filter.checkDir(info); reason != keepEntry {
+				skip(path, true, reason)
+				return filepath.SkipDir
 			}
 
-			absPath, err := filepath.Abs(path)
+			own, err := readIgnoreFile(path, srcPath)
 			if err != nil {
-				return fmt.Errorf("获取绝对路径失败 (%s): %w", path, err)
+				return fmt.Errorf(tr("读取排除规则失败: %w"), err)
 			}
+			dirRules[path] = slices.Concat(parentRules, own)
 
-			relPath := filepath.Join(backupDataDirName, filepath.Base(dirPath), filepath.ToSlash(rel))
-			tasks <- fileTask{absPath: absPath, relPath: relPath}
+			if !filter.includeDirs() {
+				return nil
+			}
+			relPath, err := archivePath(path)
+			if err != nil {
+				return err
+			}
+			return fn(path, relPath, true)
 		}
-		return nil
+
+		reason, err := filter.checkFile(srcPath, d.Type())
+		if err != nil {
+			// 例如指向不存在文件的符号链接
+			if skipped != nil {
+				slog.Warn(tr("无法访问文件，已跳过"), "path", path, "error", err)
+			}
+			skip(path, false, keepEntry)
+			return nil
+		}
+		if reason != keepEntry {
+			skip(path, false, reason)
+			return nil
+		}
+		if !bp.included(includes, absRoot, path) {
+			skip(path, false, keepEntry)
+			return nil
+		}
+
+		relPath, err := archivePath(path)
+		if err != nil {
+			return err
+		}
+		return fn(path, relPath, false)
 	})
 }
 
 // countTotalFiles 统计需要备份的文件总数
-func countTotalFiles(cfg *Config) (int, error) {
+func countTotalFiles(cfg *Config, mounts snapshotMounts) (int, error) {
 	count := 0
-	for _, path := range cfg.BackupPaths {
-		info, err := os.Stat(path)
-		if err != nil {
-			log.Printf("无法访问路径 (%s): %v", path, err)
+	for _, bp := range cfg.BackupPaths {
+		if _, err := os.Stat(bp.Path); err != nil {
+			if bp.Required {
+				return 0, fmt.Errorf(tr("必需的备份路径无法访问 (%s): %w"), bp.Path, err)
+			}
+			slog.Warn(tr("无法访问路径"), "path", bp.Path, "error", err)
 			continue
 		}
 
-		if info.IsDir() {
-			err = filepath.WalkDir(path, func(filePath string, d os.DirEntry, err error) error {
-				if err != nil {
-					return fmt.Errorf("遍历目录失败 (%s): %w", filePath, err)
-				}
-
-				// 排除目录
-				if d.IsDir() && shouldExcludeDir(cfg, d.Name()) {
-					skippedDirs.Add(1)
-					return filepath.SkipDir
-				}
-
-				// 排除文件
-				if !d.IsDir() && shouldExcludeFile(cfg, d.Name()) {
-					skippedFiles.Add(1)
-					return nil
-				}
-
-				// 统计文件
-				if !d.IsDir() {
-					count++
-				}
-				return nil
-			})
-
-			if err != nil {
-				return 0, err
-			}
-		} else {
-			// 处理单个文件
-			if !shouldExcludeFile(cfg, info.Name()) {
-				count++
-			} else {
-				skippedFiles.Add(1)
-			}
+		err := walkBackupPath(cfg, bp, mounts, nil, func(absPath, relPath string, isDir bool) error {
+			count++
+			return nil
+		})
+		if err != nil {
+			return 0, err
 		}
 	}
 	return count, nil
 }
 
-// shouldExcludeDir 检查目录是否应该被排除
-func shouldExcludeDir(cfg *Config, dirName string) bool {
-	for _, exclude := range cfg.ExcludeDirs {
-		if dirName == exclude {
-			return true
-		}
-	}
-	return false
-}
+// addFileToArchive 将文件添加到备份包
+func addFileToArchive(aw archiveWriter, task fileTask, mu *sync.Mutex, limit *rateLimiter) (os.FileInfo, error) {
+	filePath, relPath := task.srcPath, task.relPath
 
-// shouldExcludeFile 检查文件是否应该被排除（支持通配符匹配）
-func shouldExcludeFile(cfg *Config, fileName string) bool {
-	for _, pattern := range cfg.ExcludeFiles {
-		if match, _ := filepath.Match(pattern, fileName); match {
-			return true
+	if task.isDir {
+		return addDirToArchive(aw, filePath, relPath, mu)
+	}
+	if task.noFollow {
+		if info, err := os.Lstat(filePath); err == nil && info.Mode()&fs.ModeSymlink != 0 {
+			return addSymlinkToArchive(aw, filePath, relPath, info, mu)
 		}
 	}
-	return false
-}
 
-// addFileToZip 将文件添加到zip压缩包
-func addFileToZip(zipWriter *zip.Writer, filePath, relPath string, mu *sync.Mutex) error {
 	srcFile, err := os.Open(filePath)
 	if err != nil {
-		return fmt.Errorf("打开文件失败 (%s): %w", filePath, err)
+		return nil, fmt.Errorf(tr("打开文件失败 (%s): %w"), filePath, err)
 	}
 	defer srcFile.Close()
-	var method uint16
 
-	// 根据文件扩展名选择压缩方法
-	switch {
-	case !strings.Contains(relPath, "."), strings.HasSuffix(relPath, ".gz"):
-		method = zip.Store // 不压缩
-	default:
-		method = zip.Deflate // 默认压缩
+	info, err := srcFile.Stat()
+	if err != nil {
+		return nil, fmt.Errorf(tr("获取文件信息失败 (%s): %w"), filePath, err)
 	}
 
+	// 根据文件类型和采样数据选择压缩方法
+	src := bufio.NewReaderSize(limit.reader(srcFile), compressionSampleSize)
+	sample, _ := src.Peek(compressionSampleSize)
+	method := selectMethod(task.compression, relPath, sample)
+
 	mu.Lock()
 	defer mu.Unlock()
 
-	writer, err := zipWriter.CreateHeader(&zip.FileHeader{
-		Name:   relPath,
-		Method: method,
+	writer, err := aw.Create(&entryHeader{
+		Name:    relPath,
+		Size:    info.Size(),
+		Mode:    info.Mode().Perm(),
+		ModTime: info.ModTime(),
+		Method:  method,
+		Level:   task.compression.Level,
 	})
 	if err != nil {
-		return fmt.Errorf("创建zip条目失败 (%s): %w", relPath, err)
+		return nil, fmt.Errorf(tr("创建备份条目失败 (%s): %w"), relPath, err)
 	}
 
-	_, err = io.Copy(writer, srcFile)
+	n, err := io.Copy(writer, src)
 	if err != nil {
-		return fmt.Errorf("复制文件内容失败 (%s): %w", filePath, err)
+		return nil, fmt.Errorf(tr("复制文件内容失败 (%s): %w"), filePath, err)
 	}
 
-	return nil
+	// 文件在读取期间被修改：zip 条目保存读到的全部内容，
+	// tar 条目的大小已写入头部，变长的部分被截断，变短的部分用零补齐
+	changed := n != info.Size()
+	if after, err := srcFile.Stat(); err == nil && (after.Size() != info.Size() || !after.ModTime().Equal(info.ModTime())) {
+		changed = true
+	}
+	if changed {
+		slog.Warn(tr("文件在备份期间被修改，备份的内容可能不一致"), "path", filePath, "size", info.Size(), "read", n)
+	}
+
+	return info, nil
 }
 
-// writeZipFile 将数据写入zip文件（线程安全）
-func writeZipFile(zipWriter *zip.Writer, name string, data []byte, mu *sync.Mutex) error {
+// addDirToArchive 将目录条目添加到备份包，保留目录权限
+func addDirToArchive(aw archiveWriter, dirPath, relPath string, mu *sync.Mutex) (os.FileInfo, error) {
+	info, err := os.Stat(dirPath)
+	if err != nil {
+		return nil, fmt.Errorf(tr("获取目录信息失败 (%s): %w"), dirPath, err)
+	}
+
 	mu.Lock()
 	defer mu.Unlock()
 
-	w, err := zipWriter.Create(name)
-	if err != nil {
-		return fmt.Errorf("创建zip文件失败 (%s): %w", name, err)
+	if _, err := aw.Create(&entryHeader{
+		Name:    relPath,
+		Mode:    fs.ModeDir | info.Mode().Perm(),
+		ModTime: info.ModTime(),
+	}); err != nil {
+		return nil, fmt.Errorf(tr("创建备份条目失败 (%s): %w"), relPath, err)
 	}
+	return info, nil
+}
 
-	_, err = w.Write(data)
+// addSymlinkToArchive 将符号链接本身添加到备份包，条目内容为链接目标
+func addSymlinkToArchive(aw archiveWriter, linkPath, relPath string, info os.FileInfo, mu *sync.Mutex) (os.FileInfo, error) {
+	target, err := os.Readlink(linkPath)
 	if err != nil {
-		return fmt.Errorf("写入zip文件失败 (%s): %w", name, err)
+		return nil, fmt.Errorf(tr("读取符号链接失败 (%s): %w"), linkPath, err)
 	}
 
-	return nil
+	mu.Lock()
+	defer mu.Unlock()
+
+	w, err := aw.Create(&entryHeader{
+		Name:     relPath,
+		Size:     int64(len(target)),
+		Mode:     fs.ModeSymlink | info.Mode().Perm(),
+		ModTime:  info.ModTime(),
+		Method:   zip.Store,
+		Linkname: target,
+	})
+	if err != nil {
+		return nil, fmt.Errorf(tr("创建备份条目失败 (%s): %w"), relPath, err)
+	}
+	if _, err := io.WriteString(w, target); err != nil {
+		return nil, fmt.Errorf(tr("写入备份条目失败 (%s): %w"), relPath, err)
+	}
+	return info, nil
 }
 
-// loadConfig 加载并解析YAML配置文件
-func loadConfig(path string) (*Config, []byte, error) {
-	data, err := os.ReadFile(path)
+// writeArchiveFile 将数据写入备份包（线程安全）
+func writeArchiveFile(aw archiveWriter, name string, data []byte, mu *sync.Mutex) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	w, err := aw.Create(&entryHeader{
+		Name:   name,
+		Size:   int64(len(data)),
+		Method: zip.Deflate,
+	})
 	if err != nil {
-		return nil, nil, err
+		return fmt.Errorf(tr("创建备份条目失败 (%s): %w"), name, err)
 	}
 
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return nil, nil, fmt.Errorf("解析YAML配置失败 (%s): %w", path, err)
+	_, err = w.Write(data)
+	if err != nil {
+		return fmt.Errorf(tr("写入备份条目失败 (%s): %w"), name, err)
 	}
 
-	return &cfg, data, nil
+	return nil
+}
+
+// loadConfig 加载并解析YAML配置文件，profile 为空时只使用基础配置
+func loadConfig(path, profile string) (*Config, []byte, error) {
+	return resolveConfig(path, profile)
 }
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"archive/zip"
 	"path/filepath"
 	"reflect"
 	"testing"
@@ -11,6 +10,7 @@ func Test_backup(t *testing.T) {
 	type args struct {
 		configPath string
 		outputPath string
+		format     archiveFormat
 	}
 	tests := []struct {
 		name    string
@@ -22,6 +22,25 @@ func Test_backup(t *testing.T) {
 			args: args{
 				configPath: "testdata/config.yaml",
 				outputPath: "output.zip",
+				format:     formatZip,
+			},
+			wantErr: false,
+		},
+		{
+			name: "backup tar.zst",
+			args: args{
+				configPath: "testdata/config.yaml",
+				outputPath: "output.tar.zst",
+				format:     formatTarZst,
+			},
+			wantErr: false,
+		},
+		{
+			name: "backup tar.gz",
+			args: args{
+				configPath: "testdata/config.yaml",
+				outputPath: "output.tar.gz",
+				format:     formatTarGz,
 			},
 			wantErr: false,
 		},
@@ -30,17 +49,18 @@ func Test_backup(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			outputPath := filepath.Join(t.TempDir(), tt.args.outputPath)
 
-			cfg, configBytes, err := loadConfig(tt.args.configPath)
+			cfg, configBytes, err := loadConfig(tt.args.configPath, "")
 			if err != nil {
 				t.Fatalf("loadConfig() error = %v", err)
 			}
 
-			if err := backup(t.Context(), cfg, configBytes, outputPath, true); (err != nil) != tt.wantErr {
+			opts := backupOptions{Format: tt.args.format, Quiet: true}
+			if err := backup(t.Context(), cfg, configBytes, outputPath, opts); (err != nil) != tt.wantErr {
 				t.Errorf("backup() error = %v, wantErr %v", err, tt.wantErr)
 			}
-			equal, err := zipFilesAreEqual(outputPath, "testdata/output.zip")
+			equal, err := archiveFilesAreEqual(outputPath, "testdata/output.zip")
 			if err != nil {
-				t.Errorf("zipFilesAreEqual() error = %v", err)
+				t.Errorf("archiveFilesAreEqual() error = %v", err)
 			}
 			if !equal {
 				t.Errorf("backup() output zip file content mismatch: %v", err)
@@ -49,31 +69,29 @@ func Test_backup(t *testing.T) {
 	}
 }
 
-// 对比两个zip压缩包文件是否内容相同
-func zipFilesAreEqual(zip1, zip2 string) (bool, error) {
-	f1, err := zip.OpenReader(zip1)
+// 对比两个备份包中的条目是否相同
+func archiveFilesAreEqual(path1, path2 string) (bool, error) {
+	names1, err := archiveEntryNames(path1)
 	if err != nil {
 		return false, err
 	}
-	defer f1.Close()
 
-	f2, err := zip.OpenReader(zip2)
+	names2, err := archiveEntryNames(path2)
 	if err != nil {
 		return false, err
 	}
-	defer f2.Close()
 
-	if len(f1.File) != len(f2.File) {
+	if len(names1) != len(names2) {
 		return false, nil
 	}
 
-	fMap := make(map[string]struct{}, len(f1.File))
-	for i := range f1.File {
-		fMap[f1.File[i].Name] = struct{}{}
+	fMap := make(map[string]struct{}, len(names1))
+	for _, name := range names1 {
+		fMap[name] = struct{}{}
 	}
 
-	for i := range f2.File {
-		if _, exists := fMap[f2.File[i].Name]; !exists {
+	for _, name := range names2 {
+		if _, exists := fMap[name]; !exists {
 			return false, nil
 		}
 	}
@@ -81,6 +99,21 @@ func zipFilesAreEqual(zip1, zip2 string) (bool, error) {
 	return true, nil
 }
 
+func archiveEntryNames(path string) ([]string, error) {
+	ar, err := openArchive(path)
+	if err != nil {
+		return nil, err
+	}
+	defer ar.Close()
+
+	var names []string
+	err = ar.Walk(func(e *archiveEntry) error {
+		names = append(names, e.Name)
+		return nil
+	})
+	return names, err
+}
+
 func Test_loadConfig(t *testing.T) {
 	type args struct {
 		path string
@@ -97,9 +130,9 @@ func Test_loadConfig(t *testing.T) {
 				path: "testdata/config.yaml",
 			},
 			want: &Config{
-				BackupPaths: []string{
-					"./testdata/backup/data1",
-					"./testdata/backup/data3.txt",
+				BackupPaths: []BackupPath{
+					{Path: "./testdata/backup/data1"},
+					{Path: "./testdata/backup/data3.txt"},
 				},
 				ExcludeDirs:  []string{"data2"},
 				ExcludeFiles: []string{"data4.txt"},
@@ -112,7 +145,7 @@ func Test_loadConfig(t *testing.T) {
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			got, _, err := loadConfig(tt.args.path)
+			got, _, err := loadConfig(tt.args.path, "")
 			if (err != nil) != tt.wantErr {
 				t.Errorf("loadConfig() error = %v, wantErr %v", err, tt.wantErr)
 				return
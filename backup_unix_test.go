@@ -0,0 +1,59 @@
+//go:build unix
+
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"testing"
+)
+
+func Test_addFileToArchive_growing(t *testing.T) {
+	// 命名管道打开时大小为 0，之后写入的内容相当于读取期间变长的文件
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := syscall.Mkfifo(path, 0644); err != nil {
+		t.Skipf("mkfifo: %v", err)
+	}
+	content := bytes.Repeat([]byte("log line\n"), 1000)
+	go func() {
+		f, err := os.OpenFile(path, os.O_WRONLY, 0)
+		if err != nil {
+			return
+		}
+		f.Write(content)
+		f.Close()
+	}()
+
+	var buf bytes.Buffer
+	aw, err := newArchiveWriter(&buf, formatZip, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	task := fileTask{srcPath: path, relPath: "data/app.log", compression: &CompressionConfig{}}
+	if _, err := addFileToArchive(aw, task, &sync.Mutex{}, nil); err != nil {
+		t.Fatalf("addFileToArchive() error = %v", err)
+	}
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// zip 条目保存读到的全部内容，不按开始时的大小截断
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rc, err := zr.File[0].Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	got, _ := io.ReadAll(rc)
+	if !bytes.Equal(got, content) {
+		t.Errorf("archived %d bytes, want %d", len(got), len(content))
+	}
+}
@@ -0,0 +1,652 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+const previewLimit = 64 << 10 // 预览读取的最大字节数
+
+// treeNode 按原路径组织的目录树节点
+type treeNode struct {
+	name     string
+	path     string // 原绝对路径
+	entry    string // 备份包内路径，没有对应条目的中间目录为空
+	size     int64  // 文件大小，目录为子树大小之和
+	dir      bool
+	parent   *treeNode
+	children []*treeNode
+	index    map[string]*treeNode
+	expanded bool
+
+	entries int // 子树（含自身）中的条目数
+	marked  int // 子树中已标记的条目数
+}
+
+// child 返回指定名称的子节点，不存在时创建
+func (n *treeNode) child(name string) *treeNode {
+	if c, ok := n.index[name]; ok {
+		return c
+	}
+	if n.index == nil {
+		n.index = make(map[string]*treeNode)
+	}
+	c := &treeNode{name: name, path: filepath.Join(n.path, name), parent: n}
+	n.dir = true
+	n.index[name] = c
+	n.children = append(n.children, c)
+	return c
+}
+
+// finish 排序子节点（目录在前）并汇总大小和条目数
+func (n *treeNode) finish() {
+	slices.SortFunc(n.children, func(a, b *treeNode) int {
+		if a.dir != b.dir {
+			if a.dir {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.name, b.name)
+	})
+	if n.entry != "" {
+		n.entries = 1
+	}
+	for _, c := range n.children {
+		c.finish()
+		n.size += c.size
+		n.entries += c.entries
+	}
+}
+
+// walk 先序遍历子树
+func (n *treeNode) walk(fn func(*treeNode)) {
+	fn(n)
+	for _, c := range n.children {
+		c.walk(fn)
+	}
+}
+
+// buildTree 根据文件映射构建目录树，headers 提供条目大小和类型
+func buildTree(fileMap FileMap, headers map[string]entryHeader) *treeNode {
+	root := &treeNode{name: "/", path: "/", dir: true, expanded: true}
+	for _, entry := range slices.Sorted(maps.Keys(fileMap)) {
+		node := root
+		for _, part := range strings.Split(strings.Trim(filepath.ToSlash(fileMap[entry]), "/"), "/") {
+			if part != "" {
+				node = node.child(part)
+			}
+		}
+		hdr := headers[entry]
+		node.entry = entry
+		node.size = hdr.Size
+		node.dir = node.dir || hdr.Mode.IsDir()
+	}
+	root.finish()
+
+	// 展开只有一个子目录的上级目录，直接显示备份路径
+	for n := root; len(n.children) == 1 && n.children[0].dir; n = n.children[0] {
+		n.children[0].expanded = true
+	}
+	return root
+}
+
+// treeRow 列表中的一行
+type treeRow struct {
+	node  *treeNode
+	depth int
+}
+
+type browseMode int
+
+const (
+	browseList      browseMode = iota // 浏览目录树
+	browseSearch                      // 输入搜索关键字
+	browsePreview                     // 预览文件内容
+	browseMeta                        // 查看元数据文件
+	browseEdit                        // 编辑元数据文件
+	browseRootInput                   // 输入还原根目录
+	browseRestoring                   // 正在还原
+	browseDone                        // 还原结束
+)
+
+// load 打开备份包，读取元数据并重新构建目录树，已有的标记被清除
+func (m *model) load() error {
+	ar, err := openArchive(m.path)
+	if err != nil {
+		return err
+	}
+
+	cfg, fileMap, err := readBackupMetadata(ar)
+	if err != nil {
+		ar.Close()
+		return err
+	}
+
+	headers := make(map[string]entryHeader)
+	if err := ar.Walk(func(e *archiveEntry) error {
+		headers[e.Name] = e.entryHeader
+		return nil
+	}); err != nil {
+		ar.Close()
+		return err
+	}
+
+	if m.ar != nil {
+		m.ar.Close()
+	}
+	m.ar, m.cfg, m.fileMap = ar, cfg, fileMap
+	m.root = buildTree(fileMap, headers)
+	m.nodes = make(map[string]*treeNode)
+	m.marked = make(map[string]bool)
+	m.root.walk(func(n *treeNode) {
+		if n.entry != "" {
+			m.nodes[n.entry] = n
+		}
+	})
+	m.refresh()
+	return nil
+}
+
+// refresh 重新计算可见行
+func (m *model) refresh() {
+	m.rows = m.rows[:0]
+	if m.query != "" {
+		q := strings.ToLower(m.query)
+		m.root.walk(func(n *treeNode) {
+			if n != m.root && strings.Contains(strings.ToLower(n.path), q) {
+				m.rows = append(m.rows, treeRow{node: n})
+			}
+		})
+	} else {
+		var add func(n *treeNode, depth int)
+		add = func(n *treeNode, depth int) {
+			for _, c := range n.children {
+				m.rows = append(m.rows, treeRow{node: c, depth: depth})
+				if c.expanded {
+					add(c, depth+1)
+				}
+			}
+		}
+		add(m.root, 0)
+	}
+	m.cursor = max(0, min(m.cursor, len(m.rows)-1))
+	m.scroll()
+}
+
+// listHeight 列表区域的行数
+func (m *model) listHeight() int {
+	return max(1, m.height-lipgloss.Height(m.headerView())-lipgloss.Height(m.footerView()))
+}
+
+// scroll 保证光标在可见区域内
+func (m *model) scroll() {
+	h := m.listHeight()
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+h {
+		m.offset = m.cursor - h + 1
+	}
+	m.offset = max(0, min(m.offset, len(m.rows)-h))
+}
+
+// current 返回光标所在节点
+func (m *model) currentNode() *treeNode {
+	if len(m.rows) == 0 {
+		return nil
+	}
+	return m.rows[m.cursor].node
+}
+
+// toggleMark 标记或取消标记节点，目录作用于整个子树
+func (m *model) toggleMark(n *treeNode) {
+	mark := n.marked < n.entries
+	n.walk(func(c *treeNode) {
+		if c.entry == "" || m.marked[c.entry] == mark {
+			return
+		}
+		if mark {
+			m.marked[c.entry] = true
+		} else {
+			delete(m.marked, c.entry)
+		}
+		delta := 1
+		if !mark {
+			delta = -1
+		}
+		for p := c; p != nil; p = p.parent {
+			p.marked += delta
+		}
+	})
+}
+
+// selection 返回已标记条目的总大小
+func (m *model) selectionSize() int64 {
+	var size int64
+	for entry := range m.marked {
+		if n := m.nodes[entry]; !n.dir {
+			size += n.size
+		}
+	}
+	return size
+}
+
+// handleKey 按当前模式处理按键
+func (m *model) handleKey(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	key := msg.String()
+	switch m.mode {
+	case browseSearch:
+		switch key {
+		case "enter":
+			m.mode = browseList
+			m.input.Blur()
+		case "esc":
+			m.mode = browseList
+			m.input.Blur()
+			m.query = ""
+			m.refresh()
+		default:
+			var cmd tea.Cmd
+			m.input, cmd = m.input.Update(msg)
+			m.query = m.input.Value()
+			m.cursor = 0
+			m.refresh()
+			return m, cmd
+		}
+		return m, nil
+
+	case browsePreview:
+		switch key {
+		case "esc", "q", "left", "h":
+			m.mode = browseList
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.viewport, cmd = m.viewport.Update(msg)
+		return m, cmd
+
+	case browseMeta:
+		return m.updateMeta(msg)
+
+	case browseEdit:
+		return m.updateEditor(msg)
+
+	case browseRootInput:
+		switch key {
+		case "enter":
+			m.rootDir = m.input.Value()
+			m.input.Blur()
+			return m, m.startRestore()
+		case "esc":
+			m.mode = browseList
+			m.input.Blur()
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.input, cmd = m.input.Update(msg)
+		return m, cmd
+
+	case browseRestoring:
+		return m, nil
+
+	case browseDone:
+		if key == "q" || key == "esc" {
+			return m, tea.Quit
+		}
+		m.mode = browseList
+		return m, nil
+	}
+
+	m.status = ""
+	n := m.currentNode()
+	switch key {
+	case "q":
+		return m, tea.Quit
+	case "esc":
+		if m.query == "" {
+			return m, tea.Quit
+		}
+		m.query = ""
+		m.refresh()
+	case "up", "k":
+		m.cursor = max(0, m.cursor-1)
+	case "down", "j":
+		m.cursor = min(len(m.rows)-1, m.cursor+1)
+	case "pgup":
+		m.cursor = max(0, m.cursor-m.listHeight())
+	case "pgdown":
+		m.cursor = min(len(m.rows)-1, m.cursor+m.listHeight())
+	case "home", "g":
+		m.cursor = 0
+	case "end", "G":
+		m.cursor = len(m.rows) - 1
+	case "right", "l", "enter":
+		if n == nil {
+			break
+		}
+		if n.dir && len(n.children) > 0 {
+			if m.query != "" {
+				// 从搜索结果跳转到目录树中的位置
+				m.reveal(n)
+			}
+			n.expanded = key != "enter" || !n.expanded
+			m.refresh()
+		} else {
+			m.showPreview(n)
+		}
+	case "left", "h":
+		if n == nil {
+			break
+		}
+		if n.expanded && m.query == "" {
+			n.expanded = false
+			m.refresh()
+		} else if n.parent != m.root {
+			m.reveal(n.parent)
+		}
+	case " ", "space":
+		if n != nil {
+			m.toggleMark(n)
+			m.cursor = min(len(m.rows)-1, m.cursor+1)
+		}
+	case "p":
+		if n != nil && !n.dir {
+			m.showPreview(n)
+		}
+	case "c", "m":
+		name := backupConfigName
+		if key == "m" {
+			name = backupFileMapName
+		}
+		if err := m.showMetadata(name); err != nil {
+			m.status = fmt.Sprintf(tr("读取失败: %v"), err)
+		}
+	case "/":
+		m.mode = browseSearch
+		m.input.Prompt = tr("搜索: ")
+		m.input.SetValue(m.query)
+		m.input.CursorEnd()
+		return m, m.input.Focus()
+	case "r":
+		if len(m.marked) == 0 {
+			break
+		}
+		// 与 restore 命令一样，还原需要 root 权限
+		if err := checkRoot(nil, nil); err != nil {
+			m.status = err.Error()
+			break
+		}
+		m.mode = browseRootInput
+		m.input.Prompt = tr("还原到: ")
+		m.input.SetValue(m.rootDir)
+		m.input.CursorEnd()
+		return m, m.input.Focus()
+	}
+	m.scroll()
+	return m, nil
+}
+
+// reveal 退出搜索，展开上级目录并把光标移动到节点
+func (m *model) reveal(n *treeNode) {
+	m.query = ""
+	for p := n.parent; p != nil; p = p.parent {
+		p.expanded = true
+	}
+	m.refresh()
+	for i, row := range m.rows {
+		if row.node == n {
+			m.cursor = i
+			break
+		}
+	}
+	m.scroll()
+}
+
+// showPreview 读取条目开头的内容显示在预览中
+func (m *model) showPreview(n *treeNode) {
+	if n.entry == "" {
+		return
+	}
+	data, err := readEntryPrefix(m.ar, n.entry, previewLimit)
+
+	var content string
+	switch {
+	case err != nil:
+		content = fmt.Sprintf(tr("读取失败: %v"), err)
+	case bytes.IndexByte(data, 0) >= 0 || !utf8.Valid(data):
+		content = fmt.Sprintf(tr("二进制文件，大小 %s"), formatSize(n.size))
+	default:
+		content = string(data)
+		if n.size > int64(len(data)) {
+			content += fmt.Sprintf(tr("\n... (仅显示前 %s)"), formatSize(int64(len(data))))
+		}
+	}
+
+	m.viewport = viewport.New(m.width, m.listHeight())
+	m.viewport.SetContent(content)
+	m.mode = browsePreview
+}
+
+// readEntryPrefix 读取条目开头最多 limit 字节
+func readEntryPrefix(ar archiveReader, name string, limit int64) ([]byte, error) {
+	var data []byte
+	found := false
+	err := ar.Walk(func(e *archiveEntry) error {
+		if e.Name != name {
+			return nil
+		}
+		found = true
+		var err error
+		data, err = readEntry(func() (io.ReadCloser, error) {
+			rc, err := e.Open()
+			if err != nil {
+				return nil, err
+			}
+			return struct {
+				io.Reader
+				io.Closer
+			}{io.LimitReader(rc, limit), rc}, nil
+		})
+		if err != nil {
+			return err
+		}
+		return errStopWalk
+	})
+	if err == nil && !found {
+		err = fmt.Errorf(tr("备份文件中未找到 %s"), name)
+	}
+	return data, err
+}
+
+type (
+	restoreProgressMsg int
+	restoreDescribeMsg string
+	restoreDoneMsg     struct{ err error }
+)
+
+// tuiProgress 将还原进度转换为 bubbletea 消息
+type tuiProgress struct {
+	ctx    context.Context
+	events chan<- tea.Msg
+}
+
+func (p tuiProgress) Add(n int) error {
+	p.send(restoreProgressMsg(n))
+	return nil
+}
+
+func (p tuiProgress) Describe(description string) {
+	p.send(restoreDescribeMsg(description))
+}
+
+func (p tuiProgress) send(msg tea.Msg) {
+	select {
+	case p.events <- msg:
+	case <-p.ctx.Done():
+	}
+}
+
+// waitForRestore 等待下一条还原消息
+func waitForRestore(events <-chan tea.Msg) tea.Cmd {
+	return func() tea.Msg {
+		return <-events
+	}
+}
+
+// startRestore 在后台还原已标记的条目，与 restore 命令一样执行备份包中的脚本
+func (m *model) startRestore() tea.Cmd {
+	selected := maps.Clone(m.marked)
+	fileMap := make(FileMap, len(selected))
+	for entry := range selected {
+		fileMap[entry] = filepath.Join(m.rootDir, m.fileMap[entry])
+	}
+
+	ctx, cancel := context.WithCancel(m.ctx)
+	events := make(chan tea.Msg, 64)
+	m.cancel = cancel
+	m.events = events
+	m.total, m.done, m.current = len(selected), 0, ""
+	m.mode = browseRestoring
+
+	ar, cfg, opts := m.ar, m.cfg, m.opts
+	go func() {
+		err := restoreSelection(ctx, ar, cfg, fileMap, selected, tuiProgress{ctx: ctx, events: events}, opts)
+		select {
+		case events <- restoreDoneMsg{err: err}:
+		case <-ctx.Done():
+		}
+	}()
+	return waitForRestore(events)
+}
+
+// restoreSelection 还原选中的条目，opts.Script 为 true 时在前后执行备份包中的脚本
+func restoreSelection(ctx context.Context, ar archiveReader, cfg *Config, fileMap FileMap, selected map[string]bool, bar progressReporter, opts modelOptions) error {
+	if opts.Script {
+		if err := runBeforeRestoreScript(cfg); err != nil {
+			return err
+		}
+	}
+	if err := restoreFilesConcurrently(ctx, ar, fileMap, selected, bar, &restoreStats{}, opts.Throttle); err != nil {
+		return err
+	}
+	if opts.Script {
+		return runAfterRestoreScript(cfg)
+	}
+	return nil
+}
+
+func (m *model) listView() string {
+	h := m.listHeight()
+	lines := make([]string, 0, h)
+	for i := m.offset; i < len(m.rows) && len(lines) < h; i++ {
+		lines = append(lines, m.rowView(i))
+	}
+	for len(lines) < h {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n")
+}
+
+var (
+	cursorStyle = lipgloss.NewStyle().Reverse(true)
+	dirStyle    = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("12"))
+	sizeStyle   = lipgloss.NewStyle().Faint(true)
+)
+
+func (m *model) rowView(i int) string {
+	row := m.rows[i]
+	n := row.node
+
+	mark := "[ ]"
+	switch {
+	case n.entries > 0 && n.marked == n.entries:
+		mark = "[x]"
+	case n.marked > 0:
+		mark = "[-]"
+	}
+
+	name := n.name
+	if m.query != "" {
+		name = n.path
+	}
+	icon := "  "
+	if n.dir {
+		icon = "▸ "
+		if n.expanded && m.query == "" {
+			icon = "▾ "
+		}
+		name = dirStyle.Render(name + "/")
+	}
+
+	left := fmt.Sprintf("%s %s%s%s", mark, strings.Repeat("  ", row.depth), icon, name)
+	size := sizeStyle.Render(formatSize(n.size))
+	gap := max(1, m.width-lipgloss.Width(left)-lipgloss.Width(size))
+	line := left + strings.Repeat(" ", gap) + size
+	if i == m.cursor {
+		return cursorStyle.Render(line)
+	}
+	return line
+}
+
+// confirmView 输入还原根目录时显示选中的内容和还原前后要执行的脚本
+func (m *model) confirmView() string {
+	lines := []string{"", fmt.Sprintf(tr("  将还原 %d 项 %s"), len(m.marked), formatSize(m.selectionSize())), ""}
+	switch {
+	case m.cfg.BeforeScript == "" && m.cfg.AfterScript == "":
+		lines = append(lines, tr("  备份包中没有还原前后脚本"))
+	case !m.opts.Script:
+		lines = append(lines, tr("  不执行备份包中的还原前后脚本 (--script=false)"))
+	default:
+		for _, s := range []struct{ label, script string }{
+			{tr("  还原前执行:"), m.cfg.BeforeScript},
+			{tr("  还原后执行:"), m.cfg.AfterScript},
+		} {
+			if s.script == "" {
+				continue
+			}
+			lines = append(lines, s.label)
+			for _, line := range strings.Split(strings.TrimRight(s.script, "\n"), "\n") {
+				lines = append(lines, "    "+line)
+			}
+		}
+	}
+	if h := m.listHeight(); len(lines) > h {
+		lines = lines[:h]
+	}
+	for len(lines) < m.listHeight() {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n")
+}
+
+func (m *model) restoreView() string {
+	percent := 0.0
+	if m.total > 0 {
+		percent = float64(m.done) / float64(m.total)
+	}
+	lines := []string{
+		"",
+		fmt.Sprintf(tr("  还原到 %s  %d/%d"), m.rootDir, m.done, m.total),
+		"  " + m.progress.ViewAs(percent),
+		"  " + m.current,
+	}
+	if m.mode == browseDone {
+		lines = append(lines, "", "  "+m.result)
+	}
+	for len(lines) < m.listHeight() {
+		lines = append(lines, "")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func Test_buildTree(t *testing.T) {
+	fileMap := FileMap{
+		"etc/hosts":         "/etc/hosts",
+		"etc/ssh/sshd":      "/etc/ssh/sshd",
+		"home/user/.bashrc": "/home/user/.bashrc",
+	}
+	headers := map[string]entryHeader{
+		"etc/hosts":         {Size: 10},
+		"etc/ssh/sshd":      {Size: 20},
+		"home/user/.bashrc": {Size: 5},
+	}
+	root := buildTree(fileMap, headers)
+
+	if root.size != 35 || root.entries != 3 {
+		t.Errorf("root size = %d entries = %d, want 35 3", root.size, root.entries)
+	}
+	etc := root.index["etc"]
+	if etc == nil || !etc.dir || etc.size != 30 {
+		t.Fatalf("etc = %+v", etc)
+	}
+	// 目录排在文件前面
+	if etc.children[0].name != "ssh" || etc.children[1].name != "hosts" {
+		t.Errorf("etc children = %s, %s", etc.children[0].name, etc.children[1].name)
+	}
+	if got := etc.index["ssh"].index["sshd"]; got.entry != "etc/ssh/sshd" || got.path != "/etc/ssh/sshd" {
+		t.Errorf("sshd = %+v", got)
+	}
+}
+
+// pressKeys 依次向查看器发送按键
+func pressKeys(m tea.Model, keys ...string) {
+	for _, k := range keys {
+		msg := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(k)}
+		switch k {
+		case "enter":
+			msg = tea.KeyMsg{Type: tea.KeyEnter}
+		case "esc":
+			msg = tea.KeyMsg{Type: tea.KeyEsc}
+		case " ":
+			msg = tea.KeyMsg{Type: tea.KeySpace, Runes: []rune(" ")}
+		case "ctrl+s":
+			msg = tea.KeyMsg{Type: tea.KeyCtrlS}
+		}
+		m.Update(msg)
+	}
+}
+
+// runRestore 执行还原命令并处理消息直到还原结束
+func runRestore(m *model) {
+	cmd := m.startRestore()
+	for m.mode == browseRestoring {
+		m.Update(cmd())
+	}
+}
+
+func Test_model_browse(t *testing.T) {
+	cfg, configBytes, err := loadConfig("testdata/config.yaml", "")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	archivePath := filepath.Join(t.TempDir(), "backup.zip")
+	if err := backup(t.Context(), cfg, configBytes, archivePath, backupOptions{Quiet: true}); err != nil {
+		t.Fatalf("backup() error = %v", err)
+	}
+
+	rootDir := t.TempDir()
+	m, err := newModel(t.Context(), archivePath, modelOptions{RootDir: rootDir})
+	if err != nil {
+		t.Fatalf("newModel() error = %v", err)
+	}
+	defer m.ar.Close()
+	m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	if view := m.View(); !strings.Contains(view, "backup/") {
+		t.Errorf("View() = %q, want tree rows", view)
+	}
+	press := func(keys ...string) { pressKeys(m, keys...) }
+
+	// 搜索只显示匹配的节点
+	press("/", "d", "a", "t", "a", "5")
+	if len(m.rows) != 1 || m.rows[0].node.name != "data5.txt" {
+		t.Fatalf("search rows = %v", m.rows)
+	}
+	press("enter", " ")
+	if len(m.marked) != 1 {
+		t.Fatalf("marked = %v, want data5.txt", m.marked)
+	}
+
+	// data1 下的条目已全部标记，切换目录时取消整个子树的标记
+	press("esc")
+	var data1 *treeNode
+	for _, n := range m.nodes {
+		if n.name == "data5.txt" {
+			data1 = n.parent
+		}
+	}
+	m.toggleMark(data1)
+	if len(m.marked) != 0 || m.root.marked != 0 {
+		t.Errorf("marked after unmark = %v, root.marked = %d", m.marked, m.root.marked)
+	}
+	m.toggleMark(data1)
+	if data1.marked != data1.entries || m.root.marked != data1.entries {
+		t.Errorf("data1 marked = %d, root.marked = %d, want %d", data1.marked, m.root.marked, data1.entries)
+	}
+	m.toggleMark(data1)
+
+	// 只还原选中的文件
+	var target *treeNode
+	for _, n := range m.nodes {
+		if n.name == "data3.txt" {
+			target = n
+		}
+	}
+	m.toggleMark(target)
+
+	runRestore(m)
+	if m.mode != browseDone || m.result == "" {
+		t.Fatalf("restore mode = %v result = %q", m.mode, m.result)
+	}
+
+	if _, err := os.Stat(filepath.Join(rootDir, target.path)); err != nil {
+		t.Errorf("selected file not restored: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(rootDir, data1.path)); !os.IsNotExist(err) {
+		t.Errorf("unselected dir restored: %v", err)
+	}
+}
+
+func Test_model_restoreScripts(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"data/a.txt": "a",
+		"config.yaml": fmt.Sprintf("backup_paths: [%s]\nbefore_script: touch %s\nafter_script: touch %s\n",
+			filepath.Join(dir, "data"), filepath.Join(dir, "before"), filepath.Join(dir, "after")),
+	})
+	cfg, configBytes, err := loadConfig(filepath.Join(dir, "config.yaml"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	archivePath := filepath.Join(dir, "backup.zip")
+	if err := backup(t.Context(), cfg, configBytes, archivePath, backupOptions{Quiet: true}); err != nil {
+		t.Fatalf("backup() error = %v", err)
+	}
+
+	for _, script := range []bool{false, true} {
+		t.Run(fmt.Sprint(script), func(t *testing.T) {
+			os.Remove(filepath.Join(dir, "before"))
+			os.Remove(filepath.Join(dir, "after"))
+			m, err := newModel(t.Context(), archivePath, modelOptions{RootDir: t.TempDir(), Script: script})
+			if err != nil {
+				t.Fatalf("newModel() error = %v", err)
+			}
+			defer m.ar.Close()
+			m.Update(tea.WindowSizeMsg{Width: 80, Height: 24})
+			m.toggleMark(m.root)
+
+			// 输入还原根目录时显示要执行的脚本
+			m.mode = browseRootInput
+			if view := m.View(); strings.Contains(view, "touch") != script {
+				t.Errorf("confirm view shows scripts = %v, want %v:\n%s", !script, script, view)
+			}
+
+			runRestore(m)
+			if m.mode != browseDone || !strings.HasPrefix(m.result, "已还原") {
+				t.Fatalf("restore result = %q", m.result)
+			}
+			for _, name := range []string{"before", "after"} {
+				if _, err := os.Stat(filepath.Join(dir, name)); (err == nil) != script {
+					t.Errorf("%s script ran = %v, want %v", name, err == nil, script)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+// journalRecord 检查点日志中已写入备份包的条目
+type journalRecord struct {
+	Name    string    `json:"name"`              // 备份包内路径
+	Path    string    `json:"path,omitempty"`    // 源文件绝对路径
+	Size    int64     `json:"size,omitempty"`    // 写入时的源文件大小
+	ModTime time.Time `json:"mod_time,omitzero"` // 写入时的源文件修改时间
+	Closed  bool      `json:"closed,omitempty"`  // 中断后备份包已正常关闭
+}
+
+// journal 检查点日志，每写入一个条目追加一行
+type journal struct {
+	f   *os.File
+	enc *json.Encoder
+}
+
+// journalPath 返回备份输出对应的检查点日志路径
+func journalPath(outputPath string) string {
+	return outputPath + ".journal"
+}
+
+// createJournal 创建检查点日志
+func createJournal(outputPath string) (*journal, error) {
+	f, err := os.Create(journalPath(outputPath))
+	if err != nil {
+		return nil, fmt.Errorf(tr("创建检查点日志失败: %w"), err)
+	}
+	return &journal{f: f, enc: json.NewEncoder(f)}, nil
+}
+
+// record 追加一条已完成的条目，调用方负责加锁
+func (j *journal) record(r journalRecord) error {
+	return j.enc.Encode(r)
+}
+
+func (j *journal) Close() error {
+	return j.f.Close()
+}
+
+// markClosed 记录备份包已在中断后正常关闭，只有这样的检查点才能续传
+func (j *journal) markClosed() error {
+	return j.enc.Encode(journalRecord{Closed: true})
+}
+
+// Remove 备份完成后删除检查点日志
+func (j *journal) Remove() error {
+	j.f.Close()
+	return os.Remove(j.f.Name())
+}
+
+// readJournal 读取检查点日志，忽略写了一半的最后一行，closed 表示备份包已正常关闭
+func readJournal(path string) (records map[string]journalRecord, closed bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, false, err
+	}
+	defer f.Close()
+
+	records = make(map[string]journalRecord)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var r journalRecord
+		if err := json.Unmarshal(scanner.Bytes(), &r); err != nil {
+			break
+		}
+		if r.Closed {
+			closed = true
+			continue
+		}
+		records[r.Name] = r
+	}
+	return records, closed, scanner.Err()
+}
+
+// checkpoint 上次中断的备份，已改名为 outputPath.prev 以便写入新的备份包
+type checkpoint struct {
+	outputPath string
+	prevPath   string
+	ar         archiveReader
+	records    map[string]journalRecord
+}
+
+// openCheckpoint 打开上次中断留下的备份包和检查点日志，没有检查点时返回 nil
+func openCheckpoint(outputPath string) (*checkpoint, error) {
+	records, closed, err := readJournal(journalPath(outputPath))
+	if errors.Is(err, os.ErrNotExist) {
+		slog.Info(tr("未找到检查点，重新开始备份"), "output", outputPath)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf(tr("读取检查点日志失败: %w"), err)
+	}
+	if !closed {
+		// 进程被强制终止时备份包没有正常关闭，无法安全复用
+		slog.Warn(tr("检查点备份包未正常关闭，重新开始备份"), "output", outputPath)
+		removeArchive(outputPath)
+		os.Remove(journalPath(outputPath))
+		return nil, nil
+	}
+
+	cp := &checkpoint{
+		outputPath: outputPath,
+		prevPath:   outputPath + ".prev",
+		records:    records,
+	}
+	if err := renameArchive(outputPath, cp.prevPath); err != nil {
+		slog.Warn(tr("检查点备份包不可用，重新开始备份"), "output", outputPath, "error", err)
+		return nil, nil
+	}
+	if err := os.Rename(journalPath(outputPath), journalPath(cp.prevPath)); err != nil {
+		renameArchive(cp.prevPath, outputPath)
+		return nil, fmt.Errorf(tr("移动检查点日志失败: %w"), err)
+	}
+
+	cp.ar, err = openArchive(cp.prevPath)
+	if err != nil {
+		slog.Warn(tr("检查点备份包已损坏，重新开始备份"), "output", outputPath, "error", err)
+		cp.ar = nil
+	}
+	return cp, nil
+}
+
+// reuse 将源文件未变化的条目复制到新备份包，返回已复制的条目
+func (cp *checkpoint) reuse(ctx context.Context, s *backupSession) (map[string]bool, error) {
+	reused := make(map[string]bool)
+	if cp.ar == nil {
+		return reused, nil
+	}
+
+	err := cp.ar.Walk(func(e *archiveEntry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		r, ok := cp.records[e.Name]
+		if !ok {
+			return nil
+		}
+		info, err := os.Stat(s.mounts.source(r.Path))
+		if err != nil || info.Size() != r.Size || !info.ModTime().Equal(r.ModTime) {
+			return nil // 源文件已变化或不存在，重新读取
+		}
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if err := copyArchiveEntry(e, s.aw); err != nil {
+			return fmt.Errorf(tr("复用检查点条目失败 (%s): %w"), e.Name, err)
+		}
+		s.fileMap[e.Name] = r.Path
+		s.manifest.addFile(r.Size)
+		reused[e.Name] = true
+		return s.journal.record(r)
+	})
+	if err != nil {
+		return reused, err
+	}
+
+	slog.Info(tr("从检查点复用条目"), "count", len(reused))
+	return reused, nil
+}
+
+// discard 续传结束后删除旧备份包
+func (cp *checkpoint) discard() {
+	if cp.ar != nil {
+		cp.ar.Close()
+	}
+	removeArchive(cp.prevPath)
+	os.Remove(journalPath(cp.prevPath))
+}
+
+// rollback 续传失败时恢复旧备份包和检查点日志
+func (cp *checkpoint) rollback() {
+	if cp.ar != nil {
+		cp.ar.Close()
+	}
+	renameArchive(cp.prevPath, cp.outputPath)
+	os.Rename(journalPath(cp.prevPath), journalPath(cp.outputPath))
+}
+
+// renameArchive 重命名备份包，包括全部分卷
+func renameArchive(oldPath, newPath string) error {
+	paths, err := findVolumes(oldPath)
+	if err != nil {
+		return err
+	}
+	if len(paths) == 1 && paths[0] == oldPath {
+		return os.Rename(oldPath, newPath)
+	}
+
+	for i, p := range paths {
+		if err := os.Rename(p, volumePath(newPath, i+1)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeArchive 删除备份包，包括全部分卷
+func removeArchive(path string) error {
+	paths, err := findVolumes(path)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, p := range paths {
+		errs = append(errs, os.Remove(p))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func Test_backupResume(t *testing.T) {
+	data3, _ := filepath.Abs("testdata/backup/data3.txt")
+	info, err := os.Stat(data3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		modTime time.Time // 检查点中记录的修改时间
+		want    string    // 续传后 data3.txt 的内容
+	}{
+		{name: "unchanged source is reused", modTime: info.ModTime(), want: "stale"},
+		{name: "changed source is re-read", modTime: info.ModTime().Add(-time.Hour), want: "test3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			outputPath := filepath.Join(t.TempDir(), "output.zip")
+			writeTestCheckpoint(t, outputPath, journalRecord{
+				Name:    "data/data3.txt",
+				Path:    data3,
+				Size:    info.Size(),
+				ModTime: tt.modTime,
+			})
+
+			cfg, configBytes, err := loadConfig("testdata/config.yaml", "")
+			if err != nil {
+				t.Fatalf("loadConfig() error = %v", err)
+			}
+			opts := backupOptions{Format: formatZip, Resume: true, Quiet: true}
+			if err := backup(t.Context(), cfg, configBytes, outputPath, opts); err != nil {
+				t.Fatalf("backup() error = %v", err)
+			}
+
+			got, err := readFile(outputPath, "data/data3.txt")
+			if err != nil {
+				t.Fatalf("readFile() error = %v", err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("data3.txt = %q, want %q", got, tt.want)
+			}
+			if equal, _ := archiveFilesAreEqual(outputPath, "testdata/output.zip"); !equal {
+				t.Errorf("resumed backup entries mismatch")
+			}
+			for _, leftover := range []string{journalPath(outputPath), outputPath + ".prev"} {
+				if _, err := os.Stat(leftover); !os.IsNotExist(err) {
+					t.Errorf("%s should be removed after resume", leftover)
+				}
+			}
+		})
+	}
+}
+
+func Test_backupInterrupted(t *testing.T) {
+	cfg, configBytes, err := loadConfig("testdata/config.yaml", "")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	outputPath := filepath.Join(t.TempDir(), "output.tar.zst")
+	opts := backupOptions{Format: formatTarZst, Quiet: true}
+	if err := backup(ctx, cfg, configBytes, outputPath, opts); err == nil {
+		t.Fatalf("backup() expected interruption error")
+	}
+
+	_, closed, err := readJournal(journalPath(outputPath))
+	if err != nil || !closed {
+		t.Fatalf("readJournal() closed = %v, error = %v", closed, err)
+	}
+	if _, err := readFile(outputPath, backupFileMapName); err != nil {
+		t.Fatalf("interrupted backup should be readable: %v", err)
+	}
+
+	opts.Resume = true
+	if err := backup(t.Context(), cfg, configBytes, outputPath, opts); err != nil {
+		t.Fatalf("backup() resume error = %v", err)
+	}
+	if equal, _ := archiveFilesAreEqual(outputPath, "testdata/output.zip"); !equal {
+		t.Errorf("resumed backup entries mismatch")
+	}
+}
+
+// writeTestCheckpoint 构造一个已正常关闭的中断备份及其检查点日志
+func writeTestCheckpoint(t *testing.T, outputPath string, r journalRecord) {
+	t.Helper()
+
+	f, err := os.Create(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	aw, err := newArchiveWriter(f, formatZip, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := aw.Create(&entryHeader{Name: r.Name, Size: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.Write([]byte("stale"))
+	if err := aw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	jf, err := os.Create(journalPath(outputPath))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer jf.Close()
+
+	enc := json.NewEncoder(jf)
+	enc.Encode(r)
+	enc.Encode(journalRecord{Closed: true})
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/klauspost/compress"
+	"github.com/klauspost/compress/flate"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	compressionDeflate = "deflate"
+	compressionZstd    = "zstd"
+
+	compressionSampleSize = 64 << 10 // 熵采样读取的字节数
+	minCompressibility    = 0.1      // 低于该估计值视为不可压缩
+)
+
+// defaultIncompressible 默认不压缩的文件类型
+var defaultIncompressible = []string{
+	".jpg", ".jpeg", ".png", ".gif", ".webp", ".heic",
+	".mp3", ".aac", ".ogg", ".flac", ".mp4", ".mkv", ".avi", ".mov", ".webm",
+	".zip", ".gz", ".tgz", ".bz2", ".xz", ".zst", ".7z", ".rar", ".lz4",
+	".jar", ".deb", ".rpm", ".apk", ".woff", ".woff2",
+	"image/*", "video/*", "audio/*",
+}
+
+// CompressionConfig 压缩配置
+type CompressionConfig struct {
+	Algorithm       string   `yaml:"algorithm,omitempty"`        // 压缩算法 deflate|zstd，默认 deflate
+	Level           int      `yaml:"level,omitempty"`            // 压缩级别，0 表示算法默认值
+	Incompressible  []string `yaml:"incompressible,omitempty"`   // 追加的不压缩类型，扩展名(.iso)或MIME类型(image/*)
+	EntropySampling bool     `yaml:"entropy_sampling,omitempty"` // 采样文件开头估计可压缩性
+}
+
+// validate 检查压缩配置
+func (c *CompressionConfig) validate() error {
+	switch c.Algorithm {
+	case "", compressionDeflate:
+		if c.Level < 0 || c.Level > 9 {
+			return fmt.Errorf(tr("deflate 压缩级别必须在 1-9 之间，0 表示默认值: %d"), c.Level)
+		}
+	case compressionZstd:
+		if c.Level < 0 || c.Level > 22 {
+			return fmt.Errorf(tr("zstd 压缩级别必须在 1-22 之间，0 表示默认值: %d"), c.Level)
+		}
+	default:
+		return fmt.Errorf(tr("不支持的压缩算法: %s (可选 deflate, zstd)"), c.Algorithm)
+	}
+	return nil
+}
+
+// method 返回配置算法对应的zip压缩方法
+func (c *CompressionConfig) method() uint16 {
+	if c.Algorithm == compressionZstd {
+		return zstd.ZipMethodWinZip
+	}
+	return zip.Deflate
+}
+
+// selectMethod 根据文件类型和采样数据选择zip压缩方法
+func selectMethod(c *CompressionConfig, name string, sample []byte) uint16 {
+	if isIncompressible(c, name, sample) {
+		return zip.Store
+	}
+	if c.EntropySampling && compress.Estimate(sample) < minCompressibility {
+		return zip.Store
+	}
+	return c.method()
+}
+
+// isIncompressible 检查文件扩展名或MIME类型是否在不压缩列表中
+func isIncompressible(c *CompressionConfig, name string, sample []byte) bool {
+	ext := strings.ToLower(filepath.Ext(name))
+
+	var mimeType string
+	if ext != "" {
+		mimeType = mime.TypeByExtension(ext)
+	}
+	if mimeType == "" && len(sample) > 0 {
+		mimeType = http.DetectContentType(sample)
+	}
+	mimeType, _, _ = strings.Cut(mimeType, ";")
+
+	for _, t := range slices.Concat(defaultIncompressible, c.Incompressible) {
+		t = strings.ToLower(t)
+		switch {
+		case strings.Contains(t, "/"):
+			if match, _ := path.Match(t, mimeType); match {
+				return true
+			}
+		case ext != "" && t == ext:
+			return true
+		}
+	}
+	return false
+}
+
+// deflateLevel 返回deflate压缩级别，默认最佳压缩
+func deflateLevel(level int) int {
+	if level == 0 {
+		return flate.BestCompression
+	}
+	return level
+}
+
+// gzipLevel 返回gzip压缩级别
+func gzipLevel(level int) int {
+	if level == 0 {
+		return gzip.DefaultCompression
+	}
+	return level
+}
+
+// zstdLevel 将zstd命令行级别(1-22)映射到编码器级别
+func zstdLevel(level int) zstd.EncoderLevel {
+	if level == 0 {
+		return zstd.SpeedDefault
+	}
+	return zstd.EncoderLevelFromZstd(level)
+}
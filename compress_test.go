@@ -0,0 +1,96 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+func Test_selectMethod(t *testing.T) {
+	text := bytes.Repeat([]byte("backtrack compressible text\n"), 100)
+	random := make([]byte, 4096)
+	rand.Read(random)
+
+	type args struct {
+		cfg    CompressionConfig
+		name   string
+		sample []byte
+	}
+	tests := []struct {
+		name string
+		args args
+		want uint16
+	}{
+		{
+			name: "extensionless text",
+			args: args{name: "data/etc/hosts", sample: text},
+			want: zip.Deflate,
+		},
+		{
+			name: "jpeg by extension",
+			args: args{name: "data/photo.JPG", sample: text},
+			want: zip.Store,
+		},
+		{
+			name: "zstd archive",
+			args: args{name: "data/logs.tar.zst", sample: text},
+			want: zip.Store,
+		},
+		{
+			name: "zstd algorithm",
+			args: args{cfg: CompressionConfig{Algorithm: compressionZstd}, name: "data/app.log", sample: text},
+			want: zstd.ZipMethodWinZip,
+		},
+		{
+			name: "custom extension",
+			args: args{cfg: CompressionConfig{Incompressible: []string{".iso"}}, name: "data/disk.iso", sample: text},
+			want: zip.Store,
+		},
+		{
+			name: "mime sniffed from content",
+			args: args{cfg: CompressionConfig{Incompressible: []string{"application/pdf"}}, name: "data/report", sample: []byte("%PDF-1.7\n")},
+			want: zip.Store,
+		},
+		{
+			name: "random data with entropy sampling",
+			args: args{cfg: CompressionConfig{EntropySampling: true}, name: "data/blob", sample: random},
+			want: zip.Store,
+		},
+		{
+			name: "random data without entropy sampling",
+			args: args{name: "data/blob", sample: random},
+			want: zip.Deflate,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := selectMethod(&tt.args.cfg, tt.args.name, tt.args.sample); got != tt.want {
+				t.Errorf("selectMethod() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompressionConfig_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     CompressionConfig
+		wantErr bool
+	}{
+		{name: "default", cfg: CompressionConfig{}, wantErr: false},
+		{name: "deflate level", cfg: CompressionConfig{Algorithm: compressionDeflate, Level: 6}, wantErr: false},
+		{name: "deflate level too high", cfg: CompressionConfig{Algorithm: compressionDeflate, Level: 12}, wantErr: true},
+		{name: "zstd level", cfg: CompressionConfig{Algorithm: compressionZstd, Level: 19}, wantErr: false},
+		{name: "unknown algorithm", cfg: CompressionConfig{Algorithm: "lzma"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -2,45 +2,75 @@ package main
 
 import (
 	"archive/zip"
+	"bufio"
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log/slog"
 	"os"
 	"path/filepath"
-	"slices"
 	"strings"
+	"time"
 
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/textarea"
+	"github.com/charmbracelet/bubbles/textinput"
 	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/goccy/go-yaml"
-	"github.com/klauspost/compress/flate"
 	"github.com/spf13/cobra"
 )
 
 var configCmd = &cobra.Command{
 	Use:   "config",
-	Short: "处理配置",
+	Short: "浏览备份包，查看和编辑配置，选择性还原",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		backupConfigPath, _ := cmd.Flags().GetString("backup-config")
 		viewConfig, _ := cmd.Flags().GetString("view-config")
-		content, err := readFile(backupConfigPath, viewConfig)
+		rootDir, _ := cmd.Flags().GetString("root-dir")
+		script, _ := cmd.Flags().GetBool("script")
+		if backupConfigPath == "" {
+			return errors.New(tr("必须提供备份文件路径"))
+		}
+
+		throttle, err := throttleFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		priority, err := priorityFromFlags(cmd)
 		if err != nil {
 			return err
 		}
+		cmd.SilenceUsage = true
+		if err := priority.apply(); err != nil {
+			return err
+		}
 
-		p := tea.NewProgram(
-			model{title: viewConfig, content: string(content)},
-			tea.WithAltScreen(),
-			tea.WithMouseCellMotion(),
-		)
+		m, err := newModel(cmd.Context(), backupConfigPath, modelOptions{RootDir: rootDir, Script: script, Throttle: throttle})
+		if err != nil {
+			return err
+		}
+		defer func() { m.ar.Close() }()
+		// 指定 --view-config 时直接查看该文件，否则从目录树开始
+		if cmd.Flags().Changed("view-config") {
+			if err := m.showMetadata(viewConfig); err != nil {
+				return err
+			}
+		}
 
-		if _, err := p.Run(); err != nil {
-			cmd.SilenceUsage = true
+		// TUI 运行期间日志写入缓冲区，退出后再输出，避免打乱界面
+		restoreLogs := bufferTerminalLogs()
+		final, err := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion(), tea.WithContext(cmd.Context())).Run()
+		restoreLogs()
+		if err != nil {
 			return err
 		}
 
+		if fm, ok := final.(*model); ok && fm.result != "" {
+			slog.Info(fm.result)
+		}
 		return nil
 	},
 }
@@ -57,6 +87,7 @@ var configExportCmd = &cobra.Command{
 			outputPath = filepath.Base(exportConfig)
 		}
 
+		result.Input, result.Output = backupConfigPath, outputPath
 		return exportConfigFromBackup(backupConfigPath, exportConfig, outputPath)
 	},
 }
@@ -70,145 +101,338 @@ var configImportCmd = &cobra.Command{
 		importConfig, _ := cmd.Flags().GetString("config")
 		configPath, _ := cmd.Flags().GetString("import")
 		force, _ := cmd.Flags().GetBool("force")
+		yes, _ := cmd.Flags().GetBool("yes")
+		compact, _ := cmd.Flags().GetBool("compact")
 		quiet, _ := cmd.Flags().GetBool("quiet")
 
-		return importConfigToBackup(cmd.Context(), backupConfigPath, importConfig, configPath, force, quiet)
+		opts := importOptions{Force: force, Yes: yes, Compact: compact, Quiet: quiet, In: cmd.InOrStdin(), Out: cmd.OutOrStdout()}
+		if outputJSON(cmd) {
+			opts.Out = cmd.ErrOrStderr()
+		}
+		result.Input, result.Output = configPath, backupConfigPath
+		changes, err := importConfigToBackup(cmd.Context(), backupConfigPath, importConfig, configPath, opts)
+		result.Changes = changes
+		if err != nil {
+			cmd.SilenceUsage = true
+			return err
+		}
+		return nil
+	},
+}
+
+// configResolveCmd 显示展开 include、profile、环境变量和通配符后的配置
+var configResolveCmd = &cobra.Command{
+	Use:   "resolve",
+	Short: "显示解析后的完整配置",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configPath, _ := cmd.Flags().GetString("config")
+		profile, _ := cmd.Flags().GetString("profile")
+
+		cfg, _, err := loadConfig(configPath, profile)
+		if err != nil {
+			cmd.SilenceUsage = true
+			return fmt.Errorf(tr("加载配置失败: %w"), err)
+		}
+
+		data, err := yaml.Marshal(cfg)
+		if err != nil {
+			return fmt.Errorf(tr("序列化配置失败: %w"), err)
+		}
+		result.Input = configPath
+		if outputJSON(cmd) {
+			// 转换为 JSON 保留 YAML 的字段名
+			result.Config, err = yaml.YAMLToJSON(data)
+			if err != nil {
+				return fmt.Errorf(tr("序列化配置失败: %w"), err)
+			}
+			return nil
+		}
+		_, err = cmd.OutOrStdout().Write(data)
+		return err
 	},
 }
 
 func init() {
 	configCmd.Flags().StringP("view-config", "v", backupConfigName, fmt.Sprintf("要查看的配置文件名称(%s, %s)", backupConfigName, backupFileMapName))
 	configCmd.PersistentFlags().StringP("backup-config", "b", "", "备份文件路径")
+	configCmd.Flags().StringP("root-dir", "r", "/", "默认还原根目录")
+	configCmd.Flags().BoolP("script", "s", true, "还原时执行备份包中的还原前后脚本")
+	addThrottleFlags(configCmd)
 
 	configExportCmd.Flags().StringP("config", "c", backupConfigName, fmt.Sprintf("要导出的配置文件名称(%s, %s)", backupConfigName, backupFileMapName))
 	configExportCmd.Flags().StringP("output", "o", "", "导出的配置文件")
 
 	configImportCmd.Flags().StringP("config", "c", backupConfigName, fmt.Sprintf("要替换的配置文件名称(%s, %s)", backupConfigName, backupFileMapName))
 	configImportCmd.Flags().StringP("import", "i", "", "要导入的配置文件")
-	configImportCmd.Flags().BoolP("force", "f", false, "强制替换")
+	configImportCmd.Flags().BoolP("force", "f", false, "强制替换，跳过校验")
+	configImportCmd.Flags().BoolP("yes", "y", false, "不询问确认")
+	configImportCmd.Flags().Bool("compact", false, "重写整个zip备份包，清除被替换条目的旧数据")
+
+	configResolveCmd.Flags().StringP("config", "c", "config.yaml", "备份配置文件路径")
+	configResolveCmd.Flags().String("profile", "", "使用配置文件中的命名配置")
 
 	configCmd.AddCommand(configExportCmd)
 	configCmd.AddCommand(configImportCmd)
+	configCmd.AddCommand(configResolveCmd)
 	rootCmd.AddCommand(configCmd)
 }
 
 // exportConfigFromBackup 从备份包中导出配置
 func exportConfigFromBackup(zipPath, configName, outputPath string) error {
-	configData, err := readFile(zipPath, configName)
+	configData, err := readMetadataFile(zipPath, configName)
 	if err != nil {
 		return err
 	}
 
 	// 写入输出文件
 	if err := os.WriteFile(outputPath, configData, 0644); err != nil {
-		return fmt.Errorf("写入配置文件失败 (%s): %w", outputPath, err)
+		return fmt.Errorf(tr("写入配置文件失败 (%s): %w"), outputPath, err)
 	}
 
-	fmt.Printf("配置已成功导出到: %s\n", outputPath)
+	slog.Info(tr("配置已导出"), "output", outputPath)
 	return nil
 }
 
-// importConfigToBackup 导入配置到备份包
-func importConfigToBackup(ctx context.Context, zipPath, configName, configPath string, force, quiet bool) error {
+// importOptions 导入配置选项
+type importOptions struct {
+	Force   bool // 跳过校验
+	Yes     bool // 不询问确认
+	Compact bool // 重写整个 zip 备份包，清除被替换条目的旧数据
+	Quiet   bool
+	In      io.Reader // 读取确认输入
+	Out     io.Writer // 输出变化和确认提示
+}
+
+// importConfigToBackup 导入配置到备份包，校验后显示变化并确认，返回导入的变化
+func importConfigToBackup(ctx context.Context, zipPath, configName, configPath string, opts importOptions) ([]string, error) {
 	// 读取配置文件
 	configData, err := os.ReadFile(configPath)
 	if err != nil {
-		return fmt.Errorf("读取配置文件失败 (%s): %w", configPath, err)
+		return nil, fmt.Errorf(tr("读取配置文件失败 (%s): %w"), configPath, err)
 	}
 
-	if !force {
-		switch ext := filepath.Ext(configPath); ext {
-		case ".yaml", ".yml":
-			var cfg map[string]any
-			if err := yaml.Unmarshal(configData, &cfg); err != nil {
-				return fmt.Errorf("配置文件格式无效: %w", err)
-			}
-		case ".json":
-			var cfg map[string]any
-			if err := json.Unmarshal(configData, &cfg); err != nil {
-				return fmt.Errorf("配置文件格式无效: %w", err)
-			}
+	// 读取备份包中的条目和当前元数据
+	ar, err := openArchive(zipPath)
+	if err != nil {
+		return nil, err
+	}
+	entries, metadata, err := readArchiveIndex(ar)
+	ar.Close()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := archiveVersion(metadata); err != nil {
+		return nil, err
+	}
+	oldData, ok := metadata[configName]
+	if !ok {
+		return nil, fmt.Errorf(tr("备份文件中未找到 %s"), configName)
+	}
+
+	if !opts.Force {
+		if err := checkMetadataUpdate(configName, configData, entries); err != nil {
+			return nil, err
 		}
 	}
 
-	if err := updateZipFile(ctx, zipPath, configName, configData, quiet); err != nil {
-		return fmt.Errorf("更新备份文件失败: %w", err)
+	diff := importDiff(configName, oldData, configData)
+	if len(diff) == 0 {
+		fmt.Fprintf(opts.Out, tr("%s 没有变化\n"), configName)
+		return nil, nil
+	}
+	fmt.Fprintf(opts.Out, tr("%s 的变化:\n%s\n"), configName, strings.Join(diff, "\n"))
+	if !opts.Yes && !confirm(opts.In, opts.Out, tr("确认导入?")) {
+		return diff, errors.New(tr("已取消导入"))
 	}
 
-	fmt.Printf("配置已成功导入到: %s\n", zipPath)
+	if err := updateArchiveFile(ctx, zipPath, configName, configData, opts.Compact, opts.Quiet); err != nil {
+		return diff, fmt.Errorf(tr("更新备份文件失败: %w"), err)
+	}
+
+	slog.Info(tr("配置已导入"), "archive", zipPath, "config", configName)
+	return diff, nil
+}
+
+// checkMetadataUpdate 校验要写入备份包的元数据文件，文件映射还要与备份包条目一致。
+// JSON 是 YAML 的子集，两种格式都按 YAML 严格校验
+func checkMetadataUpdate(name string, data []byte, entries []string) error {
+	if err := validateMetadataFile(name, data); err != nil {
+		return fmt.Errorf(tr("配置文件无效: %w"), err)
+	}
+	if name == backupFileMapName {
+		fileMap, _ := decodeFileMap(data)
+		if err := checkFileMapEntries(fileMap, entries); err != nil {
+			return fmt.Errorf(tr("文件映射与备份包条目不一致:\n%w"), err)
+		}
+	}
 	return nil
 }
 
-// updateZipFile 更新zip文件中的配置文件
-func updateZipFile(ctx context.Context, srcPath, configName string, newConfigData []byte, quiet bool) error {
-	// 打开源zip文件
-	srcZip, err := zip.OpenReader(srcPath)
+// saveArchiveMetadata 校验并保存查看器中编辑的元数据文件
+func saveArchiveMetadata(ctx context.Context, zipPath, name string, data []byte) error {
+	ar, err := openArchive(zipPath)
+	if err != nil {
+		return err
+	}
+	entries, metadata, err := readArchiveIndex(ar)
+	ar.Close()
 	if err != nil {
-		return fmt.Errorf("打开源备份文件失败: %w", err)
+		return err
+	}
+	if _, err := archiveVersion(metadata); err != nil {
+		return err
 	}
-	defer srcZip.Close()
 
-	srcZip.RegisterDecompressor(zip.Deflate, func(r io.Reader) io.ReadCloser {
-		return flate.NewReader(r)
-	})
+	if err := checkMetadataUpdate(name, data, entries); err != nil {
+		return err
+	}
+	if err := updateArchiveFile(ctx, zipPath, name, data, false, true); err != nil {
+		return fmt.Errorf(tr("更新备份文件失败: %w"), err)
+	}
+	return nil
+}
 
-	// 创建目标zip文件
-	tempZip, err := os.CreateTemp("", filepath.Base(srcPath)+".tmp")
+// importDiff 返回导入前后的差异，文件映射按条目比较，其他文件按行比较
+func importDiff(name string, oldData, newData []byte) []string {
+	if name == backupFileMapName {
+		oldMap, oldErr := decodeFileMap(oldData)
+		newMap, newErr := decodeFileMap(newData)
+		if oldErr == nil && newErr == nil {
+			return diffFileMaps(oldMap, newMap)
+		}
+	}
+	return diffLines(string(oldData), string(newData))
+}
+
+// confirm 询问用户确认，只有输入 y 或 yes 时返回 true
+func confirm(in io.Reader, out io.Writer, prompt string) bool {
+	fmt.Fprintf(out, "%s [y/N] ", prompt)
+	answer, _ := bufio.NewReader(in).ReadString('\n')
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
+
+// updateArchiveFile 更新备份包中的配置文件。zip 格式默认原地追加，compact 时重写整个备份包以清除旧数据，
+// 未变化的 zip 条目按压缩数据原样复制；其他格式以原格式重新写入
+func updateArchiveFile(ctx context.Context, srcPath, configName string, newConfigData []byte, compact, quiet bool) error {
+	if paths, err := findVolumes(srcPath); err == nil && (len(paths) > 1 || paths[0] != srcPath) {
+		return fmt.Errorf(tr("不支持更新分卷备份文件: %s"), srcPath)
+	}
+
+	// 打开源备份文件
+	src, err := openArchive(srcPath)
 	if err != nil {
-		return fmt.Errorf("创建目标备份文件失败: %w", err)
+		return fmt.Errorf(tr("打开源备份文件失败: %w"), err)
 	}
-	defer os.Remove(tempZip.Name())
-	defer tempZip.Close()
+	defer src.Close()
 
-	dstZip := zip.NewWriter(tempZip)
-	defer dstZip.Close()
+	// 检查配置文件是否存在
+	var (
+		hdr   *entryHeader
+		total int64
+	)
+	if err := src.Walk(func(e *archiveEntry) error {
+		total++
+		if e.Name == configName {
+			hdr = &e.entryHeader
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	if hdr == nil {
+		return fmt.Errorf(tr("备份文件中未找到 %s"), configName)
+	}
 
-	dstZip.RegisterCompressor(zip.Deflate, func(w io.Writer) (io.WriteCloser, error) {
-		return flate.NewWriter(w, flate.BestCompression)
-	})
+	// zip 格式追加新条目和中央目录，不需要重写其他条目
+	if src.Format() == formatZip && !compact {
+		src.Close()
+		hdr.Size = int64(len(newConfigData))
+		hdr.Method = zip.Deflate
+		hdr.ModTime = time.Now()
+		return replaceZipEntry(srcPath, hdr, newConfigData)
+	}
+
+	// 在同一目录创建目标备份文件，保证最后的重命名不会跨文件系统
+	info, err := os.Stat(srcPath)
+	if err != nil {
+		return err
+	}
+	tempFile, err := os.CreateTemp(filepath.Dir(srcPath), "."+filepath.Base(srcPath)+".tmp")
+	if err != nil {
+		return fmt.Errorf(tr("创建目标备份文件失败: %w"), err)
+	}
+	defer os.Remove(tempFile.Name())
+	defer tempFile.Close()
+	if err := tempFile.Chmod(info.Mode().Perm()); err != nil {
+		return fmt.Errorf(tr("创建目标备份文件失败: %w"), err)
+	}
+
+	dst, err := newArchiveWriter(tempFile, src.Format(), 0, nil)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	bar := newProgressBar(total, quiet, tr("正在更新备份文件..."))
 
 	// 复制所有文件，替换配置文件
-	if !slices.ContainsFunc(srcZip.File, func(f *zip.File) bool { return f.Name == configName }) {
-		return fmt.Errorf("备份文件中未找到 %s", configName)
-	}
-
-	bar := newProgressBar(int64(len(srcZip.File)), quiet, "正在更新备份文件...")
-
-	for _, f := range srcZip.File {
-		select {
-		case <-ctx.Done():
-			return ctx.Err()
-		default:
-			bar.Describe("更新文件: " + f.Name)
-			if f.Name == configName {
-				// 写入新的配置文件
-				w, err := dstZip.Create(f.Name)
-				if err != nil {
-					return fmt.Errorf("创建zip条目失败: %w", err)
-				}
-				if _, err := w.Write(newConfigData); err != nil {
-					return fmt.Errorf("写入配置文件失败: %w", err)
-				}
-			} else {
-				// 复制其他文件
-				if err := copyZipFile(f, dstZip); err != nil {
-					return fmt.Errorf("复制文件失败 (%s): %w", f.Name, err)
-				}
-			}
+	err = src.Walk(func(e *archiveEntry) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 
-			bar.Add(1)
+		bar.Describe(fmt.Sprintf(tr("更新文件: %s"), e.Name))
+		if e.Name == configName {
+			// 写入新的配置文件
+			hdr := e.entryHeader
+			hdr.Size = int64(len(newConfigData))
+			hdr.Method = zip.Deflate
+			w, err := dst.Create(&hdr)
+			if err != nil {
+				return fmt.Errorf(tr("创建备份条目失败: %w"), err)
+			}
+			if _, err := w.Write(newConfigData); err != nil {
+				return fmt.Errorf(tr("写入配置文件失败: %w"), err)
+			}
+		} else {
+			// 复制其他文件
+			if err := copyArchiveEntry(e, dst); err != nil {
+				return fmt.Errorf(tr("复制文件失败 (%s): %w"), e.Name, err)
+			}
 		}
+
+		bar.Add(1)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf(tr("写入备份文件失败: %w"), err)
+	}
+	if err := tempFile.Close(); err != nil {
+		return fmt.Errorf(tr("写入备份文件失败: %w"), err)
 	}
 
 	// 替换原文件
-	if err := os.Rename(tempZip.Name(), srcPath); err != nil {
-		return fmt.Errorf("替换备份文件失败: %w", err)
+	if err := os.Rename(tempFile.Name(), srcPath); err != nil {
+		return fmt.Errorf(tr("替换备份文件失败: %w"), err)
 	}
 	return nil
 }
 
-// copyZipFile 复制zip文件条目
-func copyZipFile(src *zip.File, dst *zip.Writer) error {
+// copyArchiveEntry 复制备份包条目，zip 条目复制到 zip 备份包时不解压，保留原有头部
+func copyArchiveEntry(src *archiveEntry, dst archiveWriter) error {
+	if zw, ok := dst.(*zipArchiveWriter); ok && src.zf != nil {
+		return zw.zw.Copy(src.zf)
+	}
+
 	// 打开源文件
 	rc, err := src.Open()
 	if err != nil {
@@ -217,7 +441,7 @@ func copyZipFile(src *zip.File, dst *zip.Writer) error {
 	defer rc.Close()
 
 	// 创建目标文件
-	w, err := dst.Create(src.Name)
+	w, err := dst.Create(&src.entryHeader)
 	if err != nil {
 		return err
 	}
@@ -227,41 +451,39 @@ func copyZipFile(src *zip.File, dst *zip.Writer) error {
 	return err
 }
 
-func readFile(zipPath, configName string) ([]byte, error) {
-	// 打开备份文件
-	r, err := zip.OpenReader(zipPath)
+// readMetadataFile 读取备份包中的元数据文件，结构版本高于当前程序支持的版本时返回错误
+func readMetadataFile(zipPath, configName string) ([]byte, error) {
+	ar, err := openArchive(zipPath)
 	if err != nil {
-		return nil, fmt.Errorf("打开备份文件失败 (%s): %w", zipPath, err)
+		return nil, err
 	}
-	defer r.Close()
+	defer ar.Close()
 
-	r.RegisterDecompressor(zip.Deflate, func(r io.Reader) io.ReadCloser {
-		return flate.NewReader(r)
-	})
-
-	// 查找配置文件
-	var configData []byte
-	for _, f := range r.File {
-		if f.Name == configName {
-			rc, err := f.Open()
-			if err != nil {
-				return nil, fmt.Errorf("打开配置文件失败: %w", err)
-			}
-			defer rc.Close()
+	files, err := readArchiveFiles(ar, configName, backupManifestName)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := archiveVersion(files); err != nil {
+		return nil, err
+	}
 
-			configData, err = io.ReadAll(rc)
-			if err != nil {
-				return nil, fmt.Errorf("读取配置文件失败: %w", err)
-			}
-			break
-		}
+	data, ok := files[configName]
+	if !ok {
+		return nil, fmt.Errorf(tr("备份文件中未找到 %s"), configName)
 	}
+	return data, nil
+}
 
-	if configData == nil {
-		return nil, fmt.Errorf("备份文件中未找到 %s", configName)
+func readFile(zipPath, configName string) ([]byte, error) {
+	// 打开备份文件
+	ar, err := openArchive(zipPath)
+	if err != nil {
+		return nil, err
 	}
+	defer ar.Close()
 
-	return configData, nil
+	// 查找配置文件
+	return ar.ReadFile(configName)
 }
 
 var (
@@ -278,67 +500,254 @@ var (
 	}()
 )
 
+// maxEditLines 查看器中可编辑的最大行数
+const maxEditLines = 99999
+
+// modelOptions 查看器的选项
+type modelOptions struct {
+	RootDir  string     // 默认还原根目录
+	Script   bool       // 还原时执行备份包中的还原前后脚本
+	Throttle ioThrottle // 还原的并发数和读写限速
+}
+
+// model 备份包查看器：以目录树浏览备份包，预览、搜索文件，只还原选中的文件和目录，
+// 并可查看和编辑备份包中的元数据文件
 type model struct {
-	title    string
+	ctx     context.Context
+	path    string
+	opts    modelOptions
+	ar      archiveReader
+	cfg     *Config
+	fileMap FileMap
+	root    *treeNode
+	nodes   map[string]*treeNode // 条目名到节点
+	marked  map[string]bool      // 标记还原的条目
+
+	rows          []treeRow
+	cursor        int
+	offset        int
+	query         string
+	mode          browseMode
+	width, height int
+
+	input    textinput.Model
+	viewport viewport.Model // 文件预览和元数据查看
+	progress progress.Model
+	rootDir  string
+	status   string
+
+	// 元数据查看和编辑
+	title    string // 正在查看的元数据文件
 	content  string
-	ready    bool
-	viewport viewport.Model
+	textarea textarea.Model
+
+	// 还原状态
+	total, done int
+	current     string
+	events      chan tea.Msg
+	cancel      context.CancelFunc
+	result      string
 }
 
-func (m model) Init() tea.Cmd {
-	return nil
+// newModel 打开备份包并构建目录树
+func newModel(ctx context.Context, path string, opts modelOptions) (*model, error) {
+	m := &model{
+		ctx:      ctx,
+		path:     path,
+		opts:     opts,
+		input:    textinput.New(),
+		textarea: textarea.New(),
+		progress: progress.New(progress.WithDefaultGradient()),
+		rootDir:  opts.RootDir,
+	}
+	m.textarea.MaxHeight = maxEditLines
+	if err := m.load(); err != nil {
+		return nil, err
+	}
+	return m, nil
 }
 
-func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	var (
-		cmd  tea.Cmd
-		cmds []tea.Cmd
-	)
+func (m *model) Init() tea.Cmd {
+	return nil
+}
 
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	switch msg := msg.(type) {
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+		m.viewport.Width = msg.Width
+		m.viewport.Height = m.listHeight()
+		m.textarea.SetWidth(msg.Width)
+		m.textarea.SetHeight(m.listHeight())
+		m.progress.Width = max(10, msg.Width-4)
+		m.scroll()
+		return m, nil
+	case restoreProgressMsg:
+		m.done += int(msg)
+		return m, waitForRestore(m.events)
+	case restoreDescribeMsg:
+		m.current = string(msg)
+		return m, waitForRestore(m.events)
+	case restoreDoneMsg:
+		m.mode = browseDone
+		m.cancel()
+		if msg.err != nil {
+			m.result = fmt.Sprintf(tr("还原失败: %v"), msg.err)
+		} else {
+			m.result = fmt.Sprintf(tr("已还原 %d 个条目到 %s"), m.done, m.rootDir)
+		}
+		return m, nil
+	case tea.MouseMsg:
+		if m.mode == browsePreview || m.mode == browseMeta {
+			var cmd tea.Cmd
+			m.viewport, cmd = m.viewport.Update(msg)
+			return m, cmd
+		}
 	case tea.KeyMsg:
-		if k := msg.String(); k == "ctrl+c" || k == "q" || k == "esc" {
+		if msg.String() == "ctrl+c" {
+			if m.cancel != nil {
+				m.cancel()
+			}
 			return m, tea.Quit
 		}
+		return m.handleKey(msg)
+	}
+	return m, nil
+}
 
-	case tea.WindowSizeMsg:
-		headerHeight := lipgloss.Height(m.headerView())
-		footerHeight := lipgloss.Height(m.footerView())
-		verticalMarginHeight := headerHeight + footerHeight
-
-		if !m.ready {
-			m.viewport = viewport.New(msg.Width, msg.Height-verticalMarginHeight)
-			m.viewport.YPosition = headerHeight
-			m.viewport.SetContent(m.content)
-			m.ready = true
-		} else {
-			m.viewport.Width = msg.Width
-			m.viewport.Height = msg.Height - verticalMarginHeight
+// showMetadata 查看备份包中的元数据文件
+func (m *model) showMetadata(name string) error {
+	data, err := m.ar.ReadFile(name)
+	if err != nil {
+		return err
+	}
+	m.title, m.content = name, string(data)
+	m.viewport = viewport.New(m.width, m.listHeight())
+	m.viewport.SetContent(m.content)
+	m.mode = browseMeta
+	m.status = ""
+	return nil
+}
+
+// updateMeta 处理查看元数据文件时的按键，e 进入编辑模式
+func (m *model) updateMeta(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc", "q", "left", "h":
+		m.mode = browseList
+		m.status = ""
+		return m, nil
+	case "e":
+		if strings.Count(m.content, "\n") >= maxEditLines {
+			// 超出行数的内容会被编辑器截断
+			m.status = fmt.Sprintf(tr("超过 %d 行，请使用 export/import 修改"), maxEditLines)
+			return m, nil
 		}
+		m.mode = browseEdit
+		m.status = ""
+		m.textarea.SetValue(m.content)
+		return m, m.textarea.Focus()
 	}
 
-	// Handle keyboard and mouse events in the viewport
+	var cmd tea.Cmd
 	m.viewport, cmd = m.viewport.Update(msg)
-	cmds = append(cmds, cmd)
+	return m, cmd
+}
 
-	return m, tea.Batch(cmds...)
+// updateEditor 处理编辑模式的按键，ctrl+s 校验并保存，esc 放弃修改
+func (m *model) updateEditor(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "esc":
+		m.mode = browseMeta
+		m.textarea.Blur()
+		m.status = tr("已放弃修改")
+		return m, nil
+	case "ctrl+s":
+		data := []byte(m.textarea.Value())
+		if err := saveArchiveMetadata(m.ctx, m.path, m.title, data); err != nil {
+			m.status = tr("保存失败: ") + strings.Join(strings.Fields(err.Error()), " ")
+			return m, nil
+		}
+		m.mode = browseMeta
+		m.textarea.Blur()
+		m.content = string(data)
+		m.viewport.SetContent(m.content)
+		m.status = tr("已保存")
+		// 保存后备份包的目录和元数据都已变化，重新读取
+		if err := m.load(); err != nil {
+			m.status = fmt.Sprintf(tr("重新读取备份包失败: %v"), err)
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.textarea, cmd = m.textarea.Update(msg)
+	return m, cmd
 }
 
-func (m model) View() string {
-	if !m.ready {
+func (m *model) View() string {
+	if m.width == 0 {
 		return "\n  Initializing..."
 	}
-	return fmt.Sprintf("%s\n%s\n%s", m.headerView(), m.viewport.View(), m.footerView())
+
+	var body string
+	switch m.mode {
+	case browsePreview, browseMeta:
+		body = m.viewport.View()
+	case browseEdit:
+		body = m.textarea.View()
+	case browseRootInput:
+		body = m.confirmView()
+	case browseRestoring, browseDone:
+		body = m.restoreView()
+	default:
+		body = m.listView()
+	}
+	return fmt.Sprintf("%s\n%s\n%s", m.headerView(), body, m.footerView())
 }
 
-func (m model) headerView() string {
-	title := titleStyle.Render(m.title)
-	line := strings.Repeat("─", max(0, m.viewport.Width-lipgloss.Width(title)))
-	return lipgloss.JoinHorizontal(lipgloss.Center, title, line)
+func (m *model) headerView() string {
+	title := m.path
+	switch m.mode {
+	case browsePreview:
+		if n := m.currentNode(); n != nil {
+			title = n.path
+		}
+	case browseMeta, browseEdit:
+		title = m.title
+	}
+	t := titleStyle.Render(title)
+	line := strings.Repeat("─", max(0, m.width-lipgloss.Width(t)))
+	return lipgloss.JoinHorizontal(lipgloss.Center, t, line)
 }
 
-func (m model) footerView() string {
-	info := infoStyle.Render(fmt.Sprintf("%3.f%%", m.viewport.ScrollPercent()*100))
-	line := strings.Repeat("─", max(0, m.viewport.Width-lipgloss.Width(info)))
-	return lipgloss.JoinHorizontal(lipgloss.Center, line, info)
+func (m *model) footerView() string {
+	var help string
+	info := fmt.Sprintf(tr("已选择 %d 项 %s"), len(m.marked), formatSize(m.selectionSize()))
+	switch m.mode {
+	case browseSearch, browseRootInput:
+		help = m.input.View()
+	case browsePreview:
+		help = tr("↑/↓ 滚动  esc 返回")
+	case browseMeta:
+		help = tr("↑/↓ 滚动  e 编辑  esc 返回")
+		info = fmt.Sprintf("%3.f%%", m.viewport.ScrollPercent()*100)
+	case browseEdit:
+		help = tr("ctrl+s 保存  esc 放弃修改")
+	case browseRestoring:
+		help = tr("ctrl+c 取消")
+	case browseDone:
+		help = tr("任意键返回  q 退出")
+	default:
+		help = tr("↑/↓ 移动  →/enter 展开或预览  ← 收起  空格 标记  / 搜索  c 配置  m 文件映射  r 还原  q 退出")
+	}
+	if m.status != "" {
+		help = m.status + "  " + help
+	}
+	if m.width > 0 {
+		help = lipgloss.NewStyle().MaxWidth(m.width).Render(help)
+	}
+
+	info = infoStyle.Render(info)
+	line := strings.Repeat("─", max(0, m.width-lipgloss.Width(info)))
+	return lipgloss.JoinVertical(lipgloss.Left, lipgloss.JoinHorizontal(lipgloss.Center, line, info), help)
 }
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/goccy/go-yaml"
+)
+
+func Test_importConfigToBackup(t *testing.T) {
+	cfg, configBytes, err := loadConfig("testdata/config.yaml", "")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "backup.zip")
+	if err := backup(t.Context(), cfg, configBytes, archivePath, backupOptions{Quiet: true}); err != nil {
+		t.Fatalf("backup() error = %v", err)
+	}
+
+	data, err := readFile(archivePath, backupFileMapName)
+	if err != nil {
+		t.Fatalf("readFile() error = %v", err)
+	}
+	var fileMap FileMap
+	if err := yaml.Unmarshal(data, &fileMap); err != nil {
+		t.Fatal(err)
+	}
+
+	writeMap := func(m FileMap) string {
+		path := filepath.Join(t.TempDir(), backupFileMapName)
+		out, _ := yaml.Marshal(m)
+		os.WriteFile(path, out, 0644)
+		return path
+	}
+	relocated := make(FileMap, len(fileMap))
+	for k, v := range fileMap {
+		relocated[k] = "/srv" + v
+	}
+	dropped := make(FileMap, len(fileMap))
+	for k, v := range fileMap {
+		if !strings.HasSuffix(k, "data3.txt") {
+			dropped[k] = v
+		}
+	}
+	unknown := FileMap{"data/missing.txt": "/missing.txt"}
+	for k, v := range fileMap {
+		unknown[k] = v
+	}
+
+	tests := []struct {
+		name    string
+		fileMap FileMap
+		input   string
+		wantErr bool
+		wantMap FileMap // 导入后备份包中的文件映射
+	}{
+		{name: "dropped entry", fileMap: dropped, input: "y\n", wantErr: true, wantMap: fileMap},
+		{name: "unknown entry", fileMap: unknown, input: "y\n", wantErr: true, wantMap: fileMap},
+		{name: "declined", fileMap: relocated, input: "n\n", wantErr: true, wantMap: fileMap},
+		{name: "confirmed", fileMap: relocated, input: "y\n", wantMap: relocated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := importOptions{Quiet: true, In: strings.NewReader(tt.input), Out: io.Discard}
+			_, err := importConfigToBackup(t.Context(), archivePath, backupFileMapName, writeMap(tt.fileMap), opts)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("importConfigToBackup() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			data, err := readFile(archivePath, backupFileMapName)
+			if err != nil {
+				t.Fatalf("readFile() error = %v", err)
+			}
+			var got FileMap
+			yaml.Unmarshal(data, &got)
+			if len(diffFileMaps(got, tt.wantMap)) != 0 {
+				t.Errorf("file map after import = %v, want %v", got, tt.wantMap)
+			}
+		})
+	}
+}
+
+func Test_model_edit(t *testing.T) {
+	cfg, configBytes, err := loadConfig("testdata/config.yaml", "")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	archivePath := filepath.Join(t.TempDir(), "backup.zip")
+	if err := backup(t.Context(), cfg, configBytes, archivePath, backupOptions{Quiet: true}); err != nil {
+		t.Fatalf("backup() error = %v", err)
+	}
+
+	got, err := newModel(t.Context(), archivePath, modelOptions{})
+	if err != nil {
+		t.Fatalf("newModel() error = %v", err)
+	}
+	defer func() { got.ar.Close() }()
+	update := func(msg tea.Msg) *model {
+		got.Update(msg)
+		return got
+	}
+	update(tea.WindowSizeMsg{Width: 80, Height: 24})
+	pressKeys(got, "c")
+	if got.mode != browseMeta || got.content != string(configBytes) {
+		t.Fatalf("按 c 后应查看 %s, mode = %v", backupConfigName, got.mode)
+	}
+	if update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("e")}); got.mode != browseEdit {
+		t.Fatal("按 e 后应进入编辑模式")
+	}
+
+	// 未知字段校验失败，保持编辑模式，备份包不变
+	update(tea.KeyMsg{Type: tea.KeyEnter})
+	update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("exclude_file: [x]")})
+	update(tea.KeyMsg{Type: tea.KeyCtrlS})
+	if got.mode != browseEdit || !strings.HasPrefix(got.status, "保存失败") {
+		t.Fatalf("mode = %v status = %q, want validation error", got.mode, got.status)
+	}
+	if data, _ := readFile(archivePath, backupConfigName); string(data) != string(configBytes) {
+		t.Errorf("校验失败后备份包被修改")
+	}
+
+	newConfig := strings.Replace(string(configBytes), "test2", "test3", 1)
+	got.textarea.SetValue(newConfig)
+	update(tea.KeyMsg{Type: tea.KeyCtrlS})
+	if got.mode != browseMeta || got.content != newConfig {
+		t.Fatalf("mode = %v status = %q, want saved", got.mode, got.status)
+	}
+	// 保存后重新读取备份包，还原使用新的配置
+	if got.cfg.AfterScript != "echo 'test3'" {
+		t.Errorf("AfterScript after save = %q", got.cfg.AfterScript)
+	}
+	if data, _ := readFile(archivePath, backupConfigName); string(data) != newConfig {
+		t.Errorf("%s = %q, want %q", backupConfigName, data, newConfig)
+	}
+	if _, err := readFile(archivePath, backupFileMapName); err != nil {
+		t.Errorf("保存后读取其他条目失败: %v", err)
+	}
+}
+
+func Test_updateArchiveFile(t *testing.T) {
+	cfg, configBytes, err := loadConfig("testdata/config.yaml", "")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	cfg.Compression.Algorithm = "zstd"
+
+	tests := []struct {
+		name    string
+		format  archiveFormat
+		compact bool
+	}{
+		{name: "zip in place", format: formatZip},
+		{name: "zip compact", format: formatZip, compact: true},
+		{name: "tar.zst", format: formatTarZst},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			archivePath := filepath.Join(t.TempDir(), "backup."+string(tt.format))
+			if err := backup(t.Context(), cfg, configBytes, archivePath, backupOptions{Format: tt.format, Quiet: true}); err != nil {
+				t.Fatalf("backup() error = %v", err)
+			}
+			os.Chmod(archivePath, 0640)
+			before := archiveHeaders(t, archivePath)
+			rawBefore := zipRawSizes(t, archivePath, tt.format)
+
+			newConfig := []byte("backup_paths:\n  - /srv\n")
+			if err := updateArchiveFile(t.Context(), archivePath, backupConfigName, newConfig, tt.compact, true); err != nil {
+				t.Fatalf("updateArchiveFile() error = %v", err)
+			}
+
+			if data, _ := readFile(archivePath, backupConfigName); string(data) != string(newConfig) {
+				t.Errorf("%s = %q, want %q", backupConfigName, data, newConfig)
+			}
+			after := archiveHeaders(t, archivePath)
+			if len(after) != len(before) {
+				t.Fatalf("entries = %d, want %d", len(after), len(before))
+			}
+			for name, hdr := range before {
+				if name == backupConfigName {
+					continue
+				}
+				if got := after[name]; got.Method != hdr.Method || got.Size != hdr.Size || !got.ModTime.Equal(hdr.ModTime) {
+					t.Errorf("%s header = %+v, want %+v", name, got, hdr)
+				}
+			}
+			// 未变化的 zip 条目原样复制，压缩数据不变
+			rawAfter := zipRawSizes(t, archivePath, tt.format)
+			for name, raw := range rawBefore {
+				if name != backupConfigName && rawAfter[name] != raw {
+					t.Errorf("%s compressed = %v, want %v", name, rawAfter[name], raw)
+				}
+			}
+			if info, _ := os.Stat(archivePath); info.Mode().Perm() != 0640 {
+				t.Errorf("mode = %v, want 0640", info.Mode().Perm())
+			}
+			if tmp, _ := filepath.Glob(filepath.Join(filepath.Dir(archivePath), ".*.tmp*")); len(tmp) != 0 {
+				t.Errorf("临时文件未清理: %v", tmp)
+			}
+		})
+	}
+}
+
+// zipRawSizes 返回 zip 备份包中各条目的压缩大小和 CRC，其他格式返回 nil
+func zipRawSizes(t *testing.T, path string, format archiveFormat) map[string][2]uint64 {
+	t.Helper()
+	if format != formatZip {
+		return nil
+	}
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer r.Close()
+	sizes := make(map[string][2]uint64)
+	for _, f := range r.File {
+		sizes[f.Name] = [2]uint64{f.CompressedSize64, uint64(f.CRC32)}
+	}
+	return sizes
+}
+
+// archiveHeaders 返回备份包中所有条目的头部
+func archiveHeaders(t *testing.T, path string) map[string]entryHeader {
+	t.Helper()
+	ar, err := openArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	headers := make(map[string]entryHeader)
+	ar.Walk(func(e *archiveEntry) error {
+		headers[e.Name] = e.entryHeader
+		return nil
+	})
+	return headers
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_parseCron(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		wantErr bool
+	}{
+		{name: "every minute", spec: "* * * * *"},
+		{name: "lists ranges steps", spec: "0,30 8-18/2 1-15 */3 1-5"},
+		{name: "names", spec: "0 3 * jan-jun mon,fri"},
+		{name: "sunday as 7", spec: "0 0 * * 7"},
+		{name: "descriptor", spec: "@daily"},
+		{name: "every", spec: "@every 90m"},
+		{name: "too few fields", spec: "0 3 * *", wantErr: true},
+		{name: "minute out of range", spec: "60 * * * *", wantErr: true},
+		{name: "inverted range", spec: "0 18-8 * * *", wantErr: true},
+		{name: "zero step", spec: "*/0 * * * *", wantErr: true},
+		{name: "unknown name", spec: "0 0 * foo *", wantErr: true},
+		{name: "bad every", spec: "@every soon", wantErr: true},
+		{name: "negative every", spec: "@every -1h", wantErr: true},
+		{name: "empty", spec: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := parseCron(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("parseCron(%q) error = %v, wantErr %v", tt.spec, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_cronSchedule_Next(t *testing.T) {
+	// 2026-01-01 是星期四
+	from := time.Date(2026, 1, 1, 10, 17, 42, 0, time.UTC)
+	tests := []struct {
+		name string
+		spec string
+		want time.Time
+	}{
+		{name: "next minute", spec: "* * * * *", want: time.Date(2026, 1, 1, 10, 18, 0, 0, time.UTC)},
+		{name: "later today", spec: "30 14 * * *", want: time.Date(2026, 1, 1, 14, 30, 0, 0, time.UTC)},
+		{name: "tomorrow", spec: "0 3 * * *", want: time.Date(2026, 1, 2, 3, 0, 0, 0, time.UTC)},
+		{name: "step", spec: "*/20 * * * *", want: time.Date(2026, 1, 1, 10, 20, 0, 0, time.UTC)},
+		{name: "weekday name", spec: "0 0 * * mon", want: time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{name: "sunday as 7", spec: "0 0 * * 7", want: time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC)},
+		{name: "month name", spec: "0 0 1 mar *", want: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "dom or dow", spec: "0 0 15 * fri", want: time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC)},
+		{name: "dom only", spec: "0 0 15 * *", want: time.Date(2026, 1, 15, 0, 0, 0, 0, time.UTC)},
+		{name: "yearly", spec: "@yearly", want: time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{name: "hourly", spec: "@hourly", want: time.Date(2026, 1, 1, 11, 0, 0, 0, time.UTC)},
+		{name: "leap day", spec: "0 0 29 2 *", want: time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{name: "never", spec: "0 0 31 2 *", want: time.Time{}},
+		{name: "every", spec: "@every 90m", want: from.Add(90 * time.Minute)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := parseCron(tt.spec)
+			if err != nil {
+				t.Fatalf("parseCron(%q) error = %v", tt.spec, err)
+			}
+			if got := s.Next(from); !got.Equal(tt.want) {
+				t.Errorf("Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
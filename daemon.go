@@ -0,0 +1,535 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/spf13/cobra"
+)
+
+const defaultStateFile = "/var/lib/backtrack/state.json"
+
+var daemonCmd = &cobra.Command{
+	Use:     "daemon",
+	Short:   "按计划定时执行备份",
+	PreRunE: checkRoot,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configPath, _ := cmd.Flags().GetString("config")
+		once, _ := cmd.Flags().GetBool("once")
+
+		dc, err := loadDaemonConfig(configPath)
+		if err != nil {
+			return err
+		}
+		if cmd.Flags().Changed("metrics-listen") {
+			dc.MetricsListen, _ = cmd.Flags().GetString("metrics-listen")
+		}
+		if cmd.Flags().Changed("metrics-textfile") {
+			dc.MetricsTextfile, _ = cmd.Flags().GetString("metrics-textfile")
+		}
+
+		if dc.throttle, err = throttleFromFlags(cmd); err != nil {
+			return err
+		}
+		priority, err := priorityFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
+		cmd.SilenceUsage = true
+		if err := priority.apply(); err != nil {
+			return err
+		}
+		return runDaemon(cmd.Context(), dc, once)
+	},
+}
+
+func init() {
+	daemonCmd.Flags().StringP("config", "c", "daemon.yaml", "定时任务配置文件路径")
+	daemonCmd.Flags().Bool("once", false, "立即运行所有任务一次后退出")
+	daemonCmd.Flags().String("metrics-listen", "", "提供 /metrics 端点的监听地址，如 127.0.0.1:9779，覆盖配置文件")
+	daemonCmd.Flags().String("metrics-textfile", "", "每次任务结束后写入 Prometheus 指标的文件，覆盖配置文件")
+	addThrottleFlags(daemonCmd)
+
+	rootCmd.AddCommand(daemonCmd)
+}
+
+// DaemonConfig 定时任务配置
+type DaemonConfig struct {
+	StateFile       string      `yaml:"state_file,omitempty"`       // 记录每个任务最近一次运行结果
+	MetricsListen   string      `yaml:"metrics_listen,omitempty"`   // 提供 /metrics 端点的监听地址
+	MetricsTextfile string      `yaml:"metrics_textfile,omitempty"` // node_exporter textfile collector 读取的指标文件
+	Jobs            []DaemonJob `yaml:"jobs"`
+
+	throttle ioThrottle // 命令行指定的并发数和读写限速，限速由所有任务共享
+}
+
+// DaemonJob 一个定时备份任务
+type DaemonJob struct {
+	Name      string          `yaml:"name"`
+	Config    string          `yaml:"config"` // 备份配置文件，相对路径相对于定时任务配置文件
+	Profile   string          `yaml:"profile,omitempty"`
+	Schedule  string          `yaml:"schedule"` // cron 表达式
+	Output    string          `yaml:"output"`   // 输出路径模板
+	Format    string          `yaml:"format,omitempty"`
+	Retention RetentionPolicy `yaml:"retention,omitempty"`
+
+	schedule *cronSchedule
+	format   archiveFormat
+}
+
+var (
+	jobNamePattern   = regexp.MustCompile(`^[A-Za-z0-9_.-]+$`)
+	outputVarPattern = regexp.MustCompile(`\{([^{}]*)\}`)
+)
+
+// outputVars 输出路径模板中可用的变量
+var outputVars = []string{"host", "job", "profile", "time", "date", "format"}
+
+// loadDaemonConfig 严格解析定时任务配置，补全默认值并校验每个任务
+func loadDaemonConfig(path string) (*DaemonConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf(tr("读取定时任务配置失败 (%s): %w"), path, err)
+	}
+	var dc DaemonConfig
+	if err := yaml.UnmarshalWithOptions(data, &dc, yaml.Strict()); err != nil {
+		return nil, fmt.Errorf(tr("解析定时任务配置失败 (%s):\n%s"), path, yaml.FormatError(err, false, true))
+	}
+
+	dir := filepath.Dir(path)
+	if dc.StateFile == "" {
+		dc.StateFile = defaultStateFile
+	}
+	dc.StateFile = resolvePath(dir, dc.StateFile)
+	dc.MetricsTextfile = resolvePath(dir, dc.MetricsTextfile)
+
+	if len(dc.Jobs) == 0 {
+		return nil, fmt.Errorf(tr("定时任务配置无效 (%s): jobs 不能为空"), path)
+	}
+	var errs []error
+	names := make(map[string]bool)
+	for i := range dc.Jobs {
+		j := &dc.Jobs[i]
+		if names[j.Name] {
+			errs = append(errs, fmt.Errorf(tr("任务名称重复: %s"), j.Name))
+		}
+		names[j.Name] = true
+		j.Config = resolvePath(dir, j.Config)
+		j.Output = resolvePath(dir, j.Output)
+		if err := j.validate(); err != nil {
+			errs = append(errs, fmt.Errorf(tr("任务 %s: %w"), j.Name, err))
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, fmt.Errorf(tr("定时任务配置无效 (%s):\n%w"), path, err)
+	}
+	return &dc, nil
+}
+
+// resolvePath 将相对路径解析为相对于 dir 的路径
+func resolvePath(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// validate 检查任务配置并解析调度和格式
+func (j *DaemonJob) validate() error {
+	var errs []error
+	if !jobNamePattern.MatchString(j.Name) {
+		errs = append(errs, fmt.Errorf(tr("name 只能包含字母、数字、_、. 和 -: %q"), j.Name))
+	}
+	if j.Config == "" {
+		errs = append(errs, errors.New(tr("config 不能为空")))
+	}
+
+	var err error
+	if j.schedule, err = parseCron(j.Schedule); err != nil {
+		errs = append(errs, err)
+	}
+
+	if j.Output == "" {
+		errs = append(errs, errors.New(tr("output 不能为空")))
+	} else if err := validateOutputTemplate(j.Output); err != nil {
+		errs = append(errs, err)
+	}
+
+	j.format = formatFromPath(strings.TrimSuffix(j.Output, "{format}"))
+	if j.Format != "" {
+		if j.format, err = parseArchiveFormat(j.Format); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := j.Retention.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// validateOutputTemplate 检查模板中的变量，必须包含 {time} 以免覆盖之前的备份
+func validateOutputTemplate(tmpl string) error {
+	hasTime := false
+	for _, m := range outputVarPattern.FindAllStringSubmatch(tmpl, -1) {
+		switch {
+		case m[1] == "time":
+			hasTime = true
+		case !slices.Contains(outputVars, m[1]):
+			return fmt.Errorf(tr("output 中有未知变量 {%s}，可用变量: {%s}"), m[1], strings.Join(outputVars, "}, {"))
+		}
+	}
+	if !hasTime {
+		return fmt.Errorf(tr("output 必须包含 {time}: %s"), tmpl)
+	}
+	return nil
+}
+
+// templateVars 返回输出路径模板中各变量在时间 t 的值
+func (j *DaemonJob) templateVars(t time.Time) map[string]string {
+	host, _ := os.Hostname()
+	profile := j.Profile
+	if profile == "" {
+		profile = "default"
+	}
+	return map[string]string{
+		"host":    host,
+		"job":     j.Name,
+		"profile": profile,
+		"time":    t.Format("20060102150405"),
+		"date":    t.Format("20060102"),
+		"format":  string(j.format),
+	}
+}
+
+// renderOutput 按模板生成输出路径，glob 为 true 时时间变量替换为 *，用于列出该任务的所有备份
+func (j *DaemonJob) renderOutput(t time.Time, glob bool) string {
+	vars := j.templateVars(t)
+	if glob {
+		vars["time"], vars["date"] = "*", "*"
+	}
+	return outputVarPattern.ReplaceAllStringFunc(j.Output, func(s string) string {
+		return vars[s[1:len(s)-1]]
+	})
+}
+
+// outputRegexp 按模板生成只匹配该任务备份路径的正则。
+// 通配符模式会匹配到名称有相同前缀的其他任务的备份，如任务 db 的 db-* 会匹配 db-archive-*
+func (j *DaemonJob) outputRegexp() *regexp.Regexp {
+	vars := j.templateVars(time.Time{})
+	for name, value := range vars {
+		vars[name] = regexp.QuoteMeta(value)
+	}
+	vars["time"], vars["date"] = `\d{14}`, `\d{8}`
+
+	// 与 filepath.Glob 返回的路径保持一致
+	tmpl := filepath.Clean(j.Output)
+	var b strings.Builder
+	b.WriteString("^")
+	last := 0
+	for _, loc := range outputVarPattern.FindAllStringSubmatchIndex(tmpl, -1) {
+		b.WriteString(regexp.QuoteMeta(tmpl[last:loc[0]]))
+		b.WriteString(vars[tmpl[loc[2]:loc[3]]])
+		last = loc[1]
+	}
+	b.WriteString(regexp.QuoteMeta(tmpl[last:]))
+	b.WriteString("$")
+	return regexp.MustCompile(b.String())
+}
+
+// jobState 任务最近一次运行的结果
+type jobState struct {
+	LastRun      time.Time `json:"last_run,omitzero"`
+	LastSuccess  time.Time `json:"last_success,omitzero"`
+	LastFailure  time.Time `json:"last_failure,omitzero"`
+	LastError    string    `json:"last_error,omitempty"`
+	LastOutput   string    `json:"last_output,omitempty"`
+	LastDuration float64   `json:"last_duration_seconds,omitempty"`
+	NextRun      time.Time `json:"next_run,omitzero"`
+}
+
+// daemonState 状态文件，每次更新后整体写入
+type daemonState struct {
+	path string
+	mu   sync.Mutex
+	Jobs map[string]*jobState `json:"jobs"`
+}
+
+// loadDaemonState 读取状态文件，文件不存在时返回空状态
+func loadDaemonState(path string) (*daemonState, error) {
+	s := &daemonState{path: path, Jobs: make(map[string]*jobState)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf(tr("读取状态文件失败 (%s): %w"), path, err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf(tr("解析状态文件失败 (%s): %w"), path, err)
+	}
+	if s.Jobs == nil {
+		s.Jobs = make(map[string]*jobState)
+	}
+	return s, nil
+}
+
+// update 修改任务状态并写入状态文件，先写临时文件再重命名，避免中断时留下不完整的文件
+func (s *daemonState) update(job string, fn func(*jobState)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	js, ok := s.Jobs[job]
+	if !ok {
+		js = &jobState{}
+		s.Jobs[job] = js
+	}
+	fn(js)
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf(tr("创建状态文件目录失败: %w"), err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf(tr("写入状态文件失败 (%s): %w"), s.path, err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf(tr("写入状态文件失败 (%s): %w"), s.path, err)
+	}
+	return nil
+}
+
+// errJobLocked 任务的上一次运行尚未结束
+var errJobLocked error = i18nError("任务正在运行")
+
+// lockFile 以非阻塞方式获取文件排他锁，已被持有时返回 errJobLocked，关闭文件即释放
+func lockFile(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf(tr("创建锁文件目录失败: %w"), err)
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf(tr("打开锁文件失败 (%s): %w"), path, err)
+	}
+	if err := tryLock(f); err != nil {
+		f.Close()
+		if errors.Is(err, errJobLocked) {
+			return nil, err
+		}
+		return nil, fmt.Errorf(tr("获取锁失败 (%s): %w"), path, err)
+	}
+	return f, nil
+}
+
+// daemon 运行中的定时任务共享的状态
+type daemon struct {
+	cfg     *DaemonConfig
+	state   *daemonState
+	metrics *metricsRegistry
+}
+
+// newDaemon 读取状态文件，并用其中最近一次成功的时间初始化指标
+func newDaemon(dc *DaemonConfig) (*daemon, error) {
+	state, err := loadDaemonState(dc.StateFile)
+	if err != nil {
+		return nil, err
+	}
+	d := &daemon{cfg: dc, state: state, metrics: newMetricsRegistry()}
+	for _, j := range dc.Jobs {
+		if js, ok := state.Jobs[j.Name]; ok && !js.LastSuccess.IsZero() {
+			d.metrics.setLastSuccess(operationBackup, j.Name, js.LastSuccess)
+		}
+	}
+	return d, nil
+}
+
+// runDaemon 按调度运行所有任务，ctx 取消后等待正在运行的备份停止并删除未完成的备份包后返回
+func runDaemon(ctx context.Context, dc *DaemonConfig, once bool) error {
+	d, err := newDaemon(dc)
+	if err != nil {
+		return err
+	}
+
+	if once {
+		var errs []error
+		for i := range dc.Jobs {
+			if err := d.runJob(ctx, &dc.Jobs[i], time.Now()); err != nil {
+				errs = append(errs, fmt.Errorf(tr("任务 %s: %w"), dc.Jobs[i].Name, err))
+			}
+		}
+		return errors.Join(errs...)
+	}
+
+	if dc.MetricsListen != "" {
+		srv, addr, err := startMetricsServer(dc.MetricsListen, d.metrics)
+		if err != nil {
+			return err
+		}
+		defer srv.Close()
+		slog.Info(tr("已启动指标端点"), "url", "http://"+addr.String()+"/metrics")
+	}
+
+	var wg sync.WaitGroup
+	for i := range dc.Jobs {
+		j := &dc.Jobs[i]
+		wg.Go(func() {
+			d.runJobLoop(ctx, j)
+		})
+	}
+	slog.Info(tr("已启动定时任务"), "jobs", len(dc.Jobs))
+	wg.Wait()
+	slog.Info(tr("定时任务已停止"))
+	return nil
+}
+
+// startMetricsServer 在 addr 上提供 /metrics 端点，返回实际监听的地址
+func startMetricsServer(addr string, h http.Handler) (*http.Server, net.Addr, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, nil, fmt.Errorf(tr("监听指标端点失败 (%s): %w"), addr, err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", h)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			slog.Error(tr("指标端点已停止"), "error", err)
+		}
+	}()
+	return srv, ln.Addr(), nil
+}
+
+// runJobLoop 等待下一个调度时间运行任务，运行期间错过的调度直接跳过
+func (d *daemon) runJobLoop(ctx context.Context, j *DaemonJob) {
+	for {
+		next := j.schedule.Next(time.Now())
+		if next.IsZero() {
+			slog.Warn(tr("任务没有下一次调度时间"), "job", j.Name)
+			return
+		}
+		if err := d.state.update(j.Name, func(s *jobState) { s.NextRun = next }); err != nil {
+			slog.Error(tr("更新任务状态失败"), "job", j.Name, "error", err)
+		}
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if err := d.runJob(ctx, j, next); err != nil {
+			slog.Error(tr("任务失败"), "job", j.Name, "error", err)
+		}
+	}
+}
+
+// runJob 运行一次备份任务，成功后按保留策略删除旧备份，结果写入状态文件和指标
+func (d *daemon) runJob(ctx context.Context, j *DaemonJob, now time.Time) error {
+	lock, err := lockFile(filepath.Join(filepath.Dir(d.cfg.StateFile), j.Name+".lock"))
+	if err != nil {
+		if errors.Is(err, errJobLocked) {
+			slog.Warn(tr("任务的上一次运行尚未结束，跳过本次调度"), "job", j.Name)
+		}
+		return err
+	}
+	defer lock.Close()
+
+	output := j.renderOutput(now, false)
+	metrics := newRunMetrics(operationBackup, j.Name)
+	slog.Info(tr("任务开始备份"), "job", j.Name, "output", output)
+	cfg, err := runJobBackup(ctx, j, output, metrics, d.cfg.throttle)
+	metrics.finish(err)
+	d.recordMetrics(metrics)
+	if cfg != nil {
+		sendNotifications(ctx, cfg.Notify, newNotifyEvent(metrics, output, err))
+	}
+
+	if uerr := d.state.update(j.Name, func(s *jobState) {
+		s.LastRun = metrics.Start
+		s.LastDuration = metrics.End.Sub(metrics.Start).Seconds()
+		s.LastOutput = output
+		if err != nil {
+			s.LastFailure = metrics.Start
+			s.LastError = err.Error()
+		} else {
+			s.LastSuccess = metrics.Start
+			s.LastError = ""
+		}
+	}); uerr != nil {
+		slog.Error(tr("更新任务状态失败"), "job", j.Name, "error", uerr)
+	}
+	if err != nil {
+		return err
+	}
+	slog.Info(tr("任务备份完成"), "job", j.Name, "output", output)
+
+	if j.Retention.empty() {
+		return nil
+	}
+	decisions, err := pruneBackups(j.renderOutput(now, true), j.outputRegexp(), j.Retention, false)
+	for _, d := range decisions {
+		if !d.Keep {
+			slog.Info(tr("任务删除旧备份"), "job", j.Name, "path", d.Path, "time", d.Time)
+		}
+	}
+	if err != nil {
+		slog.Warn(tr("任务清理旧备份失败"), "job", j.Name, "error", err)
+	}
+	return nil
+}
+
+// recordMetrics 记录任务的运行结果，配置了指标文件时一并写入
+func (d *daemon) recordMetrics(m *runMetrics) {
+	d.metrics.observe(m)
+	if d.cfg.MetricsTextfile == "" {
+		return
+	}
+	if err := d.metrics.writeTextfile(d.cfg.MetricsTextfile); err != nil {
+		slog.Error(tr("写入指标文件失败"), "path", d.cfg.MetricsTextfile, "error", err)
+	}
+}
+
+// runJobBackup 加载任务的配置并按 throttle 限制并发数和读写速率执行备份，配置有效时返回配置用于发送通知
+func runJobBackup(ctx context.Context, j *DaemonJob, output string, metrics *runMetrics, throttle ioThrottle) (*Config, error) {
+	cfg, configBytes, err := loadConfig(j.Config, j.Profile)
+	if err != nil {
+		return nil, fmt.Errorf(tr("加载配置失败: %w"), err)
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, fmt.Errorf(tr("配置无效:\n%w"), err)
+	}
+
+	tags := map[string]string{"job": j.Name}
+	if j.Profile != "" {
+		tags["profile"] = j.Profile
+	}
+	// 下一次运行的文件名不同，不会续传中断的备份
+	return cfg, backup(ctx, cfg, configBytes, output, backupOptions{
+		Format:             j.format,
+		Tags:               tags,
+		Quiet:              true,
+		Metrics:            metrics,
+		Throttle:           throttle,
+		DiscardInterrupted: true,
+	})
+}
@@ -0,0 +1,340 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeDaemonConfig 在临时目录写入定时任务配置，返回配置路径
+func writeDaemonConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "daemon.yaml")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func Test_loadDaemonConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name: "valid",
+			content: `jobs:
+  - name: etc
+    config: config.yaml
+    schedule: "0 3 * * *"
+    output: out/{host}_{job}_{time}.tar.zst
+    retention:
+      keep_daily: 7
+`,
+		},
+		{name: "no jobs", content: "jobs: []\n", wantErr: "jobs 不能为空"},
+		{name: "unknown field", content: "jobs:\n  - name: a\n    schedul: x\n", wantErr: "unknown field"},
+		{
+			name: "duplicate name",
+			content: `jobs:
+  - {name: a, config: c.yaml, schedule: "@daily", output: "a_{time}.zip"}
+  - {name: a, config: c.yaml, schedule: "@daily", output: "b_{time}.zip"}
+`,
+			wantErr: "任务名称重复",
+		},
+		{
+			name:    "bad name",
+			content: `jobs: [{name: "a/b", config: c.yaml, schedule: "@daily", output: "a_{time}.zip"}]`,
+			wantErr: "name 只能包含",
+		},
+		{
+			name:    "bad schedule",
+			content: `jobs: [{name: a, config: c.yaml, schedule: "61 * * * *", output: "a_{time}.zip"}]`,
+			wantErr: "无效的调度",
+		},
+		{
+			name:    "missing time",
+			content: `jobs: [{name: a, config: c.yaml, schedule: "@daily", output: "a_{date}.zip"}]`,
+			wantErr: "必须包含 {time}",
+		},
+		{
+			name:    "unknown variable",
+			content: `jobs: [{name: a, config: c.yaml, schedule: "@daily", output: "{user}_{time}.zip"}]`,
+			wantErr: "未知变量 {user}",
+		},
+		{
+			name:    "negative retention",
+			content: `jobs: [{name: a, config: c.yaml, schedule: "@daily", output: "a_{time}.zip", retention: {keep_last: -1}}]`,
+			wantErr: "不能为负数",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := writeDaemonConfig(t, tt.content)
+			dc, err := loadDaemonConfig(path)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("loadDaemonConfig() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("loadDaemonConfig() error = %v", err)
+			}
+			dir := filepath.Dir(path)
+			j := dc.Jobs[0]
+			if dc.StateFile != defaultStateFile || j.Config != filepath.Join(dir, "config.yaml") || j.format != formatTarZst {
+				t.Errorf("loadDaemonConfig() = %+v, job %+v", dc, j)
+			}
+		})
+	}
+}
+
+func Test_DaemonJob_renderOutput(t *testing.T) {
+	host, _ := os.Hostname()
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	j := DaemonJob{Name: "etc", Output: "/backup/{host}/{job}_{profile}_{date}/{time}.{format}", format: formatZip}
+
+	if got, want := j.renderOutput(now, false), "/backup/"+host+"/etc_default_20260102/20260102030405.zip"; got != want {
+		t.Errorf("renderOutput() = %q, want %q", got, want)
+	}
+	j.Profile = "prod"
+	if got, want := j.renderOutput(now, true), "/backup/"+host+"/etc_prod_*/*.zip"; got != want {
+		t.Errorf("renderOutput(glob) = %q, want %q", got, want)
+	}
+}
+
+func Test_DaemonJob_outputRegexp(t *testing.T) {
+	host, _ := os.Hostname()
+	j := DaemonJob{Name: "db", Output: "/backup/{host}/{job}-{date}/{time}.{format}", format: formatTarZst}
+	re := j.outputRegexp()
+
+	tests := []struct {
+		path string
+		want bool
+	}{
+		{"/backup/" + host + "/db-20260102/20260102030405.tar.zst", true},
+		{"/backup/" + host + "/db-archive-20260102/20260102030405.tar.zst", false},
+		{"/backup/" + host + "/db-20260102/20260102030405.zip", false},
+		{"/backup/" + host + "/db-20260102/2026010203.tar.zst", false},
+		{"/backup/" + host + "/db-20260102/20260102030405.tar.zst.bak", false},
+		{"/backup/other/db-20260102/20260102030405.tar.zst", false},
+	}
+	for _, tt := range tests {
+		if got := re.MatchString(filepath.Clean(tt.path)); got != tt.want {
+			t.Errorf("outputRegexp().MatchString(%q) = %v, want %v", tt.path, got, tt.want)
+		}
+	}
+}
+
+func Test_lockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "job.lock")
+	f, err := lockFile(path)
+	if err != nil {
+		t.Fatalf("lockFile() error = %v", err)
+	}
+	if _, err := lockFile(path); !errors.Is(err, errJobLocked) {
+		t.Fatalf("second lockFile() error = %v, want errJobLocked", err)
+	}
+	f.Close()
+	f, err = lockFile(path)
+	if err != nil {
+		t.Fatalf("lockFile() after release error = %v", err)
+	}
+	f.Close()
+}
+
+// newTestDaemonConfig 返回备份 testdata 的定时任务配置
+func newTestDaemonConfig(t *testing.T, schedule string, retention RetentionPolicy) *DaemonConfig {
+	t.Helper()
+	config, err := filepath.Abs("testdata/config.yaml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	j := DaemonJob{
+		Name:      "testdata",
+		Config:    config,
+		Schedule:  schedule,
+		Output:    filepath.Join(dir, "out", "{job}_{time}.tar.zst"),
+		Retention: retention,
+	}
+	if err := j.validate(); err != nil {
+		t.Fatal(err)
+	}
+	return &DaemonConfig{StateFile: filepath.Join(dir, "state", "state.json"), Jobs: []DaemonJob{j}}
+}
+
+func Test_runJob(t *testing.T) {
+	dc := newTestDaemonConfig(t, "@daily", RetentionPolicy{KeepLast: 2})
+	dc.MetricsTextfile = filepath.Join(t.TempDir(), "backtrack.prom")
+	j := &dc.Jobs[0]
+	d, err := newDaemon(dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	base := time.Date(2026, 1, 1, 3, 0, 0, 0, time.Local)
+	for i := range 3 {
+		if err := d.runJob(t.Context(), j, base.AddDate(0, 0, i)); err != nil {
+			t.Fatalf("runJob() #%d error = %v", i, err)
+		}
+	}
+
+	matches, _ := filepath.Glob(j.renderOutput(base, true))
+	if len(matches) != 2 {
+		t.Errorf("backups after retention = %v, want 2", matches)
+	}
+
+	// 正在运行的任务不会重复运行
+	lock, err := lockFile(filepath.Join(filepath.Dir(dc.StateFile), j.Name+".lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.runJob(t.Context(), j, base.AddDate(0, 0, 3)); !errors.Is(err, errJobLocked) {
+		t.Errorf("runJob() while locked error = %v, want errJobLocked", err)
+	}
+	lock.Close()
+
+	// 失败记录到状态文件
+	j.Config = filepath.Join(t.TempDir(), "missing.yaml")
+	if err := d.runJob(t.Context(), j, base.AddDate(0, 0, 4)); err == nil {
+		t.Error("runJob() with missing config error = nil")
+	}
+
+	saved, err := loadDaemonState(dc.StateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	js := saved.Jobs[j.Name]
+	if js == nil || js.LastSuccess.IsZero() || js.LastFailure.IsZero() || js.LastError == "" ||
+		js.LastOutput != j.renderOutput(base.AddDate(0, 0, 4), false) {
+		t.Errorf("state = %+v", js)
+	}
+
+	// 失败后指标保留最近一次成功的时间
+	data, err := os.ReadFile(dc.MetricsTextfile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{
+		`backtrack_last_run_success{operation="backup",job="testdata"} 0`,
+		`backtrack_last_success_timestamp_seconds{operation="backup",job="testdata"} `,
+	} {
+		if !strings.Contains(string(data), line) {
+			t.Errorf("metrics missing %q:\n%s", line, data)
+		}
+	}
+
+	// 重启后从状态文件恢复最近一次成功的时间
+	d, err = newDaemon(dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := d.metrics.series[metricsKey{operationBackup, j.Name}]; s == nil || !s.lastSuccess.Equal(js.LastSuccess) {
+		t.Errorf("restored metrics = %+v, want last success %v", s, js.LastSuccess)
+	}
+}
+
+func Test_runDaemon(t *testing.T) {
+	dc := newTestDaemonConfig(t, "@every 100ms", RetentionPolicy{})
+	ctx, cancel := context.WithTimeout(t.Context(), 350*time.Millisecond)
+	defer cancel()
+	if err := runDaemon(ctx, dc, false); err != nil {
+		t.Fatalf("runDaemon() error = %v", err)
+	}
+
+	state, err := loadDaemonState(dc.StateFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	js := state.Jobs[dc.Jobs[0].Name]
+	if js == nil || js.LastSuccess.IsZero() || js.NextRun.IsZero() {
+		t.Errorf("state = %+v", js)
+	}
+	if _, err := os.Stat(js.LastOutput); err != nil {
+		t.Errorf("last output: %v", err)
+	}
+}
+
+func Test_runJob_pruneOtherJobs(t *testing.T) {
+	dc := newTestDaemonConfig(t, "@daily", RetentionPolicy{})
+	dir := filepath.Dir(dc.Jobs[0].Output)
+	db, archive := dc.Jobs[0], dc.Jobs[0]
+	db.Name, db.Output, db.Retention = "db", filepath.Join(dir, "{job}-{time}.tar.zst"), RetentionPolicy{KeepLast: 1}
+	archive.Name, archive.Output = "db-archive", db.Output
+	d, err := newDaemon(dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 任务 db 的通配符 db-* 也匹配 db-archive 的备份，清理时不能删除
+	base := time.Date(2026, 1, 1, 3, 0, 0, 0, time.Local)
+	for i := range 2 {
+		if err := d.runJob(t.Context(), &archive, base.AddDate(0, 0, i)); err != nil {
+			t.Fatalf("runJob(db-archive) #%d error = %v", i, err)
+		}
+	}
+	for i := range 2 {
+		if err := d.runJob(t.Context(), &db, base.AddDate(0, 0, 2+i)); err != nil {
+			t.Fatalf("runJob(db) #%d error = %v", i, err)
+		}
+	}
+
+	for job, want := range map[*DaemonJob]int{&db: 1, &archive: 2} {
+		matches, _ := filepath.Glob(job.renderOutput(base, true))
+		var n int
+		for _, m := range matches {
+			if job.outputRegexp().MatchString(m) {
+				n++
+			}
+		}
+		if n != want {
+			t.Errorf("%s backups = %v, want %d", job.Name, matches, want)
+		}
+	}
+}
+
+func Test_runJob_interrupted(t *testing.T) {
+	dc := newTestDaemonConfig(t, "@daily", RetentionPolicy{KeepLast: 2})
+	j := &dc.Jobs[0]
+	d, err := newDaemon(dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 下一次运行使用新的文件名，不会续传，中断的备份包和检查点日志需要删除
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	if err := d.runJob(ctx, j, time.Date(2026, 1, 1, 3, 0, 0, 0, time.Local)); err == nil {
+		t.Fatal("runJob() with canceled context error = nil")
+	}
+	entries, err := os.ReadDir(filepath.Dir(j.Output))
+	if err != nil && !os.IsNotExist(err) {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		t.Errorf("interrupted run left %s", e.Name())
+	}
+}
+
+func Test_runJob_throttle(t *testing.T) {
+	dc := newTestDaemonConfig(t, "@daily", RetentionPolicy{})
+	dc.throttle = ioThrottle{Workers: 1, Read: newRateLimiter(1 << 30), Write: newRateLimiter(1 << 30)}
+	d, err := newDaemon(dc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.runJob(t.Context(), &dc.Jobs[0], time.Now()); err != nil {
+		t.Fatalf("runJob() error = %v", err)
+	}
+	// 限速器被使用过时记录了下一次可以读写的时间
+	if dc.throttle.Read.next.IsZero() || dc.throttle.Write.next.IsZero() {
+		t.Error("runJob() did not use the daemon throttle")
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// diffLines 按行比较两段文本，返回以 "- "、"+ " 开头的变化行
+func diffLines(oldText, newText string) []string {
+	a := strings.Split(strings.TrimSuffix(oldText, "\n"), "\n")
+	b := strings.Split(strings.TrimSuffix(newText, "\n"), "\n")
+
+	// lcs[i][j] 为 a[i:] 与 b[j:] 的最长公共子序列长度
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var diff []string
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, "- "+a[i])
+			i++
+		default:
+			diff = append(diff, "+ "+b[j])
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		diff = append(diff, "- "+a[i])
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, "+ "+b[j])
+	}
+	return diff
+}
+
+// diffFileMaps 比较两个文件映射，按条目名排序返回新增、删除和修改的条目
+func diffFileMaps(oldMap, newMap FileMap) []string {
+	names := make([]string, 0, len(oldMap)+len(newMap))
+	for name := range oldMap {
+		names = append(names, name)
+	}
+	for name := range newMap {
+		if _, ok := oldMap[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	slices.Sort(names)
+
+	var diff []string
+	for _, name := range names {
+		oldPath, inOld := oldMap[name]
+		newPath, inNew := newMap[name]
+		switch {
+		case !inOld:
+			diff = append(diff, fmt.Sprintf("+ %s -> %s", name, newPath))
+		case !inNew:
+			diff = append(diff, fmt.Sprintf("- %s -> %s", name, oldPath))
+		case oldPath != newPath:
+			diff = append(diff, fmt.Sprintf("~ %s: %s -> %s", name, oldPath, newPath))
+		}
+	}
+	return diff
+}
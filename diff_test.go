@@ -0,0 +1,36 @@
+package main
+
+import (
+	"slices"
+	"testing"
+)
+
+func Test_diffLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		old, new string
+		want     []string
+	}{
+		{name: "unchanged", old: "a\nb\n", new: "a\nb\n", want: nil},
+		{name: "changed line", old: "a\nb\nc\n", new: "a\nx\nc\n", want: []string{"- b", "+ x"}},
+		{name: "appended", old: "a\n", new: "a\nb\n", want: []string{"+ b"}},
+		{name: "removed", old: "a\nb\nc\n", new: "a\nc\n", want: []string{"- b"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diffLines(tt.old, tt.new); !slices.Equal(got, tt.want) {
+				t.Errorf("diffLines() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_diffFileMaps(t *testing.T) {
+	oldMap := FileMap{"data/a": "/a", "data/b": "/b", "data/c": "/c"}
+	newMap := FileMap{"data/a": "/a", "data/c": "/srv/c", "data/d": "/d"}
+
+	want := []string{"- data/b -> /b", "~ data/c: /c -> /srv/c", "+ data/d -> /d"}
+	if got := diffFileMaps(oldMap, newMap); !slices.Equal(got, want) {
+		t.Errorf("diffFileMaps() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	fileTypeRegular = "regular"
+	fileTypeSymlink = "symlink"
+	fileTypeDir     = "dir"
+)
+
+// skipStats 一次备份中跳过的条目统计
+type skipStats struct {
+	files  atomic.Int64 // 跳过的文件
+	dirs   atomic.Int64 // 跳过的文件夹
+	bySize atomic.Int64 // 因大小跳过的文件
+	byAge  atomic.Int64 // 因修改时间跳过的文件
+	byType atomic.Int64 // 因类型跳过的文件
+	mounts atomic.Int64 // 因跨文件系统跳过的目录
+}
+
+// skipReason 过滤器跳过条目的原因
+type skipReason int
+
+const (
+	keepEntry skipReason = iota
+	skipBySize
+	skipByAge
+	skipByType
+	skipByMount
+)
+
+// String 返回跳过原因的名称，keepEntry 在跳过时表示被排除规则跳过
+func (r skipReason) String() string {
+	switch r {
+	case skipBySize:
+		return "size"
+	case skipByAge:
+		return "mtime"
+	case skipByType:
+		return "type"
+	case skipByMount:
+		return "mount"
+	default:
+		return "excluded"
+	}
+}
+
+// fileFilter 按大小、修改时间、类型和文件系统筛选条目
+type fileFilter struct {
+	minSize, maxSize     int64
+	newerThan, olderThan time.Time
+	types                []string
+	oneFileSystem        bool
+	rootDev              uint64
+	followSymlinks       bool // 为 false 时按链接本身判断符号链接
+}
+
+// newFileFilter 根据配置创建过滤器，root 用于 one_file_system 判断
+func newFileFilter(cfg *Config, root string, now time.Time) (*fileFilter, error) {
+	f := &fileFilter{
+		types:          cfg.FileTypes,
+		oneFileSystem:  cfg.OneFileSystem,
+		followSymlinks: true,
+	}
+	if len(f.types) == 0 {
+		f.types = []string{fileTypeRegular, fileTypeSymlink}
+	}
+	for _, t := range f.types {
+		if t != fileTypeRegular && t != fileTypeSymlink && t != fileTypeDir {
+			return nil, fmt.Errorf(tr("不支持的文件类型: %s (可选 regular, symlink, dir)"), t)
+		}
+	}
+
+	var err error
+	if f.minSize, err = parseSize(cfg.MinFileSize); err != nil {
+		return nil, fmt.Errorf("min_file_size: %w", err)
+	}
+	if f.maxSize, err = parseSize(cfg.MaxFileSize); err != nil {
+		return nil, fmt.Errorf("max_file_size: %w", err)
+	}
+
+	if cfg.ModifiedWithin != "" {
+		d, err := parseDuration(cfg.ModifiedWithin)
+		if err != nil {
+			return nil, fmt.Errorf("modified_within: %w", err)
+		}
+		f.newerThan = now.Add(-d)
+	}
+	if cfg.OlderThan != "" {
+		d, err := parseDuration(cfg.OlderThan)
+		if err != nil {
+			return nil, fmt.Errorf("older_than: %w", err)
+		}
+		f.olderThan = now.Add(-d)
+	}
+
+	if f.oneFileSystem {
+		info, err := os.Stat(root)
+		if err != nil {
+			return nil, err
+		}
+		f.rootDev = deviceID(info)
+	}
+	return f, nil
+}
+
+// checkDir 检查目录，跨文件系统的目录不再深入
+func (f *fileFilter) checkDir(info fs.FileInfo) skipReason {
+	if f.oneFileSystem && deviceID(info) != f.rootDev {
+		return skipByMount
+	}
+	return keepEntry
+}
+
+// checkFile 检查非目录条目，跟随符号链接时按指向的文件判断大小和修改时间，
+// 否则只按链接本身的修改时间判断
+func (f *fileFilter) checkFile(path string, typ fs.FileMode) (skipReason, error) {
+	var info fs.FileInfo
+	var err error
+	switch {
+	case typ.IsRegular():
+		if !slices.Contains(f.types, fileTypeRegular) {
+			return skipByType, nil
+		}
+		info, err = os.Lstat(path)
+	case typ&fs.ModeSymlink != 0:
+		if !slices.Contains(f.types, fileTypeSymlink) {
+			return skipByType, nil
+		}
+		if !f.followSymlinks {
+			info, err := os.Lstat(path)
+			if err != nil {
+				return keepEntry, err
+			}
+			return f.checkModTime(info), nil
+		}
+		info, err = os.Stat(path)
+	default:
+		return skipByType, nil // 设备、管道、套接字等特殊文件
+	}
+	if err != nil {
+		return keepEntry, err
+	}
+	if !info.Mode().IsRegular() {
+		return skipByType, nil // 指向目录或特殊文件的符号链接
+	}
+
+	if f.minSize > 0 && info.Size() < f.minSize {
+		return skipBySize, nil
+	}
+	if f.maxSize > 0 && info.Size() > f.maxSize {
+		return skipBySize, nil
+	}
+	return f.checkModTime(info), nil
+}
+
+// checkModTime 检查修改时间
+func (f *fileFilter) checkModTime(info fs.FileInfo) skipReason {
+	if !f.newerThan.IsZero() && info.ModTime().Before(f.newerThan) {
+		return skipByAge
+	}
+	if !f.olderThan.IsZero() && info.ModTime().After(f.olderThan) {
+		return skipByAge
+	}
+	return keepEntry
+}
+
+// includeDirs 是否备份目录条目本身
+func (f *fileFilter) includeDirs() bool {
+	return slices.Contains(f.types, fileTypeDir)
+}
+
+// add 记录跳过的条目和原因
+func (s *skipStats) add(isDir bool, reason skipReason) {
+	if isDir {
+		s.dirs.Add(1)
+	} else {
+		s.files.Add(1)
+	}
+	switch reason {
+	case skipBySize:
+		s.bySize.Add(1)
+	case skipByAge:
+		s.byAge.Add(1)
+	case skipByType:
+		s.byType.Add(1)
+	case skipByMount:
+		s.mounts.Add(1)
+	}
+}
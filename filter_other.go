@@ -0,0 +1,10 @@
+//go:build !unix
+
+package main
+
+import "io/fs"
+
+// deviceID 当前系统无法获取设备号，所有文件视为位于同一文件系统，one_file_system 不生效
+func deviceID(info fs.FileInfo) uint64 {
+	return 0
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+)
+
+func Test_walkBackupPathFilters(t *testing.T) {
+	root := t.TempDir()
+	now := time.Now()
+	files := []struct {
+		name    string
+		size    int
+		modTime time.Time
+	}{
+		{name: "small.txt", size: 10, modTime: now},
+		{name: "large.bin", size: 4096, modTime: now},
+		{name: "old.log", size: 100, modTime: now.Add(-30 * 24 * time.Hour)},
+		{name: "empty/.keep", size: 0, modTime: now},
+	}
+	for _, f := range files {
+		path := filepath.Join(root, f.name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, make([]byte, f.size), 0644)
+		os.Chtimes(path, f.modTime, f.modTime)
+	}
+	os.Symlink("small.txt", filepath.Join(root, "link.txt"))
+	os.Symlink("empty", filepath.Join(root, "dirlink"))
+
+	tests := []struct {
+		name string
+		cfg  Config
+		want []string
+	}{
+		{
+			name: "default keeps regular files and file symlinks",
+			cfg:  Config{},
+			want: []string{"empty/.keep", "large.bin", "link.txt", "old.log", "small.txt"},
+		},
+		{
+			name: "max and min size",
+			cfg:  Config{MaxFileSize: "1K", MinFileSize: "1"},
+			want: []string{"link.txt", "old.log", "small.txt"},
+		},
+		{
+			name: "modified within",
+			cfg:  Config{ModifiedWithin: "7d"},
+			want: []string{"empty/.keep", "large.bin", "link.txt", "small.txt"},
+		},
+		{
+			name: "older than",
+			cfg:  Config{OlderThan: "1w"},
+			want: []string{"old.log"},
+		},
+		{
+			name: "regular files and dirs only",
+			cfg:  Config{FileTypes: []string{fileTypeRegular, fileTypeDir}},
+			want: []string{"", "empty", "empty/.keep", "large.bin", "old.log", "small.txt"},
+		},
+		{
+			name: "one file system",
+			cfg:  Config{OneFileSystem: true, MaxFileSize: "1K"},
+			want: []string{"empty/.keep", "link.txt", "old.log", "small.txt"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			err := walkBackupPath(&tt.cfg, BackupPath{Path: root}, nil, nil, func(absPath, relPath string, isDir bool) error {
+				rel, _ := filepath.Rel(root, absPath)
+				if rel == "." {
+					rel = ""
+				}
+				got = append(got, filepath.ToSlash(rel))
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("walkBackupPath() error = %v", err)
+			}
+			slices.Sort(got)
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("walkBackupPath() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_newFileFilter_invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  Config
+	}{
+		{name: "bad type", cfg: Config{FileTypes: []string{"socket"}}},
+		{name: "bad size", cfg: Config{MaxFileSize: "huge"}},
+		{name: "bad duration", cfg: Config{ModifiedWithin: "soon"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newFileFilter(&tt.cfg, t.TempDir(), time.Now()); err == nil {
+				t.Errorf("newFileFilter() expected error")
+			}
+		})
+	}
+}
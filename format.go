@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// 备份包结构版本：
+//
+//	0  backup_config.yaml + file_map.yaml，数据位于 data/，文件映射为 条目 -> 原路径
+//	1  增加 manifest.json，记录结构版本和备份信息
+//
+// 读取时旧版本的布局升级为当前结构，高于 archiveFormatVersion 的版本直接拒绝。
+
+// archiveMetadata 读取并升级后的备份包元数据
+type archiveMetadata struct {
+	Version  int
+	Manifest *Manifest // 版本 0 没有清单，根据备份包内容补全
+	Config   *Config
+	FileMap  FileMap
+}
+
+// metadataReaders 各结构版本的元数据读取函数，files 为元数据文件内容
+var metadataReaders = map[int]func(ar archiveReader, files map[string][]byte) (*archiveMetadata, error){
+	0: readMetadataV0,
+	1: readMetadataV1,
+}
+
+// readArchiveMetadata 读取备份包元数据，按结构版本选择读取方式
+func readArchiveMetadata(ar archiveReader) (*archiveMetadata, error) {
+	files, err := readArchiveFiles(ar, backupConfigName, backupFileMapName, backupManifestName)
+	if err != nil {
+		return nil, err
+	}
+
+	version, err := archiveVersion(files)
+	if err != nil {
+		return nil, err
+	}
+	read, ok := metadataReaders[version]
+	if !ok {
+		return nil, fmt.Errorf(tr("不支持的备份包结构版本 %d"), version)
+	}
+	return read(ar, files)
+}
+
+// archiveVersion 返回元数据中的结构版本，没有清单的是版本 0，版本过高时返回错误
+func archiveVersion(files map[string][]byte) (int, error) {
+	data, ok := files[backupManifestName]
+	if !ok {
+		return 0, nil
+	}
+
+	// 只解析版本字段，新版本的清单结构可能不同
+	var head struct {
+		FormatVersion int    `json:"format_version"`
+		Version       string `json:"version"`
+	}
+	if err := json.Unmarshal(data, &head); err != nil {
+		return 0, fmt.Errorf(tr("解析备份清单失败 (%s): %w"), backupManifestName, err)
+	}
+	if head.FormatVersion > archiveFormatVersion {
+		return 0, fmt.Errorf(tr("备份包结构版本 %d 高于当前程序支持的版本 %d（由 backtrack %s 创建），请升级 backtrack 后再读取"),
+			head.FormatVersion, archiveFormatVersion, head.Version)
+	}
+	if head.FormatVersion < 1 {
+		return 0, fmt.Errorf(tr("备份清单中的结构版本无效: %d"), head.FormatVersion)
+	}
+	return head.FormatVersion, nil
+}
+
+// readMetadataV0 读取配置和文件映射，补全没有的清单
+func readMetadataV0(ar archiveReader, files map[string][]byte) (*archiveMetadata, error) {
+	var cfg Config
+	var fileMap FileMap
+
+	if err := unmarshalYAMLFile(files, backupConfigName, &cfg); err != nil {
+		return nil, fmt.Errorf(tr("读取 %s 失败: %w"), backupConfigName, err)
+	}
+
+	if err := unmarshalYAMLFile(files, backupFileMapName, &fileMap); err != nil {
+		return nil, fmt.Errorf(tr("读取 %s 失败: %w"), backupFileMapName, err)
+	}
+
+	if len(fileMap) == 0 {
+		return nil, fmt.Errorf(tr("备份文件缺少 %s，无法还原"), backupFileMapName)
+	}
+
+	return &archiveMetadata{
+		Version:  0,
+		Manifest: &Manifest{Format: ar.Format(), Files: int64(len(fileMap))},
+		Config:   &cfg,
+		FileMap:  fileMap,
+	}, nil
+}
+
+// readMetadataV1 在版本 0 的基础上读取清单
+func readMetadataV1(ar archiveReader, files map[string][]byte) (*archiveMetadata, error) {
+	md, err := readMetadataV0(ar, files)
+	if err != nil {
+		return nil, err
+	}
+
+	var m Manifest
+	if err := json.Unmarshal(files[backupManifestName], &m); err != nil {
+		return nil, fmt.Errorf(tr("解析备份清单失败 (%s): %w"), backupManifestName, err)
+	}
+	md.Version = 1
+	md.Manifest = &m
+	return md, nil
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// testdata/format 下保存各结构版本的备份包，新版本程序必须能继续读取
+func Test_readArchiveMetadata(t *testing.T) {
+	wantMap := FileMap{
+		"data/data1/data5.txt": "/testdata/backup/data1/data5.txt",
+		"data/data3.txt":       "/testdata/backup/data3.txt",
+	}
+	tests := []struct {
+		path        string
+		wantVersion int
+		wantFormat  archiveFormat
+	}{
+		{path: "v0.zip", wantVersion: 0, wantFormat: formatZip},
+		{path: "v1.zip", wantVersion: 1, wantFormat: formatZip},
+		{path: "v1.tar.zst", wantVersion: 1, wantFormat: formatTarZst},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			path := filepath.Join("testdata/format", tt.path)
+			ar, err := openArchive(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			md, err := readArchiveMetadata(ar)
+			ar.Close()
+			if err != nil {
+				t.Fatalf("readArchiveMetadata() error = %v", err)
+			}
+
+			if md.Version != tt.wantVersion || md.Manifest.FormatVersion != tt.wantVersion || md.Manifest.Format != tt.wantFormat {
+				t.Errorf("version = %d manifest = %+v, want %d %s", md.Version, md.Manifest, tt.wantVersion, tt.wantFormat)
+			}
+			if md.Manifest.Files != int64(len(wantMap)) {
+				t.Errorf("manifest files = %d, want %d", md.Manifest.Files, len(wantMap))
+			}
+			if !reflect.DeepEqual(md.FileMap, wantMap) {
+				t.Errorf("file map = %v, want %v", md.FileMap, wantMap)
+			}
+			if md.Config.AfterScript == "" || len(md.Config.BackupPaths) != 2 {
+				t.Errorf("config = %+v", md.Config)
+			}
+
+			rootDir := t.TempDir()
+			if err := restore(t.Context(), path, restoreOptions{RootDir: rootDir, Quiet: true}); err != nil {
+				t.Fatalf("restore() error = %v", err)
+			}
+			if data, err := os.ReadFile(filepath.Join(rootDir, "testdata/backup/data3.txt")); err != nil || string(data) != "test3" {
+				t.Errorf("restored data3.txt = %q, %v", data, err)
+			}
+		})
+	}
+}
+
+func Test_readArchiveMetadata_futureVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "future.zip")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	for name, content := range map[string]string{
+		backupConfigName:   "backup_paths: [/etc]\n",
+		backupFileMapName:  "data/etc: /etc\n",
+		backupManifestName: `{"format_version": 99, "version": "v9.0.0", "layout": "new"}`,
+	} {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	f.Close()
+
+	ar, err := openArchive(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+
+	_, err = readArchiveMetadata(ar)
+	if err == nil || !strings.Contains(err.Error(), "99") || !strings.Contains(err.Error(), "v9.0.0") {
+		t.Fatalf("readArchiveMetadata() error = %v, want unsupported version", err)
+	}
+	if err := validateArchiveConfig(path); err == nil {
+		t.Errorf("validateArchiveConfig() should reject future version")
+	}
+	if err := restore(t.Context(), path, restoreOptions{RootDir: t.TempDir(), Quiet: true}); err == nil {
+		t.Errorf("restore() should reject future version")
+	}
+	if _, err := getScriptFromBackup(path, "before"); err == nil {
+		t.Errorf("getScriptFromBackup() should reject future version")
+	}
+	if err := exportConfigFromBackup(path, backupConfigName, filepath.Join(t.TempDir(), "out.yaml")); err == nil {
+		t.Errorf("exportConfigFromBackup() should reject future version")
+	}
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// language 界面语言
+type language string
+
+const (
+	langZh language = "zh"
+	langEn language = "en"
+)
+
+// catalogs 各语言的消息目录，以中文原文为键，中文不需要目录
+var catalogs = map[language]map[string]string{
+	langEn: messagesEn,
+}
+
+// currentLang 当前语言，在命令运行前设置
+var currentLang = langZh
+
+// tr 返回消息在当前语言中的文本，没有翻译时返回中文原文。
+// 格式化字符串翻译后占位符的顺序不变，%w 包装的错误仍可用 errors.Is 判断
+func tr(msg string) string {
+	if s, ok := catalogs[currentLang][msg]; ok {
+		return s
+	}
+	return msg
+}
+
+// i18nError 以消息为内容的错误，输出时翻译，用于包级变量定义的错误
+type i18nError string
+
+func (e i18nError) Error() string {
+	return tr(string(e))
+}
+
+// parseLanguage 解析语言名称，接受 zh、en 以及 zh_CN.UTF-8 这样的 locale
+func parseLanguage(s string) (language, error) {
+	s = strings.ToLower(s)
+	switch {
+	case strings.HasPrefix(s, "zh"):
+		return langZh, nil
+	case strings.HasPrefix(s, "en"):
+		return langEn, nil
+	default:
+		return "", fmt.Errorf(tr("不支持的语言 %q，可选 zh、en"), s)
+	}
+}
+
+// detectLanguage 按 --lang、LC_ALL、LC_MESSAGES、LANG 的顺序选择语言。
+// 环境变量未设置或为 C、POSIX 时使用中文，其他不支持的 locale 使用英文
+func detectLanguage(args []string, getenv func(string) string) language {
+	if lang, err := parseLanguage(langFromArgs(args)); err == nil {
+		return lang
+	}
+	for _, name := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		v := getenv(name)
+		if v == "" {
+			continue
+		}
+		if base, _, _ := strings.Cut(v, "."); base == "C" || base == "POSIX" {
+			return langZh
+		}
+		if lang, err := parseLanguage(v); err == nil {
+			return lang
+		}
+		return langEn
+	}
+	return langZh
+}
+
+// langFromArgs 在解析参数前找出 --lang 的值，帮助信息也需要按语言输出
+func langFromArgs(args []string) string {
+	for i, a := range args {
+		if a == "--" {
+			break
+		}
+		if v, ok := strings.CutPrefix(a, "--lang="); ok {
+			return v
+		}
+		if a == "--lang" && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
+
+// setLanguage 设置当前语言并翻译命令的说明和参数说明
+func setLanguage(lang language) {
+	currentLang = lang
+	localizeCommand(rootCmd)
+}
+
+// localizeCommand 翻译命令及其子命令的说明、参数说明和参数默认值
+func localizeCommand(cmd *cobra.Command) {
+	cmd.Short, cmd.Long = tr(cmd.Short), tr(cmd.Long)
+	localizeFlag := func(f *pflag.Flag) {
+		f.Usage, f.DefValue = tr(f.Usage), tr(f.DefValue)
+	}
+	cmd.Flags().VisitAll(localizeFlag)
+	cmd.PersistentFlags().VisitAll(localizeFlag)
+	for _, c := range cmd.Commands() {
+		localizeCommand(c)
+	}
+}
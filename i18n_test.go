@@ -0,0 +1,207 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"unicode"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// useLanguage 在测试期间切换语言
+func useLanguage(t *testing.T, lang language) {
+	prev := currentLang
+	currentLang = lang
+	t.Cleanup(func() { currentLang = prev })
+}
+
+func hasHan(s string) bool {
+	return strings.ContainsFunc(s, func(r rune) bool { return unicode.Is(unicode.Han, r) })
+}
+
+func Test_parseLanguage(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    language
+		wantErr bool
+	}{
+		{in: "zh", want: langZh},
+		{in: "zh_CN.UTF-8", want: langZh},
+		{in: "en", want: langEn},
+		{in: "en_US.UTF-8", want: langEn},
+		{in: "EN", want: langEn},
+		{in: "fr", wantErr: true},
+		{in: "", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := parseLanguage(tt.in)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseLanguage() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseLanguage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_detectLanguage(t *testing.T) {
+	tests := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want language
+	}{
+		{name: "default", want: langZh},
+		{name: "flag", args: []string{"backup", "--lang", "en"}, env: map[string]string{"LANG": "zh_CN.UTF-8"}, want: langEn},
+		{name: "flag with equals", args: []string{"--lang=zh", "info"}, env: map[string]string{"LANG": "en_US.UTF-8"}, want: langZh},
+		{name: "flag after terminator", args: []string{"--", "--lang", "en"}, want: langZh},
+		{name: "invalid flag uses env", args: []string{"--lang", "fr"}, env: map[string]string{"LANG": "en_US.UTF-8"}, want: langEn},
+		{name: "LANG", env: map[string]string{"LANG": "en_US.UTF-8"}, want: langEn},
+		{name: "LC_ALL wins", env: map[string]string{"LC_ALL": "zh_CN.UTF-8", "LANG": "en_US.UTF-8"}, want: langZh},
+		{name: "LC_MESSAGES", env: map[string]string{"LC_MESSAGES": "en_GB", "LANG": "zh_CN.UTF-8"}, want: langEn},
+		{name: "C locale", env: map[string]string{"LANG": "C.UTF-8"}, want: langZh},
+		{name: "other locale", env: map[string]string{"LANG": "de_DE.UTF-8"}, want: langEn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			getenv := func(k string) string { return tt.env[k] }
+			if got := detectLanguage(tt.args, getenv); got != tt.want {
+				t.Errorf("detectLanguage() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_tr(t *testing.T) {
+	const msg = "打开备份文件失败 (%s): %w"
+	tests := []struct {
+		lang language
+		want string
+	}{
+		{lang: langZh, want: "打开备份文件失败 (a.zip): file does not exist"},
+		{lang: langEn, want: "failed to open archive (a.zip): file does not exist"},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.lang), func(t *testing.T) {
+			useLanguage(t, tt.lang)
+
+			err := fmt.Errorf(tr(msg), "a.zip", os.ErrNotExist)
+			if err.Error() != tt.want {
+				t.Errorf("error = %q, want %q", err, tt.want)
+			}
+			if !errors.Is(err, os.ErrNotExist) {
+				t.Errorf("errors.Is(err, os.ErrNotExist) = false")
+			}
+
+			locked := fmt.Errorf(tr("任务 %s: %w"), "etc", errJobLocked)
+			if !errors.Is(locked, errJobLocked) {
+				t.Errorf("errors.Is(err, errJobLocked) = false")
+			}
+			if hasHan(errJobLocked.Error()) != (tt.lang == langZh) {
+				t.Errorf("errJobLocked.Error() = %q in %s", errJobLocked.Error(), tt.lang)
+			}
+		})
+	}
+}
+
+// sourceMessages 返回源文件中 tr 和 i18nError 的字符串参数
+func sourceMessages(t *testing.T) map[string]token.Position {
+	t.Helper()
+	files, err := filepath.Glob("*.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fset := token.NewFileSet()
+	msgs := make(map[string]token.Position)
+	for _, name := range files {
+		if strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		f, err := parser.ParseFile(fset, name, nil, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ast.Inspect(f, func(n ast.Node) bool {
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) != 1 {
+				return true
+			}
+			if id, ok := call.Fun.(*ast.Ident); !ok || (id.Name != "tr" && id.Name != "i18nError") {
+				return true
+			}
+			lit, ok := call.Args[0].(*ast.BasicLit)
+			if !ok || lit.Kind != token.STRING {
+				return true
+			}
+			s, err := strconv.Unquote(lit.Value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			msgs[s] = fset.Position(lit.Pos())
+			return true
+		})
+	}
+	return msgs
+}
+
+// commandMessages 返回命令说明、参数说明和参数默认值中的中文文本
+func commandMessages(cmd *cobra.Command) []string {
+	var msgs []string
+	add := func(s string) {
+		if hasHan(s) {
+			msgs = append(msgs, s)
+		}
+	}
+	add(cmd.Short)
+	add(cmd.Long)
+	visit := func(f *pflag.Flag) {
+		add(f.Usage)
+		add(f.DefValue)
+	}
+	cmd.Flags().VisitAll(visit)
+	cmd.PersistentFlags().VisitAll(visit)
+	for _, c := range cmd.Commands() {
+		msgs = append(msgs, commandMessages(c)...)
+	}
+	return msgs
+}
+
+func Test_messagesEn_complete(t *testing.T) {
+	for msg, pos := range sourceMessages(t) {
+		if _, ok := messagesEn[msg]; !ok && hasHan(msg) {
+			t.Errorf("%s: missing English message for %q", pos, msg)
+		}
+	}
+	for _, msg := range commandMessages(rootCmd) {
+		if _, ok := messagesEn[msg]; !ok {
+			t.Errorf("missing English message for command text %q", msg)
+		}
+	}
+}
+
+// formatVerb 匹配格式化占位符
+var formatVerb = regexp.MustCompile(`%[-+# 0-9.]*[a-zA-Z%]`)
+
+func Test_messagesEn_verbs(t *testing.T) {
+	for zh, en := range messagesEn {
+		if want, got := formatVerb.FindAllString(zh, -1), formatVerb.FindAllString(en, -1); !slices.Equal(want, got) {
+			t.Errorf("verbs of %q = %v, want %v", en, got, want)
+		}
+		if hasHan(en) {
+			t.Errorf("English message for %q contains Chinese: %q", zh, en)
+		}
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// ignoreFileName 遍历目录时读取的排除规则文件，语法同 .gitignore
+const ignoreFileName = ".backtrackignore"
+
+// ignoreRule 一条排除规则
+type ignoreRule struct {
+	base     string   // 规则生效的目录（绝对路径）
+	segments []string // 按 / 切分的模式
+	negate   bool     // ! 开头，重新包含
+	dirOnly  bool     // / 结尾，只匹配目录
+	anchored bool     // 相对 base 锚定，否则可在任意深度匹配
+}
+
+// ignoreRules 有序规则列表，后面匹配的规则优先
+type ignoreRules []ignoreRule
+
+// parseIgnoreRule 解析一行规则，空行和注释返回 ok=false。
+// gitignore 为 true 时含有 / 的模式相对 base 锚定，否则只有以 / 开头的模式锚定。
+func parseIgnoreRule(base, text string, gitignore bool) (rule ignoreRule, ok bool, err error) {
+	line := strings.TrimRight(text, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignoreRule{}, false, nil
+	}
+
+	rule.base = base
+	if strings.HasPrefix(line, "!") {
+		rule.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\!`) || strings.HasPrefix(line, `\#`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		rule.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.HasPrefix(line, "/") {
+		rule.anchored = true
+		line = strings.TrimLeft(line, "/")
+	} else if gitignore && strings.Contains(line, "/") {
+		rule.anchored = true
+	}
+	if line == "" {
+		return ignoreRule{}, false, fmt.Errorf(tr("无效的排除规则: %q"), text)
+	}
+
+	rule.segments = strings.Split(line, "/")
+	for _, seg := range rule.segments {
+		if _, err := path.Match(seg, ""); err != nil {
+			return ignoreRule{}, false, fmt.Errorf(tr("无效的排除规则 %q: %w"), text, err)
+		}
+	}
+	return rule, true, nil
+}
+
+// match 检查绝对路径是否匹配该规则
+func (r *ignoreRule) match(absPath string, isDir bool) bool {
+	if r.dirOnly && !isDir {
+		return false
+	}
+
+	rel, err := filepath.Rel(r.base, absPath)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return false
+	}
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+
+	if r.anchored {
+		return matchSegments(r.segments, parts)
+	}
+	for i := range parts {
+		if matchSegments(r.segments, parts[i:]) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchSegments 逐段匹配，** 匹配零个或多个目录
+func matchSegments(pattern, parts []string) bool {
+	if len(pattern) == 0 {
+		return len(parts) == 0
+	}
+	if pattern[0] == "**" {
+		for i := 0; i <= len(parts); i++ {
+			if matchSegments(pattern[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(parts) == 0 {
+		return false
+	}
+	if ok, _ := path.Match(pattern[0], parts[0]); !ok {
+		return false
+	}
+	return matchSegments(pattern[1:], parts[1:])
+}
+
+// excluded 返回路径是否被排除，由最后一条匹配的规则决定
+func (rs ignoreRules) excluded(absPath string, isDir bool) bool {
+	for i := len(rs) - 1; i >= 0; i-- {
+		if rs[i].match(absPath, isDir) {
+			return !rs[i].negate
+		}
+	}
+	return false
+}
+
+// parseIgnoreRules 解析多条规则
+func parseIgnoreRules(base string, lines []string, gitignore bool) (ignoreRules, error) {
+	var rules ignoreRules
+	for _, line := range lines {
+		rule, ok, err := parseIgnoreRule(base, line, gitignore)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			rules = append(rules, rule)
+		}
+	}
+	return rules, nil
+}
+
+// readIgnoreFile 读取目录中的 .backtrackignore，文件不存在时返回 nil。
+// srcDir 为实际读取的目录，使用快照时为快照中的目录，规则仍相对 dir 匹配
+func readIgnoreFile(dir, srcDir string) (ignoreRules, error) {
+	f, err := os.Open(filepath.Join(srcDir, ignoreFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	rules, err := parseIgnoreRules(dir, lines, true)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", filepath.Join(dir, ignoreFileName), err)
+	}
+	return rules, nil
+}
+
+// globalIgnoreRules 将旧的 exclude_dirs/exclude_files 和 rules 转换为全局规则
+func globalIgnoreRules(cfg *Config) (ignoreRules, error) {
+	lines := make([]string, 0, len(cfg.ExcludeDirs)+len(cfg.ExcludeFiles)+len(cfg.Rules))
+	for _, dir := range cfg.ExcludeDirs {
+		lines = append(lines, globEscape(dir)+"/")
+	}
+	for _, pattern := range cfg.ExcludeFiles {
+		lines = append(lines, pattern)
+	}
+	lines = append(lines, cfg.Rules...)
+
+	return parseIgnoreRules(string(filepath.Separator), lines, false)
+}
+
+// rootIgnoreRules 返回备份路径根目录生效的规则：全局规则加上该路径的 path_rules 和 excludes
+func rootIgnoreRules(cfg *Config, bp BackupPath) (ignoreRules, error) {
+	rules, err := globalIgnoreRules(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	absRoot, err := filepath.Abs(bp.Path)
+	if err != nil {
+		return nil, err
+	}
+	for p, lines := range cfg.PathRules {
+		absPath, err := filepath.Abs(p)
+		if err != nil || absPath != absRoot {
+			continue
+		}
+		pathRules, err := parseIgnoreRules(absRoot, lines, true)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, pathRules...)
+	}
+
+	excludes, err := parseIgnoreRules(absRoot, bp.Excludes, true)
+	if err != nil {
+		return nil, err
+	}
+	return append(rules, excludes...), nil
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func Test_ignoreRules_excluded(t *testing.T) {
+	type args struct {
+		path  string
+		isDir bool
+	}
+	tests := []struct {
+		name      string
+		base      string
+		lines     []string
+		gitignore bool
+		args      args
+		want      bool
+	}{
+		{
+			name:  "base name at any depth",
+			base:  "/",
+			lines: []string{"*.log"},
+			args:  args{path: "/var/log/app/error.log"},
+			want:  true,
+		},
+		{
+			name:  "negation re-includes",
+			base:  "/",
+			lines: []string{"*.log", "!important.log"},
+			args:  args{path: "/var/log/important.log"},
+			want:  false,
+		},
+		{
+			name:  "absolute anchored dir",
+			base:  "/",
+			lines: []string{"/var/lib/app/cache/"},
+			args:  args{path: "/var/lib/app/cache", isDir: true},
+			want:  true,
+		},
+		{
+			name:  "absolute anchored does not match other cache",
+			base:  "/",
+			lines: []string{"/var/lib/app/cache/"},
+			args:  args{path: "/var/lib/other/cache", isDir: true},
+			want:  false,
+		},
+		{
+			name:  "dir only rule skips files",
+			base:  "/",
+			lines: []string{"cache/"},
+			args:  args{path: "/srv/cache"},
+			want:  false,
+		},
+		{
+			name:  "double star",
+			base:  "/",
+			lines: []string{"/home/**/.cache"},
+			args:  args{path: "/home/alice/.config/app/.cache", isDir: true},
+			want:  true,
+		},
+		{
+			name:      "gitignore slash anchors to base",
+			base:      "/srv/app",
+			lines:     []string{"build/tmp"},
+			gitignore: true,
+			args:      args{path: "/srv/app/lib/build/tmp", isDir: true},
+			want:      false,
+		},
+		{
+			name:      "gitignore anchored match",
+			base:      "/srv/app",
+			lines:     []string{"build/tmp"},
+			gitignore: true,
+			args:      args{path: "/srv/app/build/tmp", isDir: true},
+			want:      true,
+		},
+		{
+			name:      "outside base",
+			base:      "/srv/app",
+			lines:     []string{"*.tmp"},
+			gitignore: true,
+			args:      args{path: "/srv/other/a.tmp"},
+			want:      false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules, err := parseIgnoreRules(tt.base, tt.lines, tt.gitignore)
+			if err != nil {
+				t.Fatalf("parseIgnoreRules() error = %v", err)
+			}
+			if got := rules.excluded(tt.args.path, tt.args.isDir); got != tt.want {
+				t.Errorf("excluded() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseIgnoreRule_invalid(t *testing.T) {
+	if _, err := parseIgnoreRules("/", []string{"[a-"}, false); err == nil {
+		t.Errorf("parseIgnoreRules() expected error for malformed pattern")
+	}
+}
+
+func Test_walkBackupPath(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{
+		"app/cache/a.bin",
+		"app/logs/keep.log",
+		"app/logs/debug.log",
+		"app/sub/.backtrackignore",
+		"app/sub/secret.key",
+		"app/sub/data.txt",
+	} {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(name), 0644)
+	}
+	os.WriteFile(filepath.Join(root, "app/sub/.backtrackignore"), []byte("# 私钥\n*.key\n"), 0644)
+
+	appDir := filepath.Join(root, "app")
+	cfg := &Config{
+		Rules:     []string{"*.log", "!keep.log"},
+		PathRules: map[string][]string{appDir: {"/cache/"}},
+	}
+
+	var got []string
+	err := walkBackupPath(cfg, BackupPath{Path: appDir}, nil, nil, func(absPath, relPath string, isDir bool) error {
+		got = append(got, filepath.ToSlash(relPath))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walkBackupPath() error = %v", err)
+	}
+
+	want := []string{"data/app/logs/keep.log", "data/app/sub/.backtrackignore", "data/app/sub/data.txt"}
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("walkBackupPath() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	logFormatText = "text"
+	logFormatJSON = "json"
+)
+
+// logOptions 日志选项
+type logOptions struct {
+	Level  slog.Level
+	Format string // text 或 json
+	File   string // 为空时写入标准错误
+	Quiet  bool   // 写入标准错误时只输出错误
+}
+
+// logOutput 当前日志的输出位置，写入终端时 TUI 运行期间需要暂存
+var logOutput struct {
+	opts     logOptions
+	w        io.Writer
+	terminal bool
+}
+
+// setupLoggingFromFlags 根据全局参数设置日志，在每个命令运行前调用
+func setupLoggingFromFlags(cmd *cobra.Command, args []string) error {
+	levelName, _ := cmd.Flags().GetString("log-level")
+	format, _ := cmd.Flags().GetString("log-format")
+	file, _ := cmd.Flags().GetString("log-file")
+	quiet, _ := cmd.Flags().GetBool("quiet")
+
+	level, err := parseLogLevel(levelName)
+	if err != nil {
+		return err
+	}
+	return setupLogging(logOptions{Level: level, Format: format, File: file, Quiet: quiet})
+}
+
+// parseLogLevel 解析日志级别 debug、info、warn、error
+func parseLogLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return 0, fmt.Errorf(tr("无效的日志级别 %q，可选 debug、info、warn、error"), s)
+	}
+	return level, nil
+}
+
+// setupLogging 创建日志处理器并设为默认，日志文件以追加方式打开，程序退出时关闭
+func setupLogging(opts logOptions) error {
+	w, terminal := io.Writer(os.Stderr), true
+	if opts.File != "" {
+		f, err := os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+		if err != nil {
+			return fmt.Errorf(tr("打开日志文件失败 (%s): %w"), opts.File, err)
+		}
+		w, terminal = f, false
+	}
+
+	h, err := newLogHandler(w, opts, terminal)
+	if err != nil {
+		return err
+	}
+	logOutput.opts, logOutput.w, logOutput.terminal = opts, w, terminal
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// newLogHandler 按格式创建处理器，静默模式下终端只输出错误
+func newLogHandler(w io.Writer, opts logOptions, terminal bool) (slog.Handler, error) {
+	level := opts.Level
+	if opts.Quiet && terminal {
+		level = max(level, slog.LevelError)
+	}
+	ho := &slog.HandlerOptions{Level: level}
+
+	switch strings.ToLower(opts.Format) {
+	case "", logFormatText:
+		return slog.NewTextHandler(w, ho), nil
+	case logFormatJSON:
+		return slog.NewJSONHandler(w, ho), nil
+	default:
+		return nil, fmt.Errorf(tr("无效的日志格式 %q，可选 text、json"), opts.Format)
+	}
+}
+
+// bufferTerminalLogs 日志写入终端时暂存到缓冲区，避免打乱 TUI 界面；
+// 返回的函数恢复日志输出并写出暂存的日志。日志写入文件时不做处理
+func bufferTerminalLogs() (restore func()) {
+	if logOutput.w != nil && !logOutput.terminal {
+		return func() {}
+	}
+
+	prev := slog.Default()
+	var buf bytes.Buffer
+	h, err := newLogHandler(&buf, logOutput.opts, true)
+	if err != nil {
+		return func() {}
+	}
+	slog.SetDefault(slog.New(h))
+	return func() {
+		slog.SetDefault(prev)
+		os.Stderr.Write(buf.Bytes())
+	}
+}
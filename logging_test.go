@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_parseLogLevel(t *testing.T) {
+	tests := []struct {
+		s       string
+		want    slog.Level
+		wantErr bool
+	}{
+		{s: "debug", want: slog.LevelDebug},
+		{s: "INFO", want: slog.LevelInfo},
+		{s: "warn", want: slog.LevelWarn},
+		{s: "error", want: slog.LevelError},
+		{s: "verbose", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseLogLevel(tt.s)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseLogLevel(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("parseLogLevel(%q) = %v, want %v", tt.s, got, tt.want)
+		}
+	}
+}
+
+func Test_newLogHandler(t *testing.T) {
+	tests := []struct {
+		name     string
+		opts     logOptions
+		terminal bool
+		enabled  []slog.Level
+		disabled []slog.Level
+		wantErr  bool
+	}{
+		{name: "info", opts: logOptions{Level: slog.LevelInfo}, terminal: true, enabled: []slog.Level{slog.LevelInfo}, disabled: []slog.Level{slog.LevelDebug}},
+		{name: "quiet terminal", opts: logOptions{Level: slog.LevelDebug, Quiet: true}, terminal: true, enabled: []slog.Level{slog.LevelError}, disabled: []slog.Level{slog.LevelInfo, slog.LevelWarn}},
+		{name: "quiet file", opts: logOptions{Level: slog.LevelInfo, Quiet: true}, terminal: false, enabled: []slog.Level{slog.LevelInfo}},
+		{name: "json", opts: logOptions{Format: logFormatJSON}, terminal: true, enabled: []slog.Level{slog.LevelInfo}},
+		{name: "unknown format", opts: logOptions{Format: "xml"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, err := newLogHandler(io.Discard, tt.opts, tt.terminal)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newLogHandler() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			for _, l := range tt.enabled {
+				if !h.Enabled(context.Background(), l) {
+					t.Errorf("level %v disabled", l)
+				}
+			}
+			for _, l := range tt.disabled {
+				if h.Enabled(context.Background(), l) {
+					t.Errorf("level %v enabled", l)
+				}
+			}
+		})
+	}
+}
+
+func Test_setupLogging(t *testing.T) {
+	prev := slog.Default()
+	t.Cleanup(func() { slog.SetDefault(prev) })
+
+	path := filepath.Join(t.TempDir(), "backtrack.log")
+	if err := setupLogging(logOptions{Level: slog.LevelInfo, Format: logFormatJSON, File: path, Quiet: true}); err != nil {
+		t.Fatalf("setupLogging() error = %v", err)
+	}
+	slog.Info("备份完成", "output", "/tmp/backup.zip", "files", 2)
+	slog.Debug("跳过", "path", "/tmp/x")
+
+	// TUI 期间写入文件的日志不受影响
+	bufferTerminalLogs()()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var rec map[string]any
+	if err := json.Unmarshal(data, &rec); err != nil {
+		t.Fatalf("log file is not a single JSON record: %v\n%s", err, data)
+	}
+	if rec["msg"] != "备份完成" || rec["level"] != "INFO" || rec["output"] != "/tmp/backup.zip" || rec["files"] != float64(2) {
+		t.Errorf("log record = %v", rec)
+	}
+}
@@ -18,10 +18,8 @@ package main
 
 import (
 	"context"
-	"log"
 	"os"
 	"os/signal"
-	"runtime/debug"
 	"syscall"
 
 	"github.com/spf13/cobra"
@@ -30,20 +28,31 @@ import (
 var rootCmd = &cobra.Command{
 	Use:   "backtrack",
 	Short: "文件备份和还原工具",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkOutputFormat(cmd); err != nil {
+			return err
+		}
+		if lang, _ := cmd.Flags().GetString("lang"); lang != "" {
+			if _, err := parseLanguage(lang); err != nil {
+				return err
+			}
+		}
+		return setupLoggingFromFlags(cmd, args)
+	},
 }
 
 func init() {
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
-
-	debugInfo, _ := debug.ReadBuildInfo()
-
-	rootCmd.Version = debugInfo.Main.Version
-	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "静默模式，不输出日志")
+	rootCmd.Version = backtrackVersion()
+	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "静默模式，不显示进度条，终端只输出错误")
+	rootCmd.PersistentFlags().String("log-level", "info", "日志级别 (debug|info|warn|error)")
+	rootCmd.PersistentFlags().String("log-format", logFormatText, "日志格式 (text|json)")
+	rootCmd.PersistentFlags().String("log-file", "", "日志写入的文件，默认为标准错误")
+	rootCmd.PersistentFlags().String("lang", "", "界面语言 (zh|en)，默认按 LC_ALL、LC_MESSAGES、LANG 环境变量选择")
+	rootCmd.PersistentFlags().String("output-format", outputFormatText, "命令结果的输出格式 (text|json)，json 时向标准输出写入一个结果对象")
 }
 
 func main() {
 	ctx, _ := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
-	if err := rootCmd.ExecuteContext(ctx); err != nil {
-		os.Exit(1)
-	}
+	setLanguage(detectLanguage(os.Args[1:], os.Getenv))
+	os.Exit(execute(ctx, os.Args[1:], os.Stdout))
 }
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// archiveFormatVersion 备份包结构版本，元数据布局不兼容变化时递增
+const archiveFormatVersion = 1
+
+// maxManifestFailures 清单中最多记录的失败条目数
+const maxManifestFailures = 100
+
+// Manifest 备份清单，记录备份的来源、时间和统计信息
+type Manifest struct {
+	FormatVersion int               `json:"format_version"`
+	Version       string            `json:"version"` // backtrack 版本
+	Hostname      string            `json:"hostname"`
+	OS            string            `json:"os"`
+	Arch          string            `json:"arch"`
+	Format        archiveFormat     `json:"format"`
+	StartTime     time.Time         `json:"start_time"`
+	EndTime       time.Time         `json:"end_time"`
+	Files         int64             `json:"files"` // 写入的条目数
+	Bytes         int64             `json:"bytes"` // 写入的源文件字节数
+	SkippedFiles  int64             `json:"skipped_files"`
+	SkippedDirs   int64             `json:"skipped_dirs"`
+	FailedCount   int64             `json:"failed_count"`
+	Failures      []ManifestFailure `json:"failures,omitempty"` // 最多记录 maxManifestFailures 条
+	Tags          map[string]string `json:"tags,omitempty"`
+}
+
+// ManifestFailure 备份失败的文件
+type ManifestFailure struct {
+	Path  string `json:"path"`
+	Error string `json:"error"`
+}
+
+// backtrackVersion 返回构建信息中的版本
+func backtrackVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok {
+		return info.Main.Version
+	}
+	return "(unknown)"
+}
+
+// newManifest 创建备份开始时的清单
+func newManifest(format archiveFormat, tags map[string]string) *Manifest {
+	hostname, _ := os.Hostname()
+	if format == "" {
+		format = formatZip
+	}
+	return &Manifest{
+		FormatVersion: archiveFormatVersion,
+		Version:       backtrackVersion(),
+		Hostname:      hostname,
+		OS:            runtime.GOOS,
+		Arch:          runtime.GOARCH,
+		Format:        format,
+		StartTime:     time.Now(),
+		Tags:          tags,
+	}
+}
+
+// startTimeMagic 备份包头部记录的备份开始时间字段的标记，后跟8字节小端序的 Unix 纳秒时间。
+// 与分卷数量字段写在同一位置，清理旧备份时不需要解压 tar 格式的整个备份包就能读到备份时间
+var startTimeMagic = []byte("BTSTTIME")
+
+// startTimeField 返回记录开始时间 t 的头部字段
+func startTimeField(t time.Time) []byte {
+	return binary.LittleEndian.AppendUint64(bytes.Clone(startTimeMagic), uint64(t.UnixNano()))
+}
+
+// readStartTime 读取备份包（分卷时为第一个分卷）头部记录的开始时间，旧版本创建的备份包没有记录
+func readStartTime(path string) (time.Time, bool) {
+	field := readHeaderField(path, startTimeMagic, 8)
+	if field == nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, int64(binary.LittleEndian.Uint64(field))), true
+}
+
+// addFile 记录写入的条目
+func (m *Manifest) addFile(size int64) {
+	m.Files++
+	m.Bytes += size
+}
+
+// addFailure 记录备份失败的文件
+func (m *Manifest) addFailure(path string, err error) {
+	m.FailedCount++
+	if len(m.Failures) < maxManifestFailures {
+		m.Failures = append(m.Failures, ManifestFailure{Path: path, Error: err.Error()})
+	}
+}
+
+// writeManifestToArchive 补全结束时间和跳过统计后将清单写入备份包
+func writeManifestToArchive(s *backupSession) error {
+	s.mu.Lock()
+	m := s.manifest
+	m.EndTime = time.Now()
+	m.SkippedFiles = s.skipped.files.Load()
+	m.SkippedDirs = s.skipped.dirs.Load()
+	data, err := json.MarshalIndent(m, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf(tr("序列化备份清单失败: %w"), err)
+	}
+	return writeArchiveFile(s.aw, backupManifestName, append(data, '\n'), &s.mu)
+}
+
+// readManifest 读取备份包中的清单，版本 0 的备份包没有清单，只包含格式和条目数
+func readManifest(ar archiveReader) (*Manifest, error) {
+	md, err := readArchiveMetadata(ar)
+	if err != nil {
+		return nil, err
+	}
+	return md.Manifest, nil
+}
+
+// parseTags 解析 key=value 形式的标签
+func parseTags(values []string) (map[string]string, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	tags := make(map[string]string, len(values))
+	for _, v := range values {
+		key, value, ok := strings.Cut(v, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf(tr("无效的标签 %q，应为 key=value"), v)
+		}
+		tags[strings.TrimSpace(key)] = value
+	}
+	return tags, nil
+}
+
+var infoCmd = &cobra.Command{
+	Use:   "info [backup]",
+	Short: "显示备份包的清单信息",
+	Args:  cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		inputPath, _ := cmd.Flags().GetString("input")
+		if inputPath == "" && len(args) > 0 {
+			inputPath = args[0]
+		}
+		if inputPath == "" {
+			return errors.New(tr("必须提供备份文件路径"))
+		}
+
+		ar, err := openArchive(inputPath)
+		if err != nil {
+			cmd.SilenceUsage = true
+			return err
+		}
+		defer ar.Close()
+
+		m, err := readManifest(ar)
+		if err != nil {
+			cmd.SilenceUsage = true
+			return err
+		}
+		result.Input, result.Manifest = inputPath, m
+		printManifest(textOutput(cmd), m)
+		return nil
+	},
+}
+
+func init() {
+	infoCmd.Flags().StringP("input", "i", "", "备份文件路径")
+
+	rootCmd.AddCommand(infoCmd)
+}
+
+// printManifest 输出清单信息
+func printManifest(w io.Writer, m *Manifest) {
+	fmt.Fprintf(w, tr("格式版本:   %d (%s)\n"), m.FormatVersion, m.Format)
+	if m.FormatVersion == 0 {
+		fmt.Fprintf(w, tr("条目:       %d 个\n"), m.Files)
+		fmt.Fprintln(w, tr("旧版本创建的备份包没有清单，无法显示更多信息"))
+		return
+	}
+	fmt.Fprintf(w, tr("程序版本:   %s\n"), m.Version)
+	fmt.Fprintf(w, tr("主机:       %s (%s/%s)\n"), m.Hostname, m.OS, m.Arch)
+	fmt.Fprintf(w, tr("开始时间:   %s\n"), m.StartTime.Format(time.DateTime))
+	fmt.Fprintf(w, tr("结束时间:   %s\n"), m.EndTime.Format(time.DateTime))
+	fmt.Fprintf(w, tr("耗时:       %s\n"), m.EndTime.Sub(m.StartTime).Round(time.Millisecond))
+	fmt.Fprintf(w, tr("条目:       %d 个，%s\n"), m.Files, formatSize(m.Bytes))
+	fmt.Fprintf(w, tr("跳过:       %d 个文件 %d 个文件夹\n"), m.SkippedFiles, m.SkippedDirs)
+	fmt.Fprintf(w, tr("失败:       %d 个\n"), m.FailedCount)
+	for _, f := range m.Failures {
+		fmt.Fprintf(w, "  %s: %s\n", f.Path, f.Error)
+	}
+	if omitted := m.FailedCount - int64(len(m.Failures)); omitted > 0 {
+		fmt.Fprintf(w, tr("  ... 另有 %d 个未记录\n"), omitted)
+	}
+	if len(m.Tags) > 0 {
+		fmt.Fprintln(w, tr("标签:"))
+		for _, k := range slices.Sorted(maps.Keys(m.Tags)) {
+			fmt.Fprintf(w, "  %s=%s\n", k, m.Tags[k])
+		}
+	}
+}
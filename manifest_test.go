@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"io/fs"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_parseTags(t *testing.T) {
+	tests := []struct {
+		name    string
+		values  []string
+		want    map[string]string
+		wantErr bool
+	}{
+		{name: "empty", values: nil, want: nil},
+		{name: "tags", values: []string{"env=prod", "ticket=OPS-1", "note="}, want: map[string]string{"env": "prod", "ticket": "OPS-1", "note": ""}},
+		{name: "value with equals", values: []string{"q=a=b"}, want: map[string]string{"q": "a=b"}},
+		{name: "missing equals", values: []string{"env"}, wantErr: true},
+		{name: "empty key", values: []string{"=prod"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseTags(tt.values)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseTags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseTags() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_readManifest(t *testing.T) {
+	cfg, configBytes, err := loadConfig("testdata/config.yaml", "")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	archivePath := filepath.Join(t.TempDir(), "backup.tar.zst")
+	opts := backupOptions{Format: formatTarZst, Tags: map[string]string{"env": "test"}, Quiet: true}
+	if err := backup(t.Context(), cfg, configBytes, archivePath, opts); err != nil {
+		t.Fatalf("backup() error = %v", err)
+	}
+
+	ar, err := openArchive(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	m, err := readManifest(ar)
+	if err != nil {
+		t.Fatalf("readManifest() error = %v", err)
+	}
+
+	if m.FormatVersion != archiveFormatVersion || m.Format != formatTarZst || m.Hostname == "" || m.OS == "" {
+		t.Errorf("manifest = %+v", m)
+	}
+	if m.Files != 2 || m.Bytes != 10 || m.SkippedFiles != 1 || m.FailedCount != 0 {
+		t.Errorf("manifest counts = files %d bytes %d skipped %d failed %d, want 2 10 1 0",
+			m.Files, m.Bytes, m.SkippedFiles, m.FailedCount)
+	}
+	if m.EndTime.Before(m.StartTime) {
+		t.Errorf("end time %v before start time %v", m.EndTime, m.StartTime)
+	}
+
+	var out bytes.Buffer
+	printManifest(&out, m)
+	if !strings.Contains(out.String(), "env=test") {
+		t.Errorf("printManifest() = %q, want tags", out.String())
+	}
+}
+
+func Test_Manifest_addFailure(t *testing.T) {
+	m := &Manifest{}
+	for range maxManifestFailures + 5 {
+		m.addFailure("/etc/shadow", fs.ErrPermission)
+	}
+	if m.FailedCount != maxManifestFailures+5 || len(m.Failures) != maxManifestFailures {
+		t.Errorf("FailedCount = %d, len(Failures) = %d", m.FailedCount, len(m.Failures))
+	}
+}
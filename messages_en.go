@@ -0,0 +1,488 @@
+package main
+
+// messagesEn 英文消息目录，格式化字符串中占位符的顺序与中文原文一致
+var messagesEn = map[string]string{
+	// archive.go
+	"不支持的备份格式: %s (可选 zip, tar.zst, tar.gz)": "unsupported archive format: %s (available: zip, tar.zst, tar.gz)",
+	"读取文件头失败: %w":                            "failed to read file header: %w",
+	"无法识别的备份文件格式":                            "unrecognized archive format",
+	"创建zstd压缩器失败: %w":                        "failed to create zstd compressor: %w",
+	"创建gzip压缩器失败: %w":                        "failed to create gzip compressor: %w",
+	"不支持的备份格式: %s":                           "unsupported archive format: %s",
+	"打开备份文件失败 (%s): %w":                      "failed to open archive (%s): %w",
+	"打开备份文件失败 (%s): %w，分卷可能不完整":              "failed to open archive (%s): %w, volumes may be incomplete",
+	"备份文件中未找到 %s":                            "%s not found in archive",
+	"创建zstd解压器失败: %w":                        "failed to create zstd decompressor: %w",
+	"创建gzip解压器失败: %w":                        "failed to create gzip decompressor: %w",
+	"读取备份文件失败 (%s): %w，分卷可能不完整":              "failed to read archive (%s): %w, volumes may be incomplete",
+	"读取备份文件失败 (%s): %w":                      "failed to read archive (%s): %w",
+	"打开条目失败: %w":                             "failed to open entry: %w",
+	"读取条目失败: %w":                             "failed to read entry: %w",
+	"读取 %s 失败: %w":                           "failed to read %s: %w",
+
+	// backup.go
+	"加载配置失败: %w":  "failed to load config: %w",
+	"配置无效:\n%w":   "invalid config:\n%w",
+	"无效的分卷大小: %w": "invalid volume size: %w",
+	"续传需要通过 --output 指定上次中断的备份文件":      "resuming requires --output to point at the interrupted backup",
+	"写入指标文件失败":                         "failed to write metrics file",
+	"备份已中断，已保存检查点，可使用 --resume 继续: %w": "backup interrupted, checkpoint saved, continue with --resume: %w",
+	"写入备份文件失败: %w":                     "failed to write archive: %w",
+	"已写入分卷":                            "volumes written",
+	"备份完成":                             "backup finished",
+	"写入检查点日志失败: %w":                    "failed to write checkpoint log: %w",
+	"创建输出目录失败: %w":                     "failed to create output directory: %w",
+	"创建输出文件失败: %w":                     "failed to create output file: %w",
+	"开始备份":                             "backup started",
+	"正在备份":                             "backing up",
+	"备份 %s":                            "backing up %s",
+	"备份文件失败":                           "failed to back up file",
+	"文件处理失败: %s":                       "failed to process file: %s",
+	"处理备份路径失败":                         "failed to process backup path",
+	"序列化文件映射失败: %w":                    "failed to serialize file map: %w",
+	"获取绝对路径失败 (%s): %w":                "failed to get absolute path (%s): %w",
+	"跳过":                               "skipped",
+	"获取相对路径失败 (%s): %w":                "failed to get relative path (%s): %w",
+	"遍历目录失败 (%s): %w":                  "failed to walk directory (%s): %w",
+	"获取目录信息失败 (%s): %w":                "failed to stat directory (%s): %w",
+	"读取排除规则失败: %w":                     "failed to read exclude rules: %w",
+	"无法访问文件，已跳过":                       "cannot access file, skipped",
+	"必需的备份路径无法访问 (%s): %w":             "required backup path is not accessible (%s): %w",
+	"无法访问路径":                           "cannot access path",
+	"打开文件失败 (%s): %w":                  "failed to open file (%s): %w",
+	"获取文件信息失败 (%s): %w":                "failed to stat file (%s): %w",
+	"创建备份条目失败 (%s): %w":                "failed to create archive entry (%s): %w",
+	"文件在备份期间被修改，备份的内容可能不一致":            "file changed while being backed up, the archived content may be inconsistent",
+	"复制文件内容失败 (%s): %w":                "failed to copy file contents (%s): %w",
+	"读取符号链接失败 (%s): %w":                "failed to read symlink (%s): %w",
+	"写入备份条目失败 (%s): %w":                "failed to write archive entry (%s): %w",
+	"执行备份":                             "Run a backup",
+	"备份配置文件路径":                         "backup config file path",
+	"使用配置文件中的命名配置":                     "use a named profile from the config file",
+	"backup_时间戳.zip":                   "backup_TIMESTAMP.zip",
+	"备份输出路径":                           "backup output path",
+	"备份格式 (zip|tar.zst|tar.gz)":        "archive format (zip|tar.zst|tar.gz)",
+	"从上次中断的检查点继续备份":                    "continue from the checkpoint of an interrupted backup",
+	"分卷大小，如 4G、700M，为空表示不分卷":           "volume size such as 4G or 700M, empty for a single file",
+	"zip条目压缩算法 (deflate|zstd)，覆盖配置文件":  "zip entry compression algorithm (deflate|zstd), overrides the config file",
+	"压缩级别，0 表示算法默认值，覆盖配置文件":            "compression level, 0 for the algorithm default, overrides the config file",
+	"写入备份清单的标签，格式 key=value，可重复指定":     "tag written to the backup manifest as key=value, repeatable",
+	"备份结束后写入 Prometheus 指标的文件，供 node_exporter textfile collector 读取": "file to write Prometheus metrics to after the backup, for the node_exporter textfile collector",
+	"快照目录位于备份路径之内，其中的文件仍从原路径读取":                                      "a snapshot directory is inside the backup path, its files are still read from the original path",
+
+	// browse.go
+	"必须提供备份文件路径":      "a backup file path is required",
+	"还原失败: %v":        "restore failed: %v",
+	"已还原 %d 个条目到 %s":  "restored %d entries to %s",
+	"搜索: ":            "search: ",
+	"还原到: ":           "restore to: ",
+	"读取失败: %v":        "read failed: %v",
+	"二进制文件，大小 %s":     "binary file, size %s",
+	"\n... (仅显示前 %s)": "\n... (showing the first %s only)",
+	"↑/↓ 滚动  esc 返回":  "↑/↓ scroll  esc back",
+	"ctrl+c 取消":       "ctrl+c cancel",
+	"任意键返回  q 退出":     "any key back  q quit",
+	"已选择 %d 项 %s":     "%d selected %s",
+	"  还原到 %s  %d/%d": "  restoring to %s  %d/%d",
+	"备份文件路径":          "backup file path",
+	"默认还原根目录":         "default restore root directory",
+	"↑/↓ 移动  →/enter 展开或预览  ← 收起  空格 标记  / 搜索  c 配置  m 文件映射  r 还原  q 退出": "↑/↓ move  →/enter expand or preview  ← collapse  space mark  / search  c config  m file map  r restore  q quit",
+	"  将还原 %d 项 %s":                     "  restoring %d selected %s",
+	"  备份包中没有还原前后脚本":                    "  the archive has no restore scripts",
+	"  不执行备份包中的还原前后脚本 (--script=false)": "  the archive's restore scripts will not run (--script=false)",
+	"  还原前执行:":                          "  before restore:",
+	"  还原后执行:":                          "  after restore:",
+
+	// checkpoint.go
+	"创建检查点日志失败: %w":      "failed to create checkpoint log: %w",
+	"未找到检查点，重新开始备份":      "no checkpoint found, starting a new backup",
+	"读取检查点日志失败: %w":      "failed to read checkpoint log: %w",
+	"检查点备份包未正常关闭，重新开始备份": "checkpoint archive was not closed cleanly, starting a new backup",
+	"检查点备份包不可用，重新开始备份":   "checkpoint archive is unusable, starting a new backup",
+	"移动检查点日志失败: %w":      "failed to move checkpoint log: %w",
+	"检查点备份包已损坏，重新开始备份":   "checkpoint archive is corrupt, starting a new backup",
+	"复用检查点条目失败 (%s): %w": "failed to reuse checkpoint entry (%s): %w",
+	"从检查点复用条目":           "reused entries from checkpoint",
+
+	// compress.go
+	"deflate 压缩级别必须在 1-9 之间，0 表示默认值: %d": "deflate compression level must be between 1 and 9, 0 for the default: %d",
+	"zstd 压缩级别必须在 1-22 之间，0 表示默认值: %d":   "zstd compression level must be between 1 and 22, 0 for the default: %d",
+	"不支持的压缩算法: %s (可选 deflate, zstd)":    "unsupported compression algorithm: %s (available: deflate, zstd)",
+
+	// config.go
+	"序列化配置失败: %w":                  "failed to serialize config: %w",
+	"写入配置文件失败 (%s): %w":            "failed to write config file (%s): %w",
+	"配置已导出":                        "config exported",
+	"读取配置文件失败 (%s): %w":            "failed to read config file (%s): %w",
+	"%s 没有变化\n":                    "%s has no changes\n",
+	"%s 的变化:\n%s\n":                "changes to %s:\n%s\n",
+	"确认导入?":                        "Import these changes?",
+	"已取消导入":                        "import canceled",
+	"更新备份文件失败: %w":                 "failed to update archive: %w",
+	"配置已导入":                        "config imported",
+	"配置文件无效: %w":                   "invalid config file: %w",
+	"文件映射与备份包条目不一致:\n%w":           "file map does not match the archive entries:\n%w",
+	"不支持更新分卷备份文件: %s":              "updating multi-volume archives is not supported: %s",
+	"打开源备份文件失败: %w":                "failed to open source archive: %w",
+	"创建目标备份文件失败: %w":               "failed to create target archive: %w",
+	"正在更新备份文件...":                  "updating archive...",
+	"更新文件: %s":                     "updating file: %s",
+	"创建备份条目失败: %w":                 "failed to create archive entry: %w",
+	"写入配置文件失败: %w":                 "failed to write config file: %w",
+	"复制文件失败 (%s): %w":              "failed to copy file (%s): %w",
+	"替换备份文件失败: %w":                 "failed to replace archive: %w",
+	"超过 %d 行，请使用 export/import 修改": "more than %d lines, use export/import to edit",
+	"↑/↓ 滚动  e 编辑  esc 返回":         "↑/↓ scroll  e edit  esc back",
+	"重新读取备份包失败: %v":                "failed to reload the archive: %v",
+	"还原时执行备份包中的还原前后脚本":             "run the archive's before and after scripts when restoring",
+	"已放弃修改":                        "changes discarded",
+	"保存失败: ":                       "save failed: ",
+	"已保存":                          "saved",
+	"ctrl+s 保存  esc 放弃修改":          "ctrl+s save  esc discard changes",
+	"浏览备份包，查看和编辑配置，选择性还原":          "Browse an archive, view and edit its configs and restore selected entries",
+	"从备份包导出配置":                     "Export a config from an archive",
+	"导入配置到备份包":                     "Import a config into an archive",
+	"显示解析后的完整配置":                   "Show the fully resolved config",
+	"要查看的配置文件名称(" + backupConfigName + ", " + backupFileMapName + ")": "config file to view (" + backupConfigName + ", " + backupFileMapName + ")",
+	"要导出的配置文件名称(" + backupConfigName + ", " + backupFileMapName + ")": "config file to export (" + backupConfigName + ", " + backupFileMapName + ")",
+	"要替换的配置文件名称(" + backupConfigName + ", " + backupFileMapName + ")": "config file to replace (" + backupConfigName + ", " + backupFileMapName + ")",
+	"导出的配置文件":   "exported config file",
+	"要导入的配置文件":  "config file to import",
+	"强制替换，跳过校验": "replace without validation",
+	"不询问确认":     "do not ask for confirmation",
+	"重写整个zip备份包，清除被替换条目的旧数据": "rewrite the whole zip archive to drop the data of replaced entries",
+
+	// cron.go
+	"无效的调度间隔 %q": "invalid schedule interval %q",
+	"无效的调度 %q: 需要 5 个字段（分 时 日 月 周）": "invalid schedule %q: 5 fields required (minute hour day month weekday)",
+	"无效的调度 %q: %w":             "invalid schedule %q: %w",
+	"%s字段的步长无效: %s":            "invalid step in %s field: %s",
+	"%s字段的范围无效: %s":            "invalid range in %s field: %s",
+	"%s字段的取值无效: %s (范围 %d-%d)": "invalid value in %s field: %s (range %d-%d)",
+	"分钟": "minute",
+	"小时": "hour",
+	"日期": "day of month",
+	"月份": "month",
+	"星期": "day of week",
+
+	// daemon.go
+	"读取定时任务配置失败 (%s): %w":           "failed to read daemon config (%s): %w",
+	"解析定时任务配置失败 (%s):\n%s":          "failed to parse daemon config (%s):\n%s",
+	"定时任务配置无效 (%s): jobs 不能为空":      "invalid daemon config (%s): jobs must not be empty",
+	"任务名称重复: %s":                    "duplicate job name: %s",
+	"任务 %s: %w":                     "job %s: %w",
+	"定时任务配置无效 (%s):\n%w":            "invalid daemon config (%s):\n%w",
+	"name 只能包含字母、数字、_、. 和 -: %q":    "name may only contain letters, digits, _, . and -: %q",
+	"config 不能为空":                   "config must not be empty",
+	"output 不能为空":                   "output must not be empty",
+	"output 中有未知变量 {%s}，可用变量: {%s}": "unknown variable {%s} in output, available: {%s}",
+	"output 必须包含 {time}: %s":        "output must contain {time}: %s",
+	"读取状态文件失败 (%s): %w":             "failed to read state file (%s): %w",
+	"解析状态文件失败 (%s): %w":             "failed to parse state file (%s): %w",
+	"创建状态文件目录失败: %w":                "failed to create state file directory: %w",
+	"写入状态文件失败 (%s): %w":             "failed to write state file (%s): %w",
+	"任务正在运行":                        "job is already running",
+	"创建锁文件目录失败: %w":                 "failed to create lock file directory: %w",
+	"打开锁文件失败 (%s): %w":              "failed to open lock file (%s): %w",
+	"获取锁失败 (%s): %w":                "failed to acquire lock (%s): %w",
+	"已启动指标端点":                       "metrics endpoint started",
+	"已启动定时任务":                       "scheduler started",
+	"定时任务已停止":                       "scheduler stopped",
+	"监听指标端点失败 (%s): %w":             "failed to listen for metrics endpoint (%s): %w",
+	"指标端点已停止":                       "metrics endpoint stopped",
+	"任务没有下一次调度时间":                   "job has no next scheduled time",
+	"更新任务状态失败":                      "failed to update job state",
+	"任务失败":                          "job failed",
+	"任务的上一次运行尚未结束，跳过本次调度":           "previous run of the job has not finished, skipping this run",
+	"任务开始备份":                        "job backup started",
+	"任务备份完成":                        "job backup finished",
+	"任务删除旧备份":                       "job deleted old backup",
+	"任务清理旧备份失败":                     "job failed to prune old backups",
+	"按计划定时执行备份":                     "Run backups on a schedule",
+	"定时任务配置文件路径":                    "daemon config file path",
+	"立即运行所有任务一次后退出":                 "run every job once immediately and exit",
+	"提供 /metrics 端点的监听地址，如 127.0.0.1:9779，覆盖配置文件": "listen address for the /metrics endpoint such as 127.0.0.1:9779, overrides the config file",
+	"每次任务结束后写入 Prometheus 指标的文件，覆盖配置文件":           "file to write Prometheus metrics to after each job, overrides the config file",
+
+	// filter.go
+	"不支持的文件类型: %s (可选 regular, symlink, dir)": "unsupported file type: %s (available: regular, symlink, dir)",
+
+	// format.go
+	"不支持的备份包结构版本 %d":    "unsupported archive format version %d",
+	"解析备份清单失败 (%s): %w": "failed to parse backup manifest (%s): %w",
+	"备份包结构版本 %d 高于当前程序支持的版本 %d（由 backtrack %s 创建），请升级 backtrack 后再读取": "archive format version %d is newer than the supported version %d (created by backtrack %s), upgrade backtrack to read it",
+	"备份清单中的结构版本无效: %d": "invalid format version in backup manifest: %d",
+	"备份文件缺少 %s，无法还原":   "archive is missing %s and cannot be restored",
+
+	// i18n.go
+	"不支持的语言 %q，可选 zh、en": "unsupported language %q, available: zh, en",
+
+	// ignore.go
+	"无效的排除规则: %q":    "invalid exclude rule: %q",
+	"无效的排除规则 %q: %w": "invalid exclude rule %q: %w",
+
+	// logging.go
+	"无效的日志级别 %q，可选 debug、info、warn、error": "invalid log level %q, available: debug, info, warn, error",
+	"打开日志文件失败 (%s): %w":                   "failed to open log file (%s): %w",
+	"无效的日志格式 %q，可选 text、json":             "invalid log format %q, available: text, json",
+
+	// main.go
+	"文件备份和还原工具":                                       "File backup and restore tool",
+	"静默模式，不显示进度条，终端只输出错误":                             "quiet mode, no progress bar and only errors on the terminal",
+	"日志级别 (debug|info|warn|error)":                    "log level (debug|info|warn|error)",
+	"日志格式 (text|json)":                                "log format (text|json)",
+	"日志写入的文件，默认为标准错误":                                 "file to write logs to, standard error by default",
+	"界面语言 (zh|en)，默认按 LC_ALL、LC_MESSAGES、LANG 环境变量选择": "interface language (zh|en), chosen from LC_ALL, LC_MESSAGES and LANG by default",
+	"命令结果的输出格式 (text|json)，json 时向标准输出写入一个结果对象":       "output format of the command result (text|json), json writes one result object to standard output",
+
+	// manifest.go
+	"序列化备份清单失败: %w":              "failed to serialize backup manifest: %w",
+	"无效的标签 %q，应为 key=value":      "invalid tag %q, expected key=value",
+	"格式版本:   %d (%s)\n":          "Format:     %d (%s)\n",
+	"条目:       %d 个\n":           "Entries:    %d\n",
+	"旧版本创建的备份包没有清单，无法显示更多信息":     "archives created by older versions have no manifest, no more details available",
+	"程序版本:   %s\n":               "Version:    %s\n",
+	"主机:       %s (%s/%s)\n":     "Host:       %s (%s/%s)\n",
+	"开始时间:   %s\n":               "Started:    %s\n",
+	"结束时间:   %s\n":               "Finished:   %s\n",
+	"耗时:       %s\n":             "Duration:   %s\n",
+	"条目:       %d 个，%s\n":        "Entries:    %d, %s\n",
+	"跳过:       %d 个文件 %d 个文件夹\n": "Skipped:    %d files %d directories\n",
+	"失败:       %d 个\n":           "Failed:     %d\n",
+	"  ... 另有 %d 个未记录\n":         "  ... %d more not recorded\n",
+	"标签:":                        "Tags:",
+	"显示备份包的清单信息":                 "Show the manifest of an archive",
+
+	// metrics.go
+	"写入指标文件失败 (%s): %w": "failed to write metrics file (%s): %w",
+	"读取指标文件失败 (%s): %w": "failed to read metrics file (%s): %w",
+	"最近一次成功运行的结束时间":     "End time of the last successful run",
+	"最近一次运行的结束时间":       "End time of the last run",
+	"最近一次运行是否成功":        "Whether the last run succeeded",
+	"最近一次运行的耗时":         "Duration of the last run",
+	"最近一次运行写入或还原的条目数":   "Entries written or restored by the last run",
+	"最近一次运行写入或还原的文件字节数": "File bytes written or restored by the last run",
+	"最近一次运行跳过的文件数":      "Files skipped by the last run",
+	"最近一次运行跳过的文件夹数":     "Directories skipped by the last run",
+	"最近一次运行失败的文件数":      "Files that failed in the last run",
+	"最近一次成功备份的备份包大小":    "Archive size of the last successful backup",
+
+	// notify.go
+	defaultNotifyMessage: `{{.Host}} job {{.Job}} {{if eq .Status "ok"}}backup succeeded{{else if eq .Status "partial"}}backup finished, {{.Stats.FailedFiles}} files failed{{else}}backup failed: {{.Error}}{{end}}
+Output: {{.Output}}
+Entries: {{.Stats.Files}}, {{size .Stats.Bytes}}
+Duration: {{.Duration}}`,
+	"解析通知模板失败: %w":                         "failed to parse notification template: %w",
+	"渲染通知模板失败: %w":                         "failed to render notification template: %w",
+	"无效的通知条件 %q，可选 failure、success、always": "invalid notification condition %q, available: failure, success, always",
+	"url 不能为空":                             "url must not be empty",
+	"无效的 webhook 地址: %s":                   "invalid webhook URL: %s",
+	"smtp 应为 host:port: %q":                "smtp must be host:port: %q",
+	"from 不能为空":                            "from must not be empty",
+	"to 不能为空":                              "to must not be empty",
+	"run 不能为空":                             "run must not be empty",
+	"发送通知失败":                               "failed to send notification",
+	"发送 webhook 失败 (%s): %w":               "failed to send webhook (%s): %w",
+	"webhook 返回 %s (%s)":                   "webhook returned %s (%s)",
+	"连接 SMTP 服务器失败 (%s): %w":               "failed to connect to SMTP server (%s): %w",
+	"发送邮件失败 (%s): %w":                      "failed to send email (%s): %w",
+	"执行通知命令失败: %w, 输出: %s":                 "notification command failed: %w, output: %s",
+
+	// paths.go
+	"备份路径缺少 path":                 "backup path is missing path",
+	"无效的 archive_prefix (%s): %s": "invalid archive_prefix (%s): %s",
+	"备份路径 %s: %w":                 "backup path %s: %w",
+
+	// priority.go
+	"无效的 I/O 调度类别 %q，可选 idle、best-effort[:0-7]、realtime[:0-7]": "invalid I/O scheduling class %q, available: idle, best-effort[:0-7], realtime[:0-7]",
+	"nice 值应在 -20 到 19 之间: %d":                                 "nice value must be between -20 and 19: %d",
+	"设置 nice 值失败: %w":                                          "failed to set nice value: %w",
+	"设置 I/O 优先级失败: %w":                                         "failed to set I/O priority: %w",
+	"当前系统不支持设置 nice 值":                                         "setting the nice value is not supported on this system",
+	"当前系统不支持设置 I/O 优先级":                                        "setting the I/O priority is not supported on this system",
+
+	// resolve.go
+	"配置文件循环包含: %s":                       "config files include each other in a cycle: %s",
+	"无效的 include 模式 (%s): %w":            "invalid include pattern (%s): %w",
+	"未找到 profile: %s (可选 %s)":            "profile not found: %s (available: %s)",
+	"profile %s 中不支持 include 和 profiles": "include and profiles are not supported in profile %s",
+	"环境变量未设置: %s":                        "environment variable is not set: %s",
+	"无效的备份路径模式 (%s): %w":                 "invalid backup path pattern (%s): %w",
+	"必需的备份路径没有匹配项 (%s)":                  "required backup path has no matches (%s)",
+	"备份路径没有匹配项":                          "backup path has no matches",
+
+	// restore.go
+	"执行还原前脚本失败: %w":         "failed to run the before-restore script: %w",
+	"还原前脚本执行完成":             "before-restore script finished",
+	"正在还原":                  "restoring",
+	"还原完成":                  "restore finished",
+	"执行还原后脚本失败: %w":         "failed to run the after-restore script: %w",
+	"还原后脚本执行完成":             "after-restore script finished",
+	"跳过未知文件":                "skipped unknown entry",
+	"还原 %s":                 "restoring %s",
+	"还原文件 %s 失败: %w":        "failed to restore file %s: %w",
+	"备份文件中没有找到可还原的文件":       "no restorable files found in archive",
+	"解析YAML文件 %s 失败: %w":    "failed to parse YAML file %s: %w",
+	"未找到文件: %s":             "file not found: %s",
+	"创建目录 %s 失败: %w":        "failed to create directory %s: %w",
+	"打开备份条目 %s 失败: %w":      "failed to open archive entry %s: %w",
+	"设置 %s 的权限失败: %w":       "failed to set permissions of %s: %w",
+	"设置 %s 的修改时间失败: %w":     "failed to set modification time of %s: %w",
+	"创建文件 %s 失败: %w":        "failed to create file %s: %w",
+	"复制文件内容 %s → %s 失败: %w": "failed to copy %s → %s: %w",
+	"读取符号链接 %s 失败: %w":      "failed to read symlink %s: %w",
+	"删除已存在的文件 %s 失败: %w":    "failed to remove existing file %s: %w",
+	"创建符号链接 %s 失败: %w":      "failed to create symlink %s: %w",
+	"已删除旧备份":                "deleted old backup",
+	"清理旧备份失败":               "failed to prune old backups",
+	"获取用户主目录失败: %w":         "failed to get home directory: %w",
+	"创建还原目录失败: %w":          "failed to create restore directory: %w",
+	"正在还原前备份当前文件":           "backing up current files before restore",
+	"还原前备份失败: %w":           "backup before restore failed: %w",
+	"还原前备份完成":               "backup before restore finished",
+	"执行还原":                  "Run a restore",
+	"指定待还原文件":               "archive to restore",
+	"还原根目录":                 "restore root directory",
+	"还原前备份，保留最近3个备份":        "back up before restoring, keeping the latest 3 backups",
+	"执行脚本":                  "run scripts",
+	"还原结束后写入 Prometheus 指标的文件，供 node_exporter textfile collector 读取": "file to write Prometheus metrics to after the restore, for the node_exporter textfile collector",
+
+	// result.go
+	"无效的输出格式 %q，可选 text、json": "invalid output format %q, available: text, json",
+
+	// retention.go
+	"至少需要指定一个 --keep-* 保留规则": "at least one --keep-* rule is required",
+	"保留数量不能为负数":              "keep counts must not be negative",
+	"无效的保留时间段 %q: %w":        "invalid retention period %q: %w",
+	"无效的保留时间段 %q: 应为数字加单位 y、m、w、d、h，如 \"7d\"、\"1y6m\"": "invalid retention period %q: expected numbers with units y, m, w, d, h such as \"7d\" or \"1y6m\"",
+	"无效的备份文件模式 (%s): %w":                               "invalid backup file pattern (%s): %w",
+	"删除旧备份失败 (%s): %w":                                 "failed to delete old backup (%s): %w",
+	"删除":                                               "delete",
+	"将删除":                                              "would delete",
+	"保留":                                               "keep",
+	"共 %d 个备份，保留 %d 个，%s %d 个\n":                       "%d backups, keep %d, %s %d\n",
+	"按保留策略删除目录中的旧备份":                                   "Delete old backups in a directory by retention policy",
+	`按保留策略删除目录中的旧备份，各规则保留的备份取并集。
+备份时间取自备份包头部记录的开始时间，旧版本创建的备份包读取 zip 清单，tar 格式和没有清单的使用文件修改时间。
+参数也可以是通配符模式，如 "/backup/etc_*.zip"。`: `Delete old backups in a directory by retention policy, keeping the union of all rules.
+Backup times come from the start time recorded in the archive header; archives from older versions use the zip manifest, or the file modification time for tar archives and archives without a manifest.
+The argument may also be a glob pattern such as "/backup/etc_*.zip".`,
+	"保留最近的 N 个备份":                 "keep the latest N backups",
+	"最近 N 个小时每小时保留最新的一个":          "keep the latest backup of each of the last N hours",
+	"最近 N 天每天保留最新的一个":             "keep the latest backup of each of the last N days",
+	"最近 N 周每周保留最新的一个":             "keep the latest backup of each of the last N weeks",
+	"最近 N 个月每月保留最新的一个":            "keep the latest backup of each of the last N months",
+	"最近 N 年每年保留最新的一个":             "keep the latest backup of each of the last N years",
+	"保留距最新备份这段时间内的所有备份，如 7d、1y6m": "keep all backups within this period of the newest one, such as 7d or 1y6m",
+	"只显示结果，不删除文件":                 "only show the result, do not delete files",
+
+	// script.go
+	"必须提供 config 或 input 参数":      "either config or input is required",
+	"不能同时提供 config 和 input 参数":    "config and input cannot be used together",
+	"type 必须是 'before' 或 'after'": "type must be 'before' or 'after'",
+	"未找到脚本":                       "no script found",
+	"从备份包读取配置失败: %w":              "failed to read config from archive: %w",
+	"执行 %s 脚本失败: %w":              "failed to run %s script: %w",
+	"脚本执行完成":                      "script finished",
+	"执行前置或后置脚本":                   "Run the before or after script",
+	"脚本类型 (before|after)":         "script type (before|after)",
+	"YAML配置文件路径":                  "YAML config file path",
+
+	// snapshot.go
+	"不支持的快照类型 %q，可选 btrfs、lvm、zfs、command": "unsupported snapshot type %q, available: btrfs, lvm, zfs, command",
+	"快照的 path 必须是绝对路径: %q":                 "snapshot path must be absolute: %q",
+	"快照的 mount 必须是绝对路径: %q":                "snapshot mount must be absolute: %q",
+	"lvm 快照的 volume 应为 卷组/逻辑卷: %q":         "lvm snapshot volume must be volume_group/logical_volume: %q",
+	"zfs 快照需要指定 volume（数据集）":               "zfs snapshot requires volume (the dataset)",
+	"zfs 快照通过 .zfs/snapshot 读取，不能指定 mount": "zfs snapshots are read from .zfs/snapshot, mount cannot be set",
+	"command 快照需要指定 create 命令":             "command snapshot requires a create command",
+	"删除快照失败":                               "failed to remove snapshot",
+	"已删除快照":                                "removed snapshot",
+	"创建快照失败 (%s): %w":                      "failed to create snapshot (%s): %w",
+	"已创建快照":                                "created snapshot",
+
+	// throttle.go
+	"无法解析速率: %q":                    "cannot parse rate: %q",
+	"worker 数量不能为负数: %d":            "worker count must not be negative: %d",
+	"并发处理文件的 worker 数量，0 表示 CPU 核数": "number of workers processing files concurrently, 0 means the number of CPUs",
+	"读取限速（字节/秒），如 10M，为空表示不限速；备份时限制读取源文件，还原时限制读取备份条目":              "read rate limit in bytes per second, such as 10M, empty means unlimited; limits reading source files when backing up and reading archive entries when restoring",
+	"写入限速（字节/秒），如 10M，为空表示不限速；备份时限制写入备份包，还原时限制写入还原的文件":             "write rate limit in bytes per second, such as 10M, empty means unlimited; limits writing the archive when backing up and writing restored files when restoring",
+	"进程的 CPU nice 值 (-20~19)，未指定时不修改":                              "CPU nice value of the process (-20~19), unchanged if not set",
+	"进程的 I/O 调度类别 (idle|best-effort[:0-7]|realtime[:0-7])，未指定时不修改": "I/O scheduling class of the process (idle|best-effort[:0-7]|realtime[:0-7]), unchanged if not set",
+
+	// timer.go
+	"已写入 %s\n": "wrote %s\n",
+	"启用定时器: systemctl daemon-reload && systemctl enable --now %s.timer\n": "enable the timer with: systemctl daemon-reload && systemctl enable --now %s.timer\n",
+	"已启用 %s.timer\n":                     "enabled %s.timer\n",
+	"获取程序路径失败: %w":                       "failed to get executable path: %w",
+	"unit 名称只能包含字母、数字、_、. 和 -: %q":       "unit name may only contain letters, digits, _, . and -: %q",
+	"--on-calendar 不能为空":                 "--on-calendar must not be empty",
+	"unit 文件中的路径必须是绝对路径: %q":             "paths in unit files must be absolute: %q",
+	"生成 service 文件失败: %w":                "failed to render service file: %w",
+	"生成 timer 文件失败: %w":                  "failed to render timer file: %w",
+	"创建快照目录失败 (%s): %w":                  "failed to create snapshot directory (%s): %w",
+	"创建备份输出目录失败 (%s): %w":                "failed to create backup output directory (%s): %w",
+	"创建 unit 目录失败 (%s): %w":              "failed to create unit directory (%s): %w",
+	"写入 unit 文件失败 (%s): %w":              "failed to write unit file (%s): %w",
+	"生成定时备份的 systemd service 和 timer 文件": "Generate systemd service and timer units for scheduled backups",
+	`生成定时备份的 systemd service 和 timer 文件并写入 unit 目录。
+备份以低优先级运行，文件系统只读，只有输出目录、配置中快照所在的目录和 --read-write-path 指定的路径可写。
+只生成备份的 service：还原需要写入任意路径并执行备份包中的脚本，不适合在只读的 service 中定时运行，应手动执行 restore。`: `Generate systemd service and timer units for scheduled backups and write them to the unit directory.
+Backups run at low priority with a read-only file system; only the output directory, the snapshot directories of the config and --read-write-path paths are writable.
+Only a backup service is generated: restore writes to arbitrary paths and runs the scripts in the archive, so it does not fit a read-only scheduled service and should be run by hand.`,
+	"unit 名称，默认为 backtrack 或 backtrack-<profile>":   "unit name, backtrack or backtrack-<profile> by default",
+	"systemd OnCalendar 表达式，如 daily、*-*-* 03:30:00": "systemd OnCalendar expression such as daily or *-*-* 03:30:00",
+	"随机延迟启动的最长时间，如 30min":                           "maximum random start delay such as 30min",
+	"备份输出目录": "backup output directory",
+	"额外允许写入的路径，如前后置脚本写入的目录，可重复指定": "extra writable path such as a directory written by scripts, repeatable",
+	"unit 文件写入目录":                          "directory to write unit files to",
+	"写入后执行 systemctl daemon-reload 并启用定时器": "run systemctl daemon-reload and enable the timer after writing",
+
+	// tools.go
+	"请以root权限运行":                 "please run as root",
+	"命令执行失败 (%s %v): %w, 输出: %s": "command failed (%s %v): %w, output: %s",
+	"无法解析大小: %q":                 "cannot parse size: %q",
+	"无法解析时长: %w":                 "cannot parse duration: %w",
+
+	// validate.go
+	"警告: %s\n":             "warning: %s\n",
+	"配置无效 (%s):\n%w":       "invalid config (%s):\n%w",
+	"配置有效: %s\n":           "config is valid: %s\n",
+	"解析YAML配置失败 (%s):\n%s": "failed to parse YAML config (%s):\n%s",
+	"backup_paths 不能为空":    "backup_paths must not be empty",
+	"排除规则: %w":             "exclude rules: %w",
+	"备份路径无法访问，备份时将跳过 (%s): %v": "backup path is not accessible and will be skipped (%s): %v",
+	"%s 只包含空白字符":               "%s contains only whitespace",
+	"%s 语法错误: %s":              "%s syntax error: %s",
+	"解析文件映射失败 (%s):\n%s":       "failed to parse file map (%s):\n%s",
+	"条目 %s 不在文件映射中，还原时将被跳过":    "entry %s is not in the file map and will be skipped on restore",
+	"文件映射引用了备份包中不存在的条目 %s":     "file map references entry %s that does not exist in the archive",
+	"条目 %s 和 %s 还原到同一路径 %s":    "entries %s and %s restore to the same path %s",
+	"%s: 不能映射元数据文件 %s":         "%s: metadata file %s cannot be mapped",
+	"%s: %s 缺少原路径":             "%s: %s is missing its original path",
+	"%s: %s 的原路径必须是绝对路径: %s":   "%s: the original path of %s must be absolute: %s",
+	"配置文件格式无效 (%s): %w":        "invalid config file format (%s): %w",
+	"校验配置文件或备份包中的配置":           "Validate a config file or the config in an archive",
+
+	// volume.go
+	"关闭分卷失败 (%s): %w":   "failed to close volume (%s): %w",
+	"创建分卷失败 (%s): %w":   "failed to create volume (%s): %w",
+	"写入分卷数量失败 (%s): %w": "failed to write volume count (%s): %w",
+	"未找到预留的分卷数量字段":      "reserved volume count field not found",
+	"查找分卷失败 (%s): %w":   "failed to find volumes (%s): %w",
+	"分卷不完整，缺少 %s":       "volumes are incomplete, missing %s",
+
+	// zipedit.go
+	"读取目录结束记录失败: %w":       "failed to read end of central directory record: %w",
+	"未找到zip目录结束记录":         "zip end of central directory record not found",
+	"未找到zip64目录定位记录":       "zip64 end of central directory locator not found",
+	"读取zip64目录定位记录失败: %w":  "failed to read zip64 end of central directory locator: %w",
+	"读取zip64目录结束记录失败: %w":  "failed to read zip64 end of central directory record: %w",
+	"zip64目录结束记录损坏":        "zip64 end of central directory record is corrupt",
+	"读取中央目录失败: %w":         "failed to read central directory: %w",
+	"中央目录损坏":               "central directory is corrupt",
+	"中央目录条目数不一致: %d != %d": "central directory entry count mismatch: %d != %d",
+	"打开备份文件失败: %w":         "failed to open archive: %w",
+	"中央目录超出文件范围":           "central directory extends beyond the end of the file",
+	"写入备份条目失败: %w":         "failed to write archive entry: %w",
+}
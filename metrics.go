@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	operationBackup  = "backup"
+	operationRestore = "restore"
+)
+
+// runMetrics 一次备份或还原的结果，由备份和还原过程填写计数
+type runMetrics struct {
+	Operation    string // backup 或 restore
+	Job          string
+	Success      bool
+	Start, End   time.Time
+	Files        int64 // 写入或还原的条目数
+	Bytes        int64 // 写入或还原的文件字节数
+	SkippedFiles int64
+	SkippedDirs  int64
+	Failed       int64
+	ArchiveSize  int64 // 备份包大小，分卷时为所有分卷之和，还原时为 0
+
+	Volumes  []string          // 分卷路径，只用于命令结果
+	Failures []ManifestFailure // 清单中记录的失败文件，只用于命令结果
+}
+
+// newRunMetrics 创建开始运行时的指标，job 为空时使用 default
+func newRunMetrics(operation, job string) *runMetrics {
+	if job == "" {
+		job = "default"
+	}
+	return &runMetrics{Operation: operation, Job: job, Start: time.Now()}
+}
+
+// finish 记录结束时间和运行结果
+func (m *runMetrics) finish(err error) {
+	m.End = time.Now()
+	m.Success = err == nil
+}
+
+// metricsKey 指标序列的标签
+type metricsKey struct {
+	operation, job string
+}
+
+// metricsSeries 一个任务最近一次运行的指标
+type metricsSeries struct {
+	last        runMetrics
+	lastSuccess time.Time // 最近一次成功的结束时间，失败的运行不会覆盖
+}
+
+// metricsRegistry 按 operation 和 job 保存最近一次运行的指标，输出 Prometheus 文本格式
+type metricsRegistry struct {
+	mu     sync.Mutex
+	series map[metricsKey]*metricsSeries
+	fileMu sync.Mutex // 串行写入指标文件，多个任务共用同一个临时文件
+}
+
+func newMetricsRegistry() *metricsRegistry {
+	return &metricsRegistry{series: make(map[metricsKey]*metricsSeries)}
+}
+
+// get 返回标签对应的序列，不存在时创建，调用方持有锁
+func (r *metricsRegistry) get(operation, job string) *metricsSeries {
+	key := metricsKey{operation, job}
+	s, ok := r.series[key]
+	if !ok {
+		s = &metricsSeries{}
+		r.series[key] = s
+	}
+	return s
+}
+
+// observe 记录一次运行的结果
+func (r *metricsRegistry) observe(m *runMetrics) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	s := r.get(m.Operation, m.Job)
+	s.last = *m
+	if m.Success {
+		s.lastSuccess = m.End
+	}
+}
+
+// setLastSuccess 从状态文件或之前的指标文件恢复最近一次成功的时间
+func (r *metricsRegistry) setLastSuccess(operation, job string, t time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if s := r.get(operation, job); t.After(s.lastSuccess) {
+		s.lastSuccess = t
+	}
+}
+
+// metricDef 输出的指标及其取值
+type metricDef struct {
+	name, help string
+	value      func(s *metricsSeries) (float64, bool) // 返回 false 时不输出该序列
+}
+
+// metricDefs 所有指标均为 gauge，按名称输出
+var metricDefs = []metricDef{
+	{"backtrack_last_success_timestamp_seconds", "最近一次成功运行的结束时间", func(s *metricsSeries) (float64, bool) {
+		return unixSeconds(s.lastSuccess), !s.lastSuccess.IsZero()
+	}},
+	{"backtrack_last_run_timestamp_seconds", "最近一次运行的结束时间", func(s *metricsSeries) (float64, bool) {
+		return unixSeconds(s.last.End), !s.last.End.IsZero()
+	}},
+	{"backtrack_last_run_success", "最近一次运行是否成功", func(s *metricsSeries) (float64, bool) {
+		if s.last.Success {
+			return 1, !s.last.End.IsZero()
+		}
+		return 0, !s.last.End.IsZero()
+	}},
+	{"backtrack_last_run_duration_seconds", "最近一次运行的耗时", func(s *metricsSeries) (float64, bool) {
+		return s.last.End.Sub(s.last.Start).Seconds(), !s.last.End.IsZero()
+	}},
+	{"backtrack_last_run_files", "最近一次运行写入或还原的条目数", func(s *metricsSeries) (float64, bool) {
+		return float64(s.last.Files), !s.last.End.IsZero()
+	}},
+	{"backtrack_last_run_bytes", "最近一次运行写入或还原的文件字节数", func(s *metricsSeries) (float64, bool) {
+		return float64(s.last.Bytes), !s.last.End.IsZero()
+	}},
+	{"backtrack_last_run_skipped_files", "最近一次运行跳过的文件数", func(s *metricsSeries) (float64, bool) {
+		return float64(s.last.SkippedFiles), !s.last.End.IsZero()
+	}},
+	{"backtrack_last_run_skipped_dirs", "最近一次运行跳过的文件夹数", func(s *metricsSeries) (float64, bool) {
+		return float64(s.last.SkippedDirs), !s.last.End.IsZero()
+	}},
+	{"backtrack_last_run_failed_files", "最近一次运行失败的文件数", func(s *metricsSeries) (float64, bool) {
+		return float64(s.last.Failed), !s.last.End.IsZero()
+	}},
+	{"backtrack_last_archive_size_bytes", "最近一次成功备份的备份包大小", func(s *metricsSeries) (float64, bool) {
+		return float64(s.last.ArchiveSize), s.last.Operation == operationBackup && s.last.Success
+	}},
+}
+
+// unixSeconds 返回带小数的 Unix 时间戳
+func unixSeconds(t time.Time) float64 {
+	return float64(t.UnixMilli()) / 1000
+}
+
+// write 以 Prometheus 文本格式输出所有指标
+func (r *metricsRegistry) write(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	keys := make([]metricsKey, 0, len(r.series))
+	for k := range r.series {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b metricsKey) int {
+		return cmp.Or(cmp.Compare(a.operation, b.operation), cmp.Compare(a.job, b.job))
+	})
+
+	bw := bufio.NewWriter(w)
+	for _, def := range metricDefs {
+		fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s gauge\n", def.name, tr(def.help), def.name)
+		for _, k := range keys {
+			if v, ok := def.value(r.series[k]); ok {
+				fmt.Fprintf(bw, "%s{operation=\"%s\",job=\"%s\"} %s\n", def.name,
+					escapeLabel(k.operation), escapeLabel(k.job), strconv.FormatFloat(v, 'f', -1, 64))
+			}
+		}
+	}
+	return bw.Flush()
+}
+
+// labelEscaper 转义标签值中的反斜杠、双引号和换行
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escapeLabel(s string) string {
+	return labelEscaper.Replace(s)
+}
+
+// ServeHTTP 提供 /metrics 端点
+func (r *metricsRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := r.write(w); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// writeTextfile 写入 node_exporter textfile collector 读取的指标文件，
+// 先写同目录的临时文件再重命名，避免 node_exporter 读到不完整的内容
+func (r *metricsRegistry) writeTextfile(path string) error {
+	r.fileMu.Lock()
+	defer r.fileMu.Unlock()
+
+	var buf bytes.Buffer
+	if err := r.write(&buf); err != nil {
+		return err
+	}
+	tmp := filepath.Join(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err := os.WriteFile(tmp, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf(tr("写入指标文件失败 (%s): %w"), path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf(tr("写入指标文件失败 (%s): %w"), path, err)
+	}
+	return nil
+}
+
+// loadTextfileLastSuccess 从之前写入的指标文件中恢复最近一次成功的时间，文件不存在时忽略
+func (r *metricsRegistry) loadTextfileLastSuccess(path string) error {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf(tr("读取指标文件失败 (%s): %w"), path, err)
+	}
+	defer f.Close()
+
+	const prefix = `backtrack_last_success_timestamp_seconds{operation="`
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		rest, ok := strings.CutPrefix(sc.Text(), prefix)
+		if !ok {
+			continue
+		}
+		operation, rest, _ := strings.Cut(rest, `",job="`)
+		job, value, _ := strings.Cut(rest, `"} `)
+		v, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			continue
+		}
+		r.setLastSuccess(operation, job, time.UnixMilli(int64(v*1000)))
+	}
+	return sc.Err()
+}
+
+// recordMetricsTextfile 将一次运行的结果写入指标文件，保留文件中其他任务的最近成功时间
+func recordMetricsTextfile(path string, m *runMetrics) error {
+	r := newMetricsRegistry()
+	if err := r.loadTextfileLastSuccess(path); err != nil {
+		return err
+	}
+	r.observe(m)
+	return r.writeTextfile(path)
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_metricsRegistry_write(t *testing.T) {
+	start := time.Unix(1767225600, 0)
+	r := newMetricsRegistry()
+	r.observe(&runMetrics{
+		Operation: operationBackup, Job: "etc", Success: true,
+		Start: start, End: start.Add(1500 * time.Millisecond),
+		Files: 3, Bytes: 1024, SkippedFiles: 2, SkippedDirs: 1, ArchiveSize: 512,
+	})
+	r.observe(&runMetrics{
+		Operation: operationBackup, Job: "etc", Success: false,
+		Start: start.Add(time.Hour), End: start.Add(time.Hour + time.Second), Failed: 1,
+	})
+	r.observe(&runMetrics{Operation: operationRestore, Job: `a"b`, Success: true, Start: start, End: start})
+
+	var out strings.Builder
+	if err := r.write(&out); err != nil {
+		t.Fatalf("write() error = %v", err)
+	}
+	for _, line := range []string{
+		"# TYPE backtrack_last_success_timestamp_seconds gauge",
+		`backtrack_last_success_timestamp_seconds{operation="backup",job="etc"} 1767225601.5`,
+		`backtrack_last_run_timestamp_seconds{operation="backup",job="etc"} 1767229201`,
+		`backtrack_last_run_success{operation="backup",job="etc"} 0`,
+		`backtrack_last_run_duration_seconds{operation="backup",job="etc"} 1`,
+		`backtrack_last_run_failed_files{operation="backup",job="etc"} 1`,
+		`backtrack_last_run_success{operation="restore",job="a\"b"} 1`,
+	} {
+		if !strings.Contains(out.String(), line+"\n") {
+			t.Errorf("write() missing %q:\n%s", line, out.String())
+		}
+	}
+	// 失败的备份和还原没有备份包大小
+	if strings.Contains(out.String(), "backtrack_last_archive_size_bytes{") {
+		t.Errorf("write() has archive size for failed run:\n%s", out.String())
+	}
+}
+
+func Test_recordMetricsTextfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backtrack.prom")
+
+	m := newRunMetrics(operationBackup, "")
+	m.finish(nil)
+	if err := recordMetricsTextfile(path, m); err != nil {
+		t.Fatalf("recordMetricsTextfile() error = %v", err)
+	}
+
+	failed := newRunMetrics(operationBackup, "")
+	failed.finish(errors.New("disk full"))
+	if err := recordMetricsTextfile(path, failed); err != nil {
+		t.Fatalf("recordMetricsTextfile() error = %v", err)
+	}
+
+	r := newMetricsRegistry()
+	if err := r.loadTextfileLastSuccess(path); err != nil {
+		t.Fatal(err)
+	}
+	s := r.series[metricsKey{operationBackup, "default"}]
+	if s == nil || !s.lastSuccess.Equal(m.End.Truncate(time.Millisecond)) {
+		t.Errorf("last success = %+v, want %v", s, m.End)
+	}
+	if entries, _ := os.ReadDir(filepath.Dir(path)); len(entries) != 1 {
+		t.Errorf("textfile dir has %d entries, want only the metrics file", len(entries))
+	}
+}
+
+func Test_startMetricsServer(t *testing.T) {
+	r := newMetricsRegistry()
+	m := newRunMetrics(operationBackup, "etc")
+	m.finish(nil)
+	r.observe(m)
+
+	srv, addr, err := startMetricsServer("127.0.0.1:0", r)
+	if err != nil {
+		t.Fatalf("startMetricsServer() error = %v", err)
+	}
+	defer srv.Close()
+
+	resp, err := http.Get("http://" + addr.String() + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), `backtrack_last_run_success{operation="backup",job="etc"} 1`) {
+		t.Errorf("GET /metrics = %d:\n%s", resp.StatusCode, body)
+	}
+}
+
+func Test_runMetrics_pipelines(t *testing.T) {
+	cfg, configBytes, err := loadConfig("testdata/config.yaml", "")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	archivePath := filepath.Join(t.TempDir(), "backup.zip")
+
+	// 每次备份的跳过统计独立计算
+	for range 2 {
+		bm := newRunMetrics(operationBackup, "")
+		if err := backup(t.Context(), cfg, configBytes, archivePath, backupOptions{Format: formatZip, Quiet: true, Metrics: bm}); err != nil {
+			t.Fatalf("backup() error = %v", err)
+		}
+		info, err := os.Stat(archivePath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bm.Files != 2 || bm.Bytes != 10 || bm.SkippedFiles != 1 || bm.Failed != 0 || bm.ArchiveSize != info.Size() {
+			t.Errorf("backup metrics = %+v, archive size %d", bm, info.Size())
+		}
+	}
+
+	rm := newRunMetrics(operationRestore, "")
+	if err := restore(t.Context(), archivePath, restoreOptions{RootDir: t.TempDir(), Quiet: true, Metrics: rm}); err != nil {
+		t.Fatalf("restore() error = %v", err)
+	}
+	if rm.Files != 2 || rm.Bytes != 10 || rm.Failed != 0 {
+		t.Errorf("restore metrics = %+v", rm)
+	}
+}
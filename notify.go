@@ -0,0 +1,442 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"mime"
+	"mime/quotedprintable"
+	"net"
+	"net/http"
+	"net/smtp"
+	"net/url"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+)
+
+const (
+	notifyOnFailure = "failure" // 失败或有文件失败时通知，默认
+	notifyOnSuccess = "success"
+	notifyOnAlways  = "always"
+)
+
+// defaultNotifyTimeout 单个通知的默认超时
+const defaultNotifyTimeout = 30 * time.Second
+
+// defaultNotifySubject 邮件的默认主题模板
+const defaultNotifySubject = "[backtrack] {{.Host}} {{.Job}} {{.Operation}}: {{.Status}}"
+
+// defaultNotifyMessage 默认的通知内容模板，输出时翻译
+const defaultNotifyMessage = `{{.Host}} 的任务 {{.Job}} {{if eq .Status "ok"}}备份成功{{else if eq .Status "partial"}}备份完成，{{.Stats.FailedFiles}} 个文件失败{{else}}备份失败: {{.Error}}{{end}}
+输出: {{.Output}}
+条目: {{.Stats.Files}} 个，{{size .Stats.Bytes}}
+耗时: {{.Duration}}`
+
+// NotifyConfig 备份结束后的通知，每种通知可配置多个
+type NotifyConfig struct {
+	Webhooks []WebhookNotify `yaml:"webhooks,omitempty"`
+	Emails   []EmailNotify   `yaml:"emails,omitempty"`
+	Commands []CommandNotify `yaml:"commands,omitempty"`
+}
+
+// WebhookNotify 以 JSON POST 运行结果
+type WebhookNotify struct {
+	URL     string            `yaml:"url"`               // 支持 ${VAR}，发送时展开
+	On      string            `yaml:"on,omitempty"`      // failure（默认）、success 或 always
+	Headers map[string]string `yaml:"headers,omitempty"` // 值支持 ${VAR}，发送时展开
+	Message string            `yaml:"message,omitempty"` // payload 中 message 字段的模板
+	Timeout string            `yaml:"timeout,omitempty"` // 默认 30s
+}
+
+// EmailNotify 通过 SMTP 发送邮件，服务器支持时使用 STARTTLS
+type EmailNotify struct {
+	SMTP     string   `yaml:"smtp"` // host:port
+	Username string   `yaml:"username,omitempty"`
+	Password string   `yaml:"password,omitempty"` // 支持 ${VAR}，发送时展开，备份包中只保存变量引用
+	From     string   `yaml:"from"`
+	To       []string `yaml:"to"`
+	On       string   `yaml:"on,omitempty"`
+	Subject  string   `yaml:"subject,omitempty"` // 主题模板
+	Body     string   `yaml:"body,omitempty"`    // 正文模板
+	Timeout  string   `yaml:"timeout,omitempty"`
+}
+
+// CommandNotify 用 sh -c 执行命令，运行结果通过 BACKTRACK_* 环境变量传入，消息写入标准输入
+type CommandNotify struct {
+	Run     string `yaml:"run"`
+	On      string `yaml:"on,omitempty"`
+	Message string `yaml:"message,omitempty"` // 写入标准输入和 BACKTRACK_MESSAGE 的模板
+	Timeout string `yaml:"timeout,omitempty"`
+}
+
+// notifyEvent 通知的运行结果，也是模板的数据和 webhook 的 payload
+type notifyEvent struct {
+	Operation       string      `json:"operation"` // backup
+	Job             string      `json:"job"`
+	Host            string      `json:"host"`
+	Status          string      `json:"status"` // ok、partial 或 error
+	Error           string      `json:"error,omitempty"`
+	Output          string      `json:"output,omitempty"`
+	StartTime       time.Time   `json:"start_time"`
+	EndTime         time.Time   `json:"end_time"`
+	DurationSeconds float64     `json:"duration_seconds"`
+	Stats           resultStats `json:"stats"`
+	Message         string      `json:"message,omitempty"` // 渲染后的消息
+}
+
+// newNotifyEvent 根据一次运行的结果创建通知
+func newNotifyEvent(m *runMetrics, output string, err error) *notifyEvent {
+	host, _ := os.Hostname()
+	e := &notifyEvent{
+		Operation:       m.Operation,
+		Job:             m.Job,
+		Host:            host,
+		Status:          statusOK,
+		Output:          output,
+		StartTime:       m.Start,
+		EndTime:         m.End,
+		DurationSeconds: m.End.Sub(m.Start).Seconds(),
+		Stats: resultStats{
+			Files:        m.Files,
+			Bytes:        m.Bytes,
+			SkippedFiles: m.SkippedFiles,
+			SkippedDirs:  m.SkippedDirs,
+			FailedFiles:  m.Failed,
+			ArchiveSize:  m.ArchiveSize,
+		},
+	}
+	switch {
+	case err != nil:
+		e.Status, e.Error = statusError, err.Error()
+	case m.Failed > 0:
+		e.Status = statusPartial
+	}
+	return e
+}
+
+// Duration 运行耗时，用于模板
+func (e *notifyEvent) Duration() time.Duration {
+	return e.EndTime.Sub(e.StartTime).Round(time.Millisecond)
+}
+
+// env 返回传给通知命令的环境变量
+func (e *notifyEvent) env(message string) []string {
+	return []string{
+		"BACKTRACK_OPERATION=" + e.Operation,
+		"BACKTRACK_JOB=" + e.Job,
+		"BACKTRACK_HOST=" + e.Host,
+		"BACKTRACK_STATUS=" + e.Status,
+		"BACKTRACK_ERROR=" + e.Error,
+		"BACKTRACK_OUTPUT=" + e.Output,
+		"BACKTRACK_DURATION_SECONDS=" + strconv.FormatFloat(e.DurationSeconds, 'f', 3, 64),
+		"BACKTRACK_FILES=" + strconv.FormatInt(e.Stats.Files, 10),
+		"BACKTRACK_BYTES=" + strconv.FormatInt(e.Stats.Bytes, 10),
+		"BACKTRACK_FAILED_FILES=" + strconv.FormatInt(e.Stats.FailedFiles, 10),
+		"BACKTRACK_MESSAGE=" + message,
+	}
+}
+
+// notifyMatches 按 on 过滤事件，partial 视为失败
+func notifyMatches(on string, e *notifyEvent) bool {
+	switch on {
+	case notifyOnAlways:
+		return true
+	case notifyOnSuccess:
+		return e.Status == statusOK
+	default:
+		return e.Status != statusOK
+	}
+}
+
+// notifyFuncs 模板中可用的函数
+var notifyFuncs = template.FuncMap{"size": formatSize}
+
+// parseNotifyTemplate 解析模板，为空时使用默认模板
+func parseNotifyTemplate(text, fallback string) (*template.Template, error) {
+	if text == "" {
+		text = fallback
+	}
+	return template.New("notify").Funcs(notifyFuncs).Option("missingkey=error").Parse(text)
+}
+
+// renderNotifyTemplate 渲染模板，默认模板按当前语言翻译
+func renderNotifyTemplate(text, fallback string, e *notifyEvent) (string, error) {
+	t, err := parseNotifyTemplate(text, tr(fallback))
+	if err != nil {
+		return "", fmt.Errorf(tr("解析通知模板失败: %w"), err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, e); err != nil {
+		return "", fmt.Errorf(tr("渲染通知模板失败: %w"), err)
+	}
+	return buf.String(), nil
+}
+
+// notifyTimeout 解析超时，为空时使用默认值
+func notifyTimeout(s string) (time.Duration, error) {
+	if s == "" {
+		return defaultNotifyTimeout, nil
+	}
+	return parseDuration(s)
+}
+
+// validateNotifyCommon 检查通知共有的 on、超时和模板
+func validateNotifyCommon(on, timeout string, templates ...string) error {
+	switch on {
+	case "", notifyOnFailure, notifyOnSuccess, notifyOnAlways:
+	default:
+		return fmt.Errorf(tr("无效的通知条件 %q，可选 failure、success、always"), on)
+	}
+	if _, err := notifyTimeout(timeout); err != nil {
+		return err
+	}
+	for _, text := range templates {
+		if _, err := parseNotifyTemplate(text, defaultNotifyMessage); err != nil {
+			return fmt.Errorf(tr("解析通知模板失败: %w"), err)
+		}
+	}
+	return nil
+}
+
+// validate 检查通知配置
+func (c NotifyConfig) validate() error {
+	var errs []error
+	for i, w := range c.Webhooks {
+		if err := w.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("notify.webhooks[%d]: %w", i, err))
+		}
+	}
+	for i, m := range c.Emails {
+		if err := m.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("notify.emails[%d]: %w", i, err))
+		}
+	}
+	for i, cmd := range c.Commands {
+		if err := cmd.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("notify.commands[%d]: %w", i, err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (w WebhookNotify) validate() error {
+	if w.URL == "" {
+		return errors.New(tr("url 不能为空"))
+	}
+	// 含有环境变量的 URL 在发送时才能检查
+	if !strings.Contains(w.URL, "${") {
+		if u, err := url.Parse(w.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf(tr("无效的 webhook 地址: %s"), w.URL)
+		}
+	}
+	return validateNotifyCommon(w.On, w.Timeout, w.Message)
+}
+
+func (m EmailNotify) validate() error {
+	if _, _, err := net.SplitHostPort(m.SMTP); err != nil {
+		return fmt.Errorf(tr("smtp 应为 host:port: %q"), m.SMTP)
+	}
+	if m.From == "" {
+		return errors.New(tr("from 不能为空"))
+	}
+	if len(m.To) == 0 {
+		return errors.New(tr("to 不能为空"))
+	}
+	return validateNotifyCommon(m.On, m.Timeout, m.Subject, m.Body)
+}
+
+func (c CommandNotify) validate() error {
+	if strings.TrimSpace(c.Run) == "" {
+		return errors.New(tr("run 不能为空"))
+	}
+	return validateNotifyCommon(c.On, c.Timeout, c.Message)
+}
+
+// sendNotifications 按过滤条件依次发送通知。通知失败只记录日志，不影响运行结果；
+// 运行被中断时仍会发送，每个通知有独立的超时
+func sendNotifications(ctx context.Context, cfg NotifyConfig, e *notifyEvent) {
+	ctx = context.WithoutCancel(ctx)
+	for _, w := range cfg.Webhooks {
+		if notifyMatches(w.On, e) {
+			if err := w.send(ctx, e); err != nil {
+				slog.Warn(tr("发送通知失败"), "notify", "webhook", "error", err)
+			}
+		}
+	}
+	for _, m := range cfg.Emails {
+		if notifyMatches(m.On, e) {
+			if err := m.send(ctx, e); err != nil {
+				slog.Warn(tr("发送通知失败"), "notify", "email", "error", err)
+			}
+		}
+	}
+	for _, c := range cfg.Commands {
+		if notifyMatches(c.On, e) {
+			if err := c.send(ctx, e); err != nil {
+				slog.Warn(tr("发送通知失败"), "notify", "command", "error", err)
+			}
+		}
+	}
+}
+
+// send POST JSON payload，2xx 以外的状态码视为失败。错误中只包含未展开的地址，避免泄露变量中的令牌
+func (w WebhookNotify) send(ctx context.Context, e *notifyEvent) error {
+	timeout, err := notifyTimeout(w.Timeout)
+	if err != nil {
+		return err
+	}
+	addr, err := expandEnv(w.URL, true)
+	if err != nil {
+		return err
+	}
+	payload := *e
+	if payload.Message, err = renderNotifyTemplate(w.Message, defaultNotifyMessage, e); err != nil {
+		return err
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, addr, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf(tr("无效的 webhook 地址: %s"), w.URL)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range w.Headers {
+		if v, err = expandEnv(v, true); err != nil {
+			return err
+		}
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf(tr("发送 webhook 失败 (%s): %w"), w.URL, errors.Unwrap(err))
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf(tr("webhook 返回 %s (%s)"), resp.Status, w.URL)
+	}
+	return nil
+}
+
+// send 连接 SMTP 服务器发送邮件，设置了用户名时使用 PLAIN 认证，未加密的连接只允许认证 localhost
+func (m EmailNotify) send(ctx context.Context, e *notifyEvent) error {
+	timeout, err := notifyTimeout(m.Timeout)
+	if err != nil {
+		return err
+	}
+	subject, err := renderNotifyTemplate(m.Subject, defaultNotifySubject, e)
+	if err != nil {
+		return err
+	}
+	body, err := renderNotifyTemplate(m.Body, defaultNotifyMessage, e)
+	if err != nil {
+		return err
+	}
+	password, err := expandEnv(m.Password, true)
+	if err != nil {
+		return err
+	}
+	msg := buildMail(m.From, m.To, subject, body, time.Now())
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", m.SMTP)
+	if err != nil {
+		return fmt.Errorf(tr("连接 SMTP 服务器失败 (%s): %w"), m.SMTP, err)
+	}
+	deadline, _ := ctx.Deadline()
+	conn.SetDeadline(deadline)
+
+	host, _, _ := net.SplitHostPort(m.SMTP)
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf(tr("连接 SMTP 服务器失败 (%s): %w"), m.SMTP, err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf(tr("发送邮件失败 (%s): %w"), m.SMTP, err)
+		}
+	}
+	if m.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", m.Username, password, host)); err != nil {
+			return fmt.Errorf(tr("发送邮件失败 (%s): %w"), m.SMTP, err)
+		}
+	}
+	if err := c.Mail(m.From); err != nil {
+		return fmt.Errorf(tr("发送邮件失败 (%s): %w"), m.SMTP, err)
+	}
+	for _, to := range m.To {
+		if err := c.Rcpt(to); err != nil {
+			return fmt.Errorf(tr("发送邮件失败 (%s): %w"), m.SMTP, err)
+		}
+	}
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf(tr("发送邮件失败 (%s): %w"), m.SMTP, err)
+	}
+	if _, err := w.Write(msg); err != nil {
+		return fmt.Errorf(tr("发送邮件失败 (%s): %w"), m.SMTP, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf(tr("发送邮件失败 (%s): %w"), m.SMTP, err)
+	}
+	return c.Quit()
+}
+
+// buildMail 生成 UTF-8 纯文本邮件，主题按 RFC 2047 编码，正文使用 quoted-printable
+func buildMail(from string, to []string, subject, body string, date time.Time) []byte {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", strings.Join(to, ", "))
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", date.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	qp := quotedprintable.NewWriter(&buf)
+	qp.Write([]byte(strings.ReplaceAll(body, "\n", "\r\n")))
+	qp.Close()
+	return buf.Bytes()
+}
+
+// send 执行通知命令
+func (c CommandNotify) send(ctx context.Context, e *notifyEvent) error {
+	timeout, err := notifyTimeout(c.Timeout)
+	if err != nil {
+		return err
+	}
+	msg, err := renderNotifyTemplate(c.Message, defaultNotifyMessage, e)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, "sh", "-c", c.Run)
+	cmd.Env = append(os.Environ(), e.env(msg)...)
+	cmd.Stdin = strings.NewReader(msg)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return fmt.Errorf(tr("执行通知命令失败: %w, 输出: %s"), err, output)
+	}
+	return nil
+}
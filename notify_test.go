@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testNotifyEvent 返回一个有文件失败的备份结果
+func testNotifyEvent() *notifyEvent {
+	start := time.Unix(1767225600, 0)
+	return newNotifyEvent(&runMetrics{
+		Operation: operationBackup, Job: "etc",
+		Start: start, End: start.Add(1500 * time.Millisecond),
+		Files: 3, Bytes: 2048, Failed: 1,
+	}, "/backup/etc.zip", nil)
+}
+
+func Test_newNotifyEvent(t *testing.T) {
+	tests := []struct {
+		name       string
+		failed     int64
+		err        error
+		wantStatus string
+	}{
+		{name: "ok", wantStatus: statusOK},
+		{name: "partial", failed: 2, wantStatus: statusPartial},
+		{name: "error", failed: 2, err: errors.New("boom"), wantStatus: statusError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e := newNotifyEvent(&runMetrics{Operation: operationBackup, Job: "etc", Failed: tt.failed}, "out.zip", tt.err)
+			if e.Status != tt.wantStatus {
+				t.Errorf("Status = %q, want %q", e.Status, tt.wantStatus)
+			}
+			if (e.Error != "") != (tt.err != nil) {
+				t.Errorf("Error = %q", e.Error)
+			}
+		})
+	}
+}
+
+func Test_notifyMatches(t *testing.T) {
+	tests := []struct {
+		on     string
+		status string
+		want   bool
+	}{
+		{on: "", status: statusOK, want: false},
+		{on: "", status: statusPartial, want: true},
+		{on: notifyOnFailure, status: statusError, want: true},
+		{on: notifyOnSuccess, status: statusOK, want: true},
+		{on: notifyOnSuccess, status: statusPartial, want: false},
+		{on: notifyOnAlways, status: statusOK, want: true},
+		{on: notifyOnAlways, status: statusError, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.on+"/"+tt.status, func(t *testing.T) {
+			if got := notifyMatches(tt.on, &notifyEvent{Status: tt.status}); got != tt.want {
+				t.Errorf("notifyMatches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_renderNotifyTemplate(t *testing.T) {
+	e := testNotifyEvent()
+	tests := []struct {
+		name    string
+		lang    language
+		text    string
+		want    string
+		wantErr bool
+	}{
+		{name: "custom", text: "{{.Job}} {{.Status}} {{size .Stats.Bytes}} {{.Duration}}", want: "etc partial 2.0K 1.5s"},
+		{name: "default zh", lang: langZh, want: "1 个文件失败"},
+		{name: "default en", lang: langEn, want: "1 files failed"},
+		{name: "missing field", text: "{{.Nope}}", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.lang != "" {
+				useLanguage(t, tt.lang)
+			}
+			got, err := renderNotifyTemplate(tt.text, defaultNotifyMessage, e)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("renderNotifyTemplate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("renderNotifyTemplate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_NotifyConfig_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     NotifyConfig
+		wantErr bool
+	}{
+		{name: "empty"},
+		{name: "valid", cfg: NotifyConfig{
+			Webhooks: []WebhookNotify{{URL: "https://example.com/hook", On: notifyOnAlways, Timeout: "10s"}},
+			Emails:   []EmailNotify{{SMTP: "smtp.example.com:587", From: "a@example.com", To: []string{"b@example.com"}}},
+			Commands: []CommandNotify{{Run: "true", Message: "{{.Status}}"}},
+		}},
+		{name: "url with env", cfg: NotifyConfig{Webhooks: []WebhookNotify{{URL: "${HOOK_URL}"}}}},
+		{name: "empty url", cfg: NotifyConfig{Webhooks: []WebhookNotify{{}}}, wantErr: true},
+		{name: "invalid url", cfg: NotifyConfig{Webhooks: []WebhookNotify{{URL: "example.com"}}}, wantErr: true},
+		{name: "invalid on", cfg: NotifyConfig{Webhooks: []WebhookNotify{{URL: "http://a", On: "never"}}}, wantErr: true},
+		{name: "invalid timeout", cfg: NotifyConfig{Commands: []CommandNotify{{Run: "true", Timeout: "soon"}}}, wantErr: true},
+		{name: "invalid template", cfg: NotifyConfig{Commands: []CommandNotify{{Run: "true", Message: "{{.Job"}}}, wantErr: true},
+		{name: "empty run", cfg: NotifyConfig{Commands: []CommandNotify{{Run: " "}}}, wantErr: true},
+		{name: "smtp without port", cfg: NotifyConfig{Emails: []EmailNotify{{SMTP: "smtp.example.com", From: "a@b", To: []string{"c@d"}}}}, wantErr: true},
+		{name: "email without to", cfg: NotifyConfig{Emails: []EmailNotify{{SMTP: "smtp.example.com:25", From: "a@b"}}}, wantErr: true},
+		{name: "invalid subject", cfg: NotifyConfig{Emails: []EmailNotify{{SMTP: "smtp.example.com:25", From: "a@b", To: []string{"c@d"}, Subject: "{{"}}}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_WebhookNotify_send(t *testing.T) {
+	var (
+		got    notifyEvent
+		header http.Header
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Errorf("decode payload: %v", err)
+		}
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer srv.Close()
+	t.Setenv("BACKTRACK_TEST_TOKEN", "secret")
+
+	w := WebhookNotify{
+		URL:     srv.URL + "/hook",
+		Headers: map[string]string{"Authorization": "Bearer ${BACKTRACK_TEST_TOKEN}"},
+		Message: "{{.Job}}: {{.Status}}",
+	}
+	if err := w.send(context.Background(), testNotifyEvent()); err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+	if got.Job != "etc" || got.Status != statusPartial || got.Stats.FailedFiles != 1 || got.Output != "/backup/etc.zip" {
+		t.Errorf("payload = %+v", got)
+	}
+	if got.Message != "etc: partial" {
+		t.Errorf("payload message = %q", got.Message)
+	}
+	if header.Get("Authorization") != "Bearer secret" || header.Get("Content-Type") != "application/json" {
+		t.Errorf("header = %v", header)
+	}
+
+	w.URL = srv.URL + "/fail"
+	if err := w.send(context.Background(), testNotifyEvent()); err == nil {
+		t.Errorf("send() to failing server error = nil")
+	}
+}
+
+// fakeSMTP 接受一个连接，按最简单的 SMTP 会话应答，返回收到的命令和邮件内容
+func fakeSMTP(t *testing.T) (addr string, session <-chan []string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	ch := make(chan []string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var lines []string
+		defer func() { ch <- lines }()
+		r := bufio.NewReader(conn)
+		reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+		reply("220 localhost ESMTP")
+		data := false
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			lines = append(lines, line)
+			switch {
+			case data:
+				if line == "." {
+					data = false
+					reply("250 OK")
+				}
+			case strings.HasPrefix(line, "EHLO"):
+				reply("250-localhost")
+				reply("250 AUTH PLAIN")
+			case strings.HasPrefix(line, "AUTH"):
+				reply("235 OK")
+			case line == "DATA":
+				data = true
+				reply("354 go ahead")
+			case line == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return ln.Addr().String(), ch
+}
+
+func Test_EmailNotify_send(t *testing.T) {
+	addr, session := fakeSMTP(t)
+	t.Setenv("BACKTRACK_TEST_PASSWORD", "pw")
+
+	m := EmailNotify{
+		SMTP:     addr,
+		Username: "user",
+		Password: "${BACKTRACK_TEST_PASSWORD}",
+		From:     "backtrack@example.com",
+		To:       []string{"ops@example.com", "dev@example.com"},
+		Subject:  "{{.Job}} {{.Status}}",
+		Body:     "失败 {{.Stats.FailedFiles}}",
+	}
+	if err := m.send(context.Background(), testNotifyEvent()); err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+
+	lines := <-session
+	text := strings.Join(lines, "\n")
+	for _, want := range []string{
+		"AUTH PLAIN",
+		"MAIL FROM:<backtrack@example.com>",
+		"RCPT TO:<ops@example.com>",
+		"RCPT TO:<dev@example.com>",
+		"To: ops@example.com, dev@example.com",
+		"Subject: etc partial",
+		"Content-Transfer-Encoding: quoted-printable",
+		"=E5=A4=B1=E8=B4=A5 1", // 失败 1
+		"QUIT",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("session missing %q:\n%s", want, text)
+		}
+	}
+}
+
+func Test_CommandNotify_send(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	c := CommandNotify{
+		Run:     `printf '%s %s %s\n' "$BACKTRACK_JOB" "$BACKTRACK_STATUS" "$BACKTRACK_FAILED_FILES" > ` + out + `; cat >> ` + out,
+		Message: "{{.Job}} done",
+	}
+	if err := c.send(context.Background(), testNotifyEvent()); err != nil {
+		t.Fatalf("send() error = %v", err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "etc partial 1\netc done"; string(data) != want {
+		t.Errorf("output = %q, want %q", data, want)
+	}
+
+	c = CommandNotify{Run: "exit 3"}
+	if err := c.send(context.Background(), testNotifyEvent()); err == nil {
+		t.Errorf("send() of failing command error = nil")
+	}
+}
+
+func Test_sendNotifications(t *testing.T) {
+	var hits atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+	}))
+	defer srv.Close()
+
+	cfg := NotifyConfig{Webhooks: []WebhookNotify{
+		{URL: srv.URL},
+		{URL: srv.URL, On: notifyOnSuccess},
+		{URL: srv.URL, On: notifyOnAlways},
+		{URL: "http://127.0.0.1:1", On: notifyOnAlways, Timeout: "1s"}, // 失败不影响其他通知
+	}}
+	// 运行被中断时仍发送通知
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	sendNotifications(ctx, cfg, testNotifyEvent())
+	if got := hits.Load(); got != 2 {
+		t.Errorf("hits = %d, want 2", got)
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// BackupPath 备份路径，配置中可以写成字符串或带选项的对象
+type BackupPath struct {
+	Path           string             `yaml:"path"`
+	Excludes       []string           `yaml:"excludes,omitempty"`        // 相对该路径的排除规则，gitignore 语法
+	Includes       []string           `yaml:"includes,omitempty"`        // 只备份匹配的文件，为空表示全部
+	FollowSymlinks *bool              `yaml:"follow_symlinks,omitempty"` // 为 false 时符号链接按链接本身备份，默认跟随
+	Compression    *CompressionConfig `yaml:"compression,omitempty"`     // 覆盖全局压缩配置
+	ArchivePrefix  string             `yaml:"archive_prefix,omitempty"`  // 备份包内的目录名，默认为路径的最后一级
+	Required       bool               `yaml:"required,omitempty"`        // 路径不存在时备份失败
+}
+
+// backupPathOptions 用于解析对象形式，避免递归调用 UnmarshalYAML
+type backupPathOptions BackupPath
+
+// UnmarshalYAML 兼容字符串形式的备份路径
+func (p *BackupPath) UnmarshalYAML(unmarshal func(any) error) error {
+	var path string
+	if err := unmarshal(&path); err == nil {
+		*p = BackupPath{Path: path}
+		return nil
+	}
+
+	var opts backupPathOptions
+	if err := unmarshal(&opts); err != nil {
+		return err
+	}
+	if opts.Path == "" {
+		return errors.New(tr("备份路径缺少 path"))
+	}
+	*p = BackupPath(opts)
+	return nil
+}
+
+// MarshalYAML 没有额外选项时输出字符串形式
+func (p BackupPath) MarshalYAML() (any, error) {
+	if p.isPlain() {
+		return p.Path, nil
+	}
+	return backupPathOptions(p), nil
+}
+
+func (p BackupPath) isPlain() bool {
+	return len(p.Excludes) == 0 && len(p.Includes) == 0 && p.FollowSymlinks == nil &&
+		p.Compression == nil && p.ArchivePrefix == "" && !p.Required
+}
+
+// followSymlinks 是否跟随符号链接备份其指向的文件
+func (p BackupPath) followSymlinks() bool {
+	return p.FollowSymlinks == nil || *p.FollowSymlinks
+}
+
+// compression 返回该路径生效的压缩配置
+func (p BackupPath) compression(cfg *Config) *CompressionConfig {
+	if p.Compression != nil {
+		return p.Compression
+	}
+	return &cfg.Compression
+}
+
+// prefix 返回该路径在数据目录下使用的名称
+func (p BackupPath) prefix(absRoot string) string {
+	if p.ArchivePrefix != "" {
+		return filepath.Clean(p.ArchivePrefix)
+	}
+	return filepath.Base(absRoot)
+}
+
+// validate 检查备份路径选项
+func (p BackupPath) validate() error {
+	if p.ArchivePrefix != "" {
+		prefix := filepath.Clean(p.ArchivePrefix)
+		if filepath.IsAbs(prefix) || prefix == "." || prefix == ".." || strings.HasPrefix(prefix, "../") {
+			return fmt.Errorf(tr("无效的 archive_prefix (%s): %s"), p.Path, p.ArchivePrefix)
+		}
+	}
+	if p.Compression != nil {
+		if err := p.Compression.validate(); err != nil {
+			return fmt.Errorf(tr("备份路径 %s: %w"), p.Path, err)
+		}
+	}
+	if _, err := parseIgnoreRules(string(filepath.Separator), p.Excludes, true); err != nil {
+		return fmt.Errorf(tr("备份路径 %s: %w"), p.Path, err)
+	}
+	if _, err := parseIgnoreRules(string(filepath.Separator), p.Includes, true); err != nil {
+		return fmt.Errorf(tr("备份路径 %s: %w"), p.Path, err)
+	}
+	return nil
+}
+
+// included 检查文件是否匹配 includes，文件本身或任一上级目录匹配即可
+func (p BackupPath) included(rules ignoreRules, absRoot, path string) bool {
+	if len(rules) == 0 || path == absRoot {
+		return true
+	}
+	for cur, isDir := path, false; cur != absRoot && cur != filepath.Dir(cur); cur, isDir = filepath.Dir(cur), true {
+		for i := range rules {
+			if rules[i].match(cur, isDir) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+)
+
+func Test_BackupPath_UnmarshalYAML(t *testing.T) {
+	no := false
+	tests := []struct {
+		name    string
+		data    string
+		want    []BackupPath
+		wantErr bool
+	}{
+		{
+			name: "plain string",
+			data: "- /etc/app\n",
+			want: []BackupPath{{Path: "/etc/app"}},
+		},
+		{
+			name: "object with options",
+			data: "- path: /srv/www\n  excludes: [\"cache/\"]\n  includes: [\"*.html\"]\n" +
+				"  follow_symlinks: false\n  compression:\n    algorithm: zstd\n  archive_prefix: www\n  required: true\n",
+			want: []BackupPath{{
+				Path:           "/srv/www",
+				Excludes:       []string{"cache/"},
+				Includes:       []string{"*.html"},
+				FollowSymlinks: &no,
+				Compression:    &CompressionConfig{Algorithm: compressionZstd},
+				ArchivePrefix:  "www",
+				Required:       true,
+			}},
+		},
+		{
+			name: "mixed",
+			data: "- /etc/app\n- path: /var/lib/app\n  required: true\n",
+			want: []BackupPath{{Path: "/etc/app"}, {Path: "/var/lib/app", Required: true}},
+		},
+		{
+			name:    "missing path",
+			data:    "- excludes: [\"*.log\"]\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []BackupPath
+			err := yaml.Unmarshal([]byte(tt.data), &got)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Unmarshal() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Unmarshal() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_BackupPath_MarshalYAML(t *testing.T) {
+	paths := []BackupPath{{Path: "/etc/app"}, {Path: "/srv/www", ArchivePrefix: "www"}}
+	data, err := yaml.Marshal(paths)
+	if err != nil {
+		t.Fatalf("Marshal() error = %v", err)
+	}
+
+	want := "- /etc/app\n- path: /srv/www\n  archive_prefix: www\n"
+	if string(data) != want {
+		t.Errorf("Marshal() = %q, want %q", data, want)
+	}
+}
+
+func Test_walkBackupPathOptions(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"www/index.html", "www/cache/page.html", "www/notes.txt", "www/css/site.css"} {
+		path := filepath.Join(root, name)
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(name), 0644)
+	}
+
+	bp := BackupPath{
+		Path:          filepath.Join(root, "www"),
+		Excludes:      []string{"cache/"},
+		Includes:      []string{"*.html", "css/"},
+		ArchivePrefix: "site/public",
+	}
+
+	var got []string
+	err := walkBackupPath(&Config{}, bp, nil, nil, func(absPath, relPath string, isDir bool) error {
+		got = append(got, filepath.ToSlash(relPath))
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walkBackupPath() error = %v", err)
+	}
+
+	want := []string{"data/site/public/css/site.css", "data/site/public/index.html"}
+	slices.Sort(got)
+	if !slices.Equal(got, want) {
+		t.Errorf("walkBackupPath() = %v, want %v", got, want)
+	}
+}
+
+func Test_backupSymlinks(t *testing.T) {
+	for _, format := range archiveFormats {
+		t.Run(string(format), func(t *testing.T) {
+			root := t.TempDir()
+			dir := filepath.Join(root, "app")
+			os.MkdirAll(dir, 0755)
+			os.WriteFile(filepath.Join(dir, "config.v2"), []byte("v2"), 0644)
+			if err := os.Symlink("config.v2", filepath.Join(dir, "config")); err != nil {
+				t.Skipf("不支持符号链接: %v", err)
+			}
+
+			no := false
+			cfg := &Config{BackupPaths: []BackupPath{{Path: dir, FollowSymlinks: &no}}}
+			outputPath := filepath.Join(t.TempDir(), "output."+string(format))
+			if err := backup(t.Context(), cfg, nil, outputPath, backupOptions{Format: format, Quiet: true}); err != nil {
+				t.Fatalf("backup() error = %v", err)
+			}
+
+			tempDir := t.TempDir()
+			if err := restore(t.Context(), outputPath, restoreOptions{RootDir: tempDir, Quiet: true}); err != nil {
+				t.Fatalf("restore() error = %v", err)
+			}
+
+			target, err := os.Readlink(filepath.Join(tempDir, dir, "config"))
+			if err != nil {
+				t.Fatalf("Readlink() error = %v", err)
+			}
+			if target != "config.v2" {
+				t.Errorf("restored symlink target = %q, want %q", target, "config.v2")
+			}
+		})
+	}
+}
+
+func Test_backupRequiredPath(t *testing.T) {
+	cfg := &Config{BackupPaths: []BackupPath{{Path: filepath.Join(t.TempDir(), "missing"), Required: true}}}
+	outputPath := filepath.Join(t.TempDir(), "output.zip")
+	if err := backup(t.Context(), cfg, nil, outputPath, backupOptions{Quiet: true}); err == nil {
+		t.Errorf("backup() expected error for missing required path")
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Errorf("backup() left output file after failure")
+	}
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// ioClass I/O 调度类别，取值与 Linux 的 IOPRIO_CLASS_* 相同
+type ioClass int
+
+const (
+	ioClassNone ioClass = iota // 不修改
+	ioClassRealtime
+	ioClassBestEffort
+	ioClassIdle
+)
+
+// defaultIOLevel 未指定级别时使用的 I/O 优先级，与 nice 值为 0 时内核使用的级别相同
+const defaultIOLevel = 4
+
+// processPriority 进程的 CPU 和 I/O 优先级
+type processPriority struct {
+	Nice    *int    // nil 表示不修改
+	IOClass ioClass // ioClassNone 表示不修改
+	IOLevel int     // 0-7，数字越小优先级越高，idle 类别没有级别
+}
+
+// parseIONice 解析 I/O 调度类别，如 idle、best-effort、best-effort:7、realtime:0
+func parseIONice(s string) (ioClass, int, error) {
+	name, levelStr, hasLevel := strings.Cut(strings.ToLower(strings.TrimSpace(s)), ":")
+	var class ioClass
+	switch name {
+	case "idle":
+		class = ioClassIdle
+	case "best-effort":
+		class = ioClassBestEffort
+	case "realtime":
+		class = ioClassRealtime
+	default:
+		return ioClassNone, 0, fmt.Errorf(tr("无效的 I/O 调度类别 %q，可选 idle、best-effort[:0-7]、realtime[:0-7]"), s)
+	}
+
+	if !hasLevel {
+		if class == ioClassIdle {
+			return class, 0, nil
+		}
+		return class, defaultIOLevel, nil
+	}
+	level, err := strconv.Atoi(levelStr)
+	if err != nil || level < 0 || level > 7 || class == ioClassIdle {
+		return ioClassNone, 0, fmt.Errorf(tr("无效的 I/O 调度类别 %q，可选 idle、best-effort[:0-7]、realtime[:0-7]"), s)
+	}
+	return class, level, nil
+}
+
+// priorityFromFlags 根据 --nice 和 --ionice 创建进程优先级设置
+func priorityFromFlags(cmd *cobra.Command) (processPriority, error) {
+	var p processPriority
+	if cmd.Flags().Changed("nice") {
+		nice, _ := cmd.Flags().GetInt("nice")
+		if nice < -20 || nice > 19 {
+			return p, fmt.Errorf(tr("nice 值应在 -20 到 19 之间: %d"), nice)
+		}
+		p.Nice = &nice
+	}
+	if ionice, _ := cmd.Flags().GetString("ionice"); ionice != "" {
+		var err error
+		if p.IOClass, p.IOLevel, err = parseIONice(ionice); err != nil {
+			return p, err
+		}
+	}
+	return p, nil
+}
+
+// apply 设置当前进程的优先级，在开始处理文件前调用
+func (p processPriority) apply() error {
+	if p.Nice != nil {
+		if err := setNice(*p.Nice); err != nil {
+			return fmt.Errorf(tr("设置 nice 值失败: %w"), err)
+		}
+	}
+	if p.IOClass != ioClassNone {
+		if err := setIOPriority(p.IOClass, p.IOLevel); err != nil {
+			return fmt.Errorf(tr("设置 I/O 优先级失败: %w"), err)
+		}
+	}
+	return nil
+}
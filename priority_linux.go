@@ -0,0 +1,51 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"syscall"
+)
+
+const (
+	ioprioWhoProcess = 1  // IOPRIO_WHO_PROCESS
+	ioprioClassShift = 13 // IOPRIO_CLASS_SHIFT
+)
+
+// eachThread 对进程的每个线程执行 fn。Linux 上 nice 值和 I/O 优先级属于线程，
+// 新线程继承创建它的线程的设置，所以需要修改所有已有的线程
+func eachThread(fn func(tid int) error) error {
+	entries, err := os.ReadDir("/proc/self/task")
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		tid, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		// 线程可能已经退出
+		if err := fn(tid); err != nil && !errors.Is(err, syscall.ESRCH) {
+			return err
+		}
+	}
+	return nil
+}
+
+// setNice 设置进程的 CPU nice 值
+func setNice(n int) error {
+	return eachThread(func(tid int) error {
+		return syscall.Setpriority(syscall.PRIO_PROCESS, tid, n)
+	})
+}
+
+// setIOPriority 通过 ioprio_set 设置进程的 I/O 调度类别和级别
+func setIOPriority(class ioClass, level int) error {
+	prio := uintptr(class)<<ioprioClassShift | uintptr(level)
+	return eachThread(func(tid int) error {
+		if _, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_SET, ioprioWhoProcess, uintptr(tid), prio); errno != 0 {
+			return errno
+		}
+		return nil
+	})
+}
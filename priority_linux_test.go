@@ -0,0 +1,37 @@
+package main
+
+import (
+	"syscall"
+	"testing"
+)
+
+// Test_setPriority 设置为当前的 nice 值和与之对应的 best-effort 级别，不改变测试进程的调度，
+// 检查所有线程都已设置
+func Test_setPriority(t *testing.T) {
+	// Linux 的 getpriority 系统调用返回 20 - nice
+	prio, err := syscall.Getpriority(syscall.PRIO_PROCESS, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	nice := 20 - prio
+	level := (nice + 20) / 5
+
+	if err := (processPriority{Nice: &nice, IOClass: ioClassBestEffort, IOLevel: level}).apply(); err != nil {
+		t.Fatalf("apply() error = %v", err)
+	}
+
+	want := uintptr(ioClassBestEffort)<<ioprioClassShift | uintptr(level)
+	err = eachThread(func(tid int) error {
+		if p, err := syscall.Getpriority(syscall.PRIO_PROCESS, tid); err == nil && 20-p != nice {
+			t.Errorf("thread %d nice = %d, want %d", tid, 20-p, nice)
+		}
+		got, _, errno := syscall.Syscall(syscall.SYS_IOPRIO_GET, ioprioWhoProcess, uintptr(tid), 0)
+		if errno == 0 && got != want {
+			t.Errorf("thread %d ioprio = %#x, want %#x", tid, got, want)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,18 @@
+//go:build unix && !linux
+
+package main
+
+import (
+	"errors"
+	"syscall"
+)
+
+// setNice 设置进程的 CPU nice 值
+func setNice(n int) error {
+	return syscall.Setpriority(syscall.PRIO_PROCESS, 0, n)
+}
+
+// setIOPriority 只有 Linux 支持设置 I/O 调度类别
+func setIOPriority(class ioClass, level int) error {
+	return errors.New(tr("当前系统不支持设置 I/O 优先级"))
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+func Test_parseIONice(t *testing.T) {
+	tests := []struct {
+		s         string
+		wantClass ioClass
+		wantLevel int
+		wantErr   bool
+	}{
+		{s: "idle", wantClass: ioClassIdle},
+		{s: "best-effort", wantClass: ioClassBestEffort, wantLevel: defaultIOLevel},
+		{s: "best-effort:7", wantClass: ioClassBestEffort, wantLevel: 7},
+		{s: "Realtime:0", wantClass: ioClassRealtime, wantLevel: 0},
+		{s: "best-effort:8", wantErr: true},
+		{s: "idle:3", wantErr: true},
+		{s: "lazy", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.s, func(t *testing.T) {
+			class, level, err := parseIONice(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseIONice() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if class != tt.wantClass || level != tt.wantLevel {
+				t.Errorf("parseIONice() = %v, %v, want %v, %v", class, level, tt.wantClass, tt.wantLevel)
+			}
+		})
+	}
+}
+
+func Test_priorityFromFlags(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		wantNice *int
+		wantIO   ioClass
+		wantErr  bool
+	}{
+		{name: "unchanged"},
+		{name: "nice zero", args: []string{"--nice", "0"}, wantNice: new(int)},
+		{name: "ionice", args: []string{"--ionice", "idle"}, wantIO: ioClassIdle},
+		{name: "nice out of range", args: []string{"--nice", "20"}, wantErr: true},
+		{name: "invalid ionice", args: []string{"--ionice", "x"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &cobra.Command{}
+			addThrottleFlags(cmd)
+			if err := cmd.ParseFlags(tt.args); err != nil {
+				t.Fatal(err)
+			}
+			p, err := priorityFromFlags(cmd)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("priorityFromFlags() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if (p.Nice == nil) != (tt.wantNice == nil) || (p.Nice != nil && *p.Nice != *tt.wantNice) {
+				t.Errorf("Nice = %v, want %v", p.Nice, tt.wantNice)
+			}
+			if p.IOClass != tt.wantIO {
+				t.Errorf("IOClass = %v, want %v", p.IOClass, tt.wantIO)
+			}
+		})
+	}
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package main
+
+import "errors"
+
+// setNice 当前系统没有 nice 值
+func setNice(n int) error {
+	return errors.New(tr("当前系统不支持设置 nice 值"))
+}
+
+// setIOPriority 只有 Linux 支持设置 I/O 调度类别
+func setIOPriority(class ioClass, level int) error {
+	return errors.New(tr("当前系统不支持设置 I/O 优先级"))
+}
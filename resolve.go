@@ -0,0 +1,313 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// envPattern 匹配 ${VAR}、${VAR:-default} 和转义的 $$
+var envPattern = regexp.MustCompile(`\$\$\{?|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// resolveConfig 读取配置文件并展开 include、profile、环境变量和 backup_paths 中的通配符。
+// 返回的 data 在解析结果与原文件一致时为原文件内容，否则为解析后的配置。
+func resolveConfig(path, profile string) (*Config, []byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	plain, err := decodeConfig(path, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cfg, err := readConfigTree(path, data, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := applyProfile(cfg, profile); err != nil {
+		return nil, nil, err
+	}
+	if err := expandConfigEnv(cfg); err != nil {
+		return nil, nil, err
+	}
+	if cfg.BackupPaths, err = expandBackupPaths(cfg.BackupPaths); err != nil {
+		return nil, nil, err
+	}
+
+	if reflect.DeepEqual(cfg, plain) {
+		return cfg, data, nil
+	}
+	resolved, err := yaml.Marshal(cfg)
+	if err != nil {
+		return nil, nil, fmt.Errorf(tr("序列化配置失败: %w"), err)
+	}
+	return cfg, resolved, nil
+}
+
+// readConfigTree 解析配置文件及其 include 的文件，被包含的配置先合并，当前文件最后合并。
+// stack 为正在读取的文件，用于检测循环包含。
+func readConfigTree(path string, data []byte, stack []string) (*Config, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf(tr("获取绝对路径失败 (%s): %w"), path, err)
+	}
+	if slices.Contains(stack, absPath) {
+		return nil, fmt.Errorf(tr("配置文件循环包含: %s"), strings.Join(append(stack, absPath), " -> "))
+	}
+	stack = append(stack, absPath)
+
+	if data == nil {
+		if data, err = os.ReadFile(path); err != nil {
+			return nil, fmt.Errorf(tr("读取配置文件失败 (%s): %w"), path, err)
+		}
+	}
+
+	own, err := decodeConfig(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	for _, pattern := range own.Include {
+		files, err := includeFiles(filepath.Dir(absPath), pattern)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		for _, file := range files {
+			included, err := readConfigTree(file, nil, stack)
+			if err != nil {
+				return nil, err
+			}
+			mergeConfig(cfg, included)
+		}
+	}
+
+	own.Include = nil
+	mergeConfig(cfg, own)
+	return cfg, nil
+}
+
+// includeFiles 展开 include 条目，相对路径相对于所在配置文件的目录，支持通配符
+func includeFiles(dir, pattern string) ([]string, error) {
+	pattern, err := expandEnv(pattern, true)
+	if err != nil {
+		return nil, fmt.Errorf("include: %w", err)
+	}
+	if !filepath.IsAbs(pattern) {
+		pattern = filepath.Join(dir, pattern)
+	}
+	if !hasGlobMeta(pattern) {
+		return []string{pattern}, nil
+	}
+
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf(tr("无效的 include 模式 (%s): %w"), pattern, err)
+	}
+	return files, nil
+}
+
+// applyProfile 将指定 profile 合并到基础配置上，并清除 profiles
+func applyProfile(cfg *Config, name string) error {
+	profiles := cfg.Profiles
+	cfg.Profiles = nil
+	if name == "" {
+		return nil
+	}
+
+	p, ok := profiles[name]
+	if !ok {
+		names := make([]string, 0, len(profiles))
+		for n := range profiles {
+			names = append(names, n)
+		}
+		slices.Sort(names)
+		return fmt.Errorf(tr("未找到 profile: %s (可选 %s)"), name, strings.Join(names, ", "))
+	}
+	if len(p.Include) > 0 || len(p.Profiles) > 0 {
+		return fmt.Errorf(tr("profile %s 中不支持 include 和 profiles"), name)
+	}
+	mergeConfig(cfg, &p)
+	return nil
+}
+
+// mergeConfig 将 src 合并到 dst：列表追加，映射按键覆盖，其他字段在 src 非零值时覆盖
+func mergeConfig(dst, src *Config) {
+	mergeValue(reflect.ValueOf(dst).Elem(), reflect.ValueOf(src).Elem())
+}
+
+func mergeValue(dst, src reflect.Value) {
+	switch src.Kind() {
+	case reflect.Struct:
+		for i := range src.NumField() {
+			mergeValue(dst.Field(i), src.Field(i))
+		}
+	case reflect.Slice:
+		if src.Len() > 0 {
+			dst.Set(reflect.AppendSlice(dst, src))
+		}
+	case reflect.Map:
+		if src.Len() == 0 {
+			return
+		}
+		if dst.IsNil() {
+			dst.Set(reflect.MakeMap(src.Type()))
+		}
+		for iter := src.MapRange(); iter.Next(); {
+			dst.SetMapIndex(iter.Key(), iter.Value())
+		}
+	default:
+		if !src.IsZero() {
+			dst.Set(src)
+		}
+	}
+}
+
+// expandConfigEnv 展开路径和快照配置中的环境变量。脚本原样保存到备份包，
+// ${VAR:-default} 是脚本自身的 shell 语法，执行时由 shell 从环境中读取变量
+func expandConfigEnv(cfg *Config) error {
+	var err error
+	for i := range cfg.BackupPaths {
+		bp := &cfg.BackupPaths[i]
+		if bp.Path, err = expandEnv(bp.Path, true); err != nil {
+			return fmt.Errorf("backup_paths: %w", err)
+		}
+		if bp.ArchivePrefix, err = expandEnv(bp.ArchivePrefix, true); err != nil {
+			return fmt.Errorf("archive_prefix: %w", err)
+		}
+	}
+
+	if len(cfg.PathRules) > 0 {
+		rules := make(map[string][]string, len(cfg.PathRules))
+		for p, lines := range cfg.PathRules {
+			expanded, err := expandEnv(p, true)
+			if err != nil {
+				return fmt.Errorf("path_rules: %w", err)
+			}
+			rules[expanded] = lines
+		}
+		cfg.PathRules = rules
+	}
+
+	for i := range cfg.Snapshot {
+		s := &cfg.Snapshot[i]
+		for _, p := range []*string{&s.Path, &s.Mount, &s.Volume} {
+			if *p, err = expandEnv(*p, true); err != nil {
+				return fmt.Errorf("snapshot: %w", err)
+			}
+		}
+		for _, p := range []*string{&s.Create, &s.Remove} {
+			if *p, err = expandEnv(*p, false); err != nil {
+				return fmt.Errorf("snapshot: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// expandEnv 展开 ${VAR} 和 ${VAR:-default}，$${ 表示字面的 ${。
+// strict 为 true 时未设置且没有默认值的变量返回错误，$$ 表示字面的 $；否则变量保留原样，
+// $$ 也保持不变，快照命令中的 $$ 是 shell 的进程号。
+func expandEnv(s string, strict bool) (string, error) {
+	var err error
+	result := envPattern.ReplaceAllStringFunc(s, func(m string) string {
+		switch {
+		case m == "$${":
+			return "${"
+		case m == "$$" && strict:
+			return "$"
+		case m == "$$":
+			return m
+		}
+		sub := envPattern.FindStringSubmatch(m)
+		name, hasDefault, def := sub[1], sub[2] != "", sub[3]
+
+		if v := os.Getenv(name); v != "" {
+			return v
+		}
+		if hasDefault {
+			return def
+		}
+		if _, ok := os.LookupEnv(name); ok {
+			return ""
+		}
+		if strict && err == nil {
+			err = fmt.Errorf(tr("环境变量未设置: %s"), name)
+		}
+		return m
+	})
+	return result, err
+}
+
+// expandBackupPaths 展开 backup_paths 中的通配符，如 /home/*/.ssh。
+// 匹配到的每个路径在备份包内保留相对于通配符前固定部分的目录结构，避免同名路径冲突。
+func expandBackupPaths(paths []BackupPath) ([]BackupPath, error) {
+	var result []BackupPath
+	for _, bp := range paths {
+		if !hasGlobMeta(bp.Path) {
+			result = append(result, bp)
+			continue
+		}
+
+		matches, err := filepath.Glob(bp.Path)
+		if err != nil {
+			return nil, fmt.Errorf(tr("无效的备份路径模式 (%s): %w"), bp.Path, err)
+		}
+		if len(matches) == 0 {
+			if bp.Required {
+				return nil, fmt.Errorf(tr("必需的备份路径没有匹配项 (%s)"), bp.Path)
+			}
+			slog.Warn(tr("备份路径没有匹配项"), "path", bp.Path)
+			continue
+		}
+
+		base := globBase(bp.Path)
+		for _, match := range matches {
+			expanded := bp
+			expanded.Path = match
+			expanded.ArchivePrefix = globPrefix(bp.ArchivePrefix, base, match)
+			result = append(result, expanded)
+		}
+	}
+	return result, nil
+}
+
+// globBase 返回模式中第一个通配符之前的目录
+func globBase(pattern string) string {
+	base := pattern
+	for hasGlobMeta(base) {
+		base = filepath.Dir(base)
+	}
+	return base
+}
+
+// globPrefix 计算通配符匹配项的 archive_prefix：目录保留相对路径，文件保留相对路径的上级目录
+func globPrefix(prefix, base, match string) string {
+	rel, err := filepath.Rel(base, match)
+	if err != nil {
+		return prefix
+	}
+	if info, err := os.Stat(match); err == nil && !info.IsDir() {
+		rel = filepath.Dir(rel)
+	}
+
+	if prefix == "" && (rel == "." || rel == filepath.Base(match)) {
+		return "" // 与不使用通配符时相同
+	}
+	return filepath.Join(prefix, rel)
+}
+
+// hasGlobMeta 路径是否包含通配符
+func hasGlobMeta(path string) bool {
+	return strings.ContainsAny(path, `*?[`)
+}
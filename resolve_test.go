@@ -0,0 +1,209 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_expandEnv(t *testing.T) {
+	t.Setenv("BT_HOME", "/home/backup")
+	t.Setenv("BT_EMPTY", "")
+
+	tests := []struct {
+		name    string
+		s       string
+		strict  bool
+		want    string
+		wantErr bool
+	}{
+		{name: "set", s: "${BT_HOME}/.ssh", strict: true, want: "/home/backup/.ssh"},
+		{name: "default", s: "${BT_UNSET:-/srv}/www", strict: true, want: "/srv/www"},
+		{name: "empty uses default", s: "${BT_EMPTY:-x}", strict: true, want: "x"},
+		{name: "empty without default", s: "a${BT_EMPTY}b", strict: true, want: "ab"},
+		{name: "escaped", s: "$${BT_HOME}", strict: true, want: "${BT_HOME}"},
+		{name: "plain dollar untouched", s: "echo $HOME", strict: true, want: "echo $HOME"},
+		{name: "unset strict", s: "${BT_UNSET}", strict: true, wantErr: true},
+		{name: "unset in script", s: "echo ${BT_UNSET}", strict: false, want: "echo ${BT_UNSET}"},
+		{name: "pid in script", s: "echo $$ > /run/x.pid; kill $$", strict: false, want: "echo $$ > /run/x.pid; kill $$"},
+		{name: "escaped in script", s: "echo $${BT_HOME} $$", strict: false, want: "echo ${BT_HOME} $$"},
+		{name: "dollar in path", s: "/srv/a$$b", strict: true, want: "/srv/a$b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := expandEnv(tt.s, tt.strict)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("expandEnv() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("expandEnv() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func Test_resolveConfig(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("BT_WEB_ROOT", "/srv/www")
+	writeTestFiles(t, dir, map[string]string{
+		"common.yaml": "exclude_files: [\"*.tmp\"]\ncompression:\n  algorithm: zstd\n",
+		"conf.d/nginx.yaml": "profiles:\n  nginx:\n    backup_paths: [/etc/nginx]\n" +
+			"    before_script: systemctl stop nginx\n",
+		"config.yaml": "include: [common.yaml, \"conf.d/*.yaml\"]\n" +
+			"backup_paths:\n  - ${BT_WEB_ROOT}\n" +
+			"exclude_files: [\"*.log\"]\n" +
+			"compression:\n  level: 3\n",
+	})
+
+	tests := []struct {
+		name    string
+		profile string
+		want    *Config
+		wantErr bool
+	}{
+		{
+			name: "base",
+			want: &Config{
+				BackupPaths:  []BackupPath{{Path: "/srv/www"}},
+				ExcludeFiles: []string{"*.tmp", "*.log"},
+				Compression:  CompressionConfig{Algorithm: compressionZstd, Level: 3},
+			},
+		},
+		{
+			name:    "profile",
+			profile: "nginx",
+			want: &Config{
+				BackupPaths:  []BackupPath{{Path: "/srv/www"}, {Path: "/etc/nginx"}},
+				ExcludeFiles: []string{"*.tmp", "*.log"},
+				Compression:  CompressionConfig{Algorithm: compressionZstd, Level: 3},
+				BeforeScript: "systemctl stop nginx",
+			},
+		},
+		{name: "unknown profile", profile: "mysql", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, data, err := resolveConfig(filepath.Join(dir, "config.yaml"), tt.profile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("resolveConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveConfig() = %+v, want %+v", got, tt.want)
+			}
+			if strings.Contains(string(data), "include") || strings.Contains(string(data), "${") {
+				t.Errorf("resolveConfig() data is not resolved:\n%s", data)
+			}
+		})
+	}
+}
+
+func Test_resolveConfig_cycle(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"a.yaml": "include: [b.yaml]\n",
+		"b.yaml": "include: [a.yaml]\n",
+	})
+	if _, _, err := resolveConfig(filepath.Join(dir, "a.yaml"), ""); err == nil {
+		t.Errorf("resolveConfig() expected error for include cycle")
+	}
+}
+
+func Test_resolveConfig_scriptPID(t *testing.T) {
+	dir := t.TempDir()
+	script := "echo $$ > /run/x.pid\nkill $$\n"
+	writeTestFiles(t, dir, map[string]string{
+		"config.yaml": "backup_paths: [/srv]\nbefore_script: |\n  echo $$ > /run/x.pid\n  kill $$\n",
+	})
+	cfg, data, err := resolveConfig(filepath.Join(dir, "config.yaml"), "")
+	if err != nil {
+		t.Fatalf("resolveConfig() error = %v", err)
+	}
+	if cfg.BeforeScript != script {
+		t.Errorf("BeforeScript = %q, want %q", cfg.BeforeScript, script)
+	}
+	if !strings.Contains(string(data), "kill $$") {
+		t.Errorf("resolveConfig() data changed the script:\n%s", data)
+	}
+}
+
+func Test_resolveConfig_scriptUnexpanded(t *testing.T) {
+	t.Setenv("BT_HOME", "/home/backup")
+	dir := t.TempDir()
+	script := "DIR=/srv; tar c ${DIR:-/tmp} ${BT_HOME}\n"
+	writeTestFiles(t, dir, map[string]string{
+		"config.yaml": "backup_paths: [/srv]\nafter_script: |\n  DIR=/srv; tar c ${DIR:-/tmp} ${BT_HOME}\n",
+	})
+	cfg, data, err := resolveConfig(filepath.Join(dir, "config.yaml"), "")
+	if err != nil {
+		t.Fatalf("resolveConfig() error = %v", err)
+	}
+	if cfg.AfterScript != script {
+		t.Errorf("AfterScript = %q, want %q", cfg.AfterScript, script)
+	}
+	if !strings.Contains(string(data), "${DIR:-/tmp} ${BT_HOME}") {
+		t.Errorf("resolveConfig() data changed the script:\n%s", data)
+	}
+}
+
+func Test_resolveConfig_unchanged(t *testing.T) {
+	data, _ := os.ReadFile("testdata/config.yaml")
+	_, got, err := resolveConfig("testdata/config.yaml", "")
+	if err != nil {
+		t.Fatalf("resolveConfig() error = %v", err)
+	}
+	if string(got) != string(data) {
+		t.Errorf("resolveConfig() should keep the original file when nothing is resolved")
+	}
+}
+
+func Test_expandBackupPaths(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"home/alice/.ssh/id_ed25519": "a",
+		"home/bob/.ssh/id_ed25519":   "b",
+		"home/bob/.bashrc":           "b",
+		"etc/app.conf":               "c",
+	})
+
+	got, err := expandBackupPaths([]BackupPath{
+		{Path: filepath.Join(dir, "home/*/.ssh")},
+		{Path: filepath.Join(dir, "home/*/.bashrc"), ArchivePrefix: "rc"},
+		{Path: filepath.Join(dir, "etc/*.conf")},
+		{Path: filepath.Join(dir, "missing/*")},
+	})
+	if err != nil {
+		t.Fatalf("expandBackupPaths() error = %v", err)
+	}
+
+	want := []BackupPath{
+		{Path: filepath.Join(dir, "home/alice/.ssh"), ArchivePrefix: "alice/.ssh"},
+		{Path: filepath.Join(dir, "home/bob/.ssh"), ArchivePrefix: "bob/.ssh"},
+		{Path: filepath.Join(dir, "home/bob/.bashrc"), ArchivePrefix: "rc/bob"},
+		{Path: filepath.Join(dir, "etc/app.conf")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expandBackupPaths() = %+v, want %+v", got, want)
+	}
+
+	if _, err := expandBackupPaths([]BackupPath{{Path: filepath.Join(dir, "missing/*"), Required: true}}); err == nil {
+		t.Errorf("expandBackupPaths() expected error for required pattern without matches")
+	}
+}
@@ -1,20 +1,19 @@
 package main
 
 import (
-	"archive/zip"
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"io"
-	"log"
+	"io/fs"
+	"log/slog"
 	"os"
 	"path/filepath"
-	"runtime"
-	"sort"
+	"sync/atomic"
 	"time"
 
 	"github.com/goccy/go-yaml"
-	"github.com/klauspost/compress/flate"
-	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/cobra"
 	"golang.org/x/sync/errgroup"
 )
@@ -30,7 +29,7 @@ var restoreCmd = &cobra.Command{
 			inputPath = args[0]
 		}
 		if inputPath == "" {
-			return fmt.Errorf("必须提供备份文件路径")
+			return errors.New(tr("必须提供备份文件路径"))
 		}
 
 		cmd.Flags().Set("input", inputPath)
@@ -43,8 +42,33 @@ var restoreCmd = &cobra.Command{
 		backupBeforeRestore, _ := cmd.Flags().GetBool("backup-before-restore")
 		script, _ := cmd.Flags().GetBool("script")
 		quiet, _ := cmd.Flags().GetBool("quiet")
+		metricsPath, _ := cmd.Flags().GetString("metrics-textfile")
 
-		if err := restore(cmd.Context(), inputPath, rootDir, backupBeforeRestore, script, quiet); err != nil {
+		throttle, err := throttleFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		priority, err := priorityFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		if err := priority.apply(); err != nil {
+			cmd.SilenceUsage = true
+			return err
+		}
+
+		metrics := newRunMetrics(operationRestore, "")
+		opts := restoreOptions{RootDir: rootDir, BackupBeforeRestore: backupBeforeRestore, Script: script, Quiet: quiet, Metrics: metrics, Throttle: throttle}
+		err = restore(cmd.Context(), inputPath, opts)
+		metrics.finish(err)
+		result.Input, result.RootDir = inputPath, rootDir
+		result.setRun(metrics)
+		if metricsPath != "" {
+			if merr := recordMetricsTextfile(metricsPath, metrics); merr != nil {
+				slog.Error(tr("写入指标文件失败"), "path", metricsPath, "error", merr)
+			}
+		}
+		if err != nil {
 			cmd.SilenceUsage = true
 			return err
 		}
@@ -57,251 +81,339 @@ func init() {
 	restoreCmd.Flags().StringP("root-dir", "r", "/", "还原根目录")
 	restoreCmd.Flags().BoolP("backup-before-restore", "b", false, "还原前备份，保留最近3个备份")
 	restoreCmd.Flags().BoolP("script", "s", true, "执行脚本")
+	restoreCmd.Flags().String("metrics-textfile", "", "还原结束后写入 Prometheus 指标的文件，供 node_exporter textfile collector 读取")
+	addThrottleFlags(restoreCmd)
 
 	rootCmd.AddCommand(restoreCmd)
 }
 
+// restoreOptions 还原选项
+type restoreOptions struct {
+	RootDir             string
+	BackupBeforeRestore bool
+	Script              bool
+	Quiet               bool
+	Metrics             *runMetrics // 不为 nil 时记录本次还原的统计
+	Throttle            ioThrottle  // 并发数和读写限速，还原前备份使用相同的设置
+}
+
+// restoreStats 一次还原的统计
+type restoreStats struct {
+	files   atomic.Int64
+	bytes   atomic.Int64
+	skipped atomic.Int64 // 文件映射中没有的条目
+	failed  atomic.Int64
+}
+
+// record 将统计写入指标
+func (s *restoreStats) record(m *runMetrics) {
+	m.Files, m.Bytes = s.files.Load(), s.bytes.Load()
+	m.SkippedFiles, m.Failed = s.skipped.Load(), s.failed.Load()
+}
+
 // restore 执行还原操作
-func restore(ctx context.Context, zipPath string, rootDir string, backupBeforeRestore, script, quiet bool) error {
+func restore(ctx context.Context, zipPath string, opts restoreOptions) error {
 	// 打开备份文件
-	r, err := zip.OpenReader(zipPath)
+	ar, err := openArchive(zipPath)
 	if err != nil {
-		return fmt.Errorf("打开备份文件失败 (%s): %w", zipPath, err)
+		return err
 	}
-	defer r.Close()
-
-	r.RegisterDecompressor(zip.Deflate, func(r io.Reader) io.ReadCloser {
-		return flate.NewReader(r)
-	})
+	defer ar.Close()
 
 	// 读取配置和文件映射
-	cfg, fileMap, err := readBackupMetadata(r.File)
+	cfg, fileMap, err := readBackupMetadata(ar)
 	if err != nil {
 		return err
 	}
 
 	// 还原前备份
-	if backupBeforeRestore {
-		if err := backupBeforeRestoreAction(ctx, cfg, quiet); err != nil {
+	if opts.BackupBeforeRestore {
+		if err := backupBeforeRestoreAction(ctx, cfg, opts.Quiet, opts.Throttle); err != nil {
 			return err
 		}
 	}
 
 	// 设置根目录
 	for k := range fileMap {
-		fileMap[k] = filepath.Join(rootDir, fileMap[k])
+		fileMap[k] = filepath.Join(opts.RootDir, fileMap[k])
 	}
 
 	// 执行还原前脚本
-	if script && cfg.BeforeScript != "" {
-		result, err := runCommand("sh", "-c", cfg.BeforeScript)
-		if err != nil {
-			return fmt.Errorf("执行还原前脚本失败: %w", err)
+	if opts.Script {
+		if err := runBeforeRestoreScript(cfg); err != nil {
+			return err
 		}
-		log.Printf("还原前脚本输出:\n%s", result)
-	}
-
-	// 获取需要还原的文件列表
-	filesToRestore := getFilesToRestore(r.File)
-	if len(filesToRestore) == 0 {
-		return fmt.Errorf("备份文件中没有找到可还原的文件")
 	}
 
 	// 初始化进度条
-	bar := newProgressBar(int64(len(filesToRestore)), quiet, "正在还原")
+	bar := newProgressBar(int64(len(fileMap)), opts.Quiet, tr("正在还原"))
 
 	// 并发还原文件
-	if err := restoreFilesConcurrently(ctx, filesToRestore, fileMap, bar); err != nil {
+	var stats restoreStats
+	err = restoreFilesConcurrently(ctx, ar, fileMap, nil, bar, &stats, opts.Throttle)
+	if opts.Metrics != nil {
+		stats.record(opts.Metrics)
+	}
+	if err != nil {
 		return err
 	}
 
-	bar.Describe("还原完成")
+	bar.Describe(tr("还原完成"))
+	slog.Info(tr("还原完成"), "input", zipPath, "root_dir", opts.RootDir,
+		"files", stats.files.Load(), "bytes", stats.bytes.Load(), "skipped", stats.skipped.Load())
 
 	// 执行还原后脚本
-	if script && cfg.AfterScript != "" {
-		result, err := runCommand("sh", "-c", cfg.AfterScript)
-		if err != nil {
-			return fmt.Errorf("执行还原后脚本失败: %w", err)
-		}
-		log.Printf("还原后脚本输出:\n%s", result)
+	if opts.Script {
+		return runAfterRestoreScript(cfg)
 	}
-
 	return nil
 }
 
-// readBackupMetadata 读取备份文件的元数据（配置和文件映射）
-func readBackupMetadata(files []*zip.File) (*Config, FileMap, error) {
-	var cfg Config
-	var fileMap FileMap
-
-	if err := readYAMLFromZip(files, backupConfigName, &cfg); err != nil {
-		return nil, nil, fmt.Errorf("读取 %s 失败: %w", backupConfigName, err)
+// runBeforeRestoreScript 执行备份包中的还原前脚本
+func runBeforeRestoreScript(cfg *Config) error {
+	if cfg.BeforeScript == "" {
+		return nil
 	}
-
-	if err := readYAMLFromZip(files, backupFileMapName, &fileMap); err != nil {
-		return nil, nil, fmt.Errorf("读取 %s 失败: %w", backupFileMapName, err)
+	result, err := runCommand("sh", "-c", cfg.BeforeScript)
+	if err != nil {
+		return fmt.Errorf(tr("执行还原前脚本失败: %w"), err)
 	}
+	slog.Info(tr("还原前脚本执行完成"), "script", "before", "output", result)
+	return nil
+}
 
-	if len(fileMap) == 0 {
-		return nil, nil, fmt.Errorf("备份文件缺少 %s，无法还原", backupFileMapName)
+// runAfterRestoreScript 执行备份包中的还原后脚本
+func runAfterRestoreScript(cfg *Config) error {
+	if cfg.AfterScript == "" {
+		return nil
 	}
-
-	return &cfg, fileMap, nil
+	result, err := runCommand("sh", "-c", cfg.AfterScript)
+	if err != nil {
+		return fmt.Errorf(tr("执行还原后脚本失败: %w"), err)
+	}
+	slog.Info(tr("还原后脚本执行完成"), "script", "after", "output", result)
+	return nil
 }
 
-// getFilesToRestore 获取需要还原的文件列表（排除元数据文件）
-func getFilesToRestore(files []*zip.File) []*zip.File {
-	filesToRestore := make([]*zip.File, 0, len(files))
-	for _, f := range files {
-		if f.Name != backupConfigName && f.Name != backupFileMapName {
-			filesToRestore = append(filesToRestore, f)
-		}
+// readBackupMetadata 读取备份文件的元数据（配置和文件映射），旧结构版本自动升级
+func readBackupMetadata(ar archiveReader) (*Config, FileMap, error) {
+	md, err := readArchiveMetadata(ar)
+	if err != nil {
+		return nil, nil, err
 	}
-	return filesToRestore
+	return md.Config, md.FileMap, nil
 }
 
-// restoreFilesConcurrently 并发还原文件
-func restoreFilesConcurrently(ctx context.Context, filesToRestore []*zip.File, fileMap FileMap, bar *progressbar.ProgressBar) error {
-	g, _ := errgroup.WithContext(ctx)
-	sem := make(chan struct{}, runtime.NumCPU()) // 限制并发量
+// isMetadataFile 判断条目是否为备份元数据
+func isMetadataFile(name string) bool {
+	return name == backupConfigName || name == backupFileMapName || name == backupManifestName
+}
 
-	for _, f := range filesToRestore {
-		targetPath, ok := fileMap[f.Name]
-		if !ok {
-			log.Printf("跳过未知文件: %s", f.Name)
-			continue
+// restoreFilesConcurrently 并发还原文件，流式格式按顺序还原。
+// selected 不为空时只还原其中的条目，其他条目直接跳过。还原结果计入 stats。
+func restoreFilesConcurrently(ctx context.Context, ar archiveReader, fileMap FileMap, selected map[string]bool, bar progressReporter, stats *restoreStats, throttle ioThrottle) error {
+	g, gctx := errgroup.WithContext(ctx)
+	sem := make(chan struct{}, throttle.workers()) // 限制并发量
+
+	var restored int
+	err := ar.Walk(func(e *archiveEntry) error {
+		if isMetadataFile(e.Name) {
+			return nil
+		}
+		if err := gctx.Err(); err != nil {
+			return err
+		}
+		if selected != nil && !selected[e.Name] {
+			return nil
 		}
 
-		g.Go(func() error {
-			sem <- struct{}{}        // 获取信号量
-			defer func() { <-sem }() // 释放信号量
+		targetPath, ok := fileMap[e.Name]
+		if !ok {
+			slog.Warn(tr("跳过未知文件"), "entry", e.Name)
+			stats.skipped.Add(1)
+			return nil
+		}
+		restored++
 
-			bar.Describe(fmt.Sprintf("还原 %s", filepath.Base(targetPath)))
+		extract := func() error {
+			bar.Describe(fmt.Sprintf(tr("还原 %s"), filepath.Base(targetPath)))
 
-			if err := extractFile(f, targetPath); err != nil {
-				return fmt.Errorf("还原文件 %s 失败: %w", targetPath, err)
+			if err := extractFile(e, targetPath, throttle); err != nil {
+				stats.failed.Add(1)
+				return fmt.Errorf(tr("还原文件 %s 失败: %w"), targetPath, err)
+			}
+			stats.files.Add(1)
+			if e.Mode.IsRegular() {
+				stats.bytes.Add(e.Size)
 			}
 
 			bar.Add(1)
 			return nil
+		}
+
+		if !ar.RandomAccess() {
+			return extract()
+		}
+
+		g.Go(func() error {
+			sem <- struct{}{}        // 获取信号量
+			defer func() { <-sem }() // 释放信号量
+
+			return extract()
 		})
+		return nil
+	})
+	if gErr := g.Wait(); gErr != nil {
+		return gErr
+	}
+	if err != nil {
+		return err
 	}
 
-	return g.Wait()
+	if restored == 0 {
+		return errors.New(tr("备份文件中没有找到可还原的文件"))
+	}
+	return nil
 }
 
-// readYAMLFromZip 从zip文件中读取并解析YAML数据
-func readYAMLFromZip(files []*zip.File, filename string, out any) error {
-	for _, f := range files {
-		if f.Name == filename {
-			rc, err := f.Open()
-			if err != nil {
-				return fmt.Errorf("打开zip文件 %s 失败: %w", filename, err)
-			}
-			defer rc.Close()
+// unmarshalYAMLFile 解析已读取的YAML条目
+func unmarshalYAMLFile(files map[string][]byte, filename string, out any) error {
+	data, ok := files[filename]
+	if !ok {
+		return fmt.Errorf(tr("未找到文件: %s"), filename)
+	}
 
-			data, err := io.ReadAll(rc)
-			if err != nil {
-				return fmt.Errorf("读取zip文件 %s 内容失败: %w", filename, err)
-			}
+	if err := yaml.Unmarshal(data, out); err != nil {
+		return fmt.Errorf(tr("解析YAML文件 %s 失败: %w"), filename, err)
+	}
+	return nil
+}
 
-			if err := yaml.Unmarshal(data, out); err != nil {
-				return fmt.Errorf("解析YAML文件 %s 失败: %w", filename, err)
-			}
+// extractFile 从备份包中提取文件到目标路径，按 throttle 限制读取条目和写入文件的速率。
+// 还原条目记录的权限和修改时间，已存在的文件和目录同样会被设置，没有记录权限的条目使用默认权限
+func extractFile(e *archiveEntry, targetPath string, throttle ioThrottle) error {
+	perm := e.Mode.Perm()
 
-			return nil
+	// 目录条目只需创建目录
+	if e.Mode.IsDir() {
+		if err := os.MkdirAll(targetPath, cmp.Or(perm, 0755)); err != nil {
+			return fmt.Errorf(tr("创建目录 %s 失败: %w"), targetPath, err)
 		}
+		return restorePerm(targetPath, perm)
+	}
+	if e.Mode&fs.ModeSymlink != 0 {
+		return extractSymlink(e, targetPath)
 	}
-	return fmt.Errorf("未找到文件: %s", filename)
-}
 
-// extractFile 从zip文件中提取文件到目标路径
-func extractFile(f *zip.File, targetPath string) error {
-	// 打开zip文件条目
-	rc, err := f.Open()
+	// 打开备份条目
+	rc, err := e.Open()
 	if err != nil {
-		return fmt.Errorf("打开zip条目 %s 失败: %w", f.Name, err)
+		return fmt.Errorf(tr("打开备份条目 %s 失败: %w"), e.Name, err)
 	}
 	defer rc.Close()
 
 	// 创建目标目录
 	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
-		return fmt.Errorf("创建目录 %s 失败: %w", filepath.Dir(targetPath), err)
+		return fmt.Errorf(tr("创建目录 %s 失败: %w"), filepath.Dir(targetPath), err)
 	}
 
 	// 创建目标文件
-	outFile, err := os.Create(targetPath)
+	outFile, err := os.OpenFile(targetPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, cmp.Or(perm, 0666))
 	if err != nil {
-		return fmt.Errorf("创建文件 %s 失败: %w", targetPath, err)
+		return fmt.Errorf(tr("创建文件 %s 失败: %w"), targetPath, err)
 	}
-	defer outFile.Close()
 
 	// 复制文件内容
-	if _, err := io.Copy(outFile, rc); err != nil {
-		return fmt.Errorf("复制文件内容 %s → %s 失败: %w", f.Name, targetPath, err)
+	_, err = io.Copy(throttle.Write.writer(outFile), throttle.Read.reader(rc))
+	if cerr := outFile.Close(); err == nil {
+		err = cerr
 	}
-
-	return nil
-}
-
-func cleanupOldBackups(dir string, maxBackups int) {
-	files, err := os.ReadDir(dir)
 	if err != nil {
-		log.Printf("读取备份目录失败 (%s): %v", dir, err)
-		return
+		return fmt.Errorf(tr("复制文件内容 %s → %s 失败: %w"), e.Name, targetPath, err)
 	}
 
-	var backups []os.DirEntry
-	for _, file := range files {
-		if !file.IsDir() && filepath.Ext(file.Name()) == ".zip" {
-			backups = append(backups, file)
+	if err := restorePerm(targetPath, perm); err != nil {
+		return err
+	}
+	if !e.ModTime.IsZero() {
+		if err := os.Chtimes(targetPath, e.ModTime, e.ModTime); err != nil {
+			return fmt.Errorf(tr("设置 %s 的修改时间失败: %w"), targetPath, err)
 		}
 	}
+	return nil
+}
 
-	if len(backups) <= maxBackups {
-		return
+// restorePerm 设置还原的文件或目录的权限，已存在的文件创建时不会修改权限，perm 为 0 时保持不变
+func restorePerm(path string, perm fs.FileMode) error {
+	if perm == 0 {
+		return nil
 	}
+	if err := os.Chmod(path, perm); err != nil {
+		return fmt.Errorf(tr("设置 %s 的权限失败: %w"), path, err)
+	}
+	return nil
+}
 
-	// 按文件名排序（假设文件名包含时间戳）
-	sort.Slice(backups, func(i, j int) bool {
-		return backups[i].Name() < backups[j].Name()
-	})
+// extractSymlink 还原符号链接，已存在的同名文件会被替换
+func extractSymlink(e *archiveEntry, targetPath string) error {
+	target, err := readEntry(e.Open)
+	if err != nil {
+		return fmt.Errorf(tr("读取符号链接 %s 失败: %w"), e.Name, err)
+	}
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return fmt.Errorf(tr("创建目录 %s 失败: %w"), filepath.Dir(targetPath), err)
+	}
+	if err := os.Remove(targetPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf(tr("删除已存在的文件 %s 失败: %w"), targetPath, err)
+	}
+	if err := os.Symlink(string(target), targetPath); err != nil {
+		return fmt.Errorf(tr("创建符号链接 %s 失败: %w"), targetPath, err)
+	}
+	return nil
+}
 
-	// 删除旧备份
-	for i := 0; i < len(backups)-maxBackups; i++ {
-		toDelete := filepath.Join(dir, backups[i].Name())
-		if err := os.Remove(toDelete); err != nil {
-			log.Printf("删除旧备份失败 (%s): %v", toDelete, err)
-		} else {
-			log.Printf("已删除旧备份: %s", toDelete)
+// cleanupOldBackups 删除还原前备份目录中的旧备份，只保留最新的 maxBackups 个
+func cleanupOldBackups(dir string, maxBackups int) {
+	decisions, err := pruneBackups(filepath.Join(dir, "*.zip"), nil, RetentionPolicy{KeepLast: maxBackups}, false)
+	for _, d := range decisions {
+		if !d.Keep {
+			slog.Info(tr("已删除旧备份"), "path", d.Path)
 		}
 	}
+	if err != nil {
+		slog.Warn(tr("清理旧备份失败"), "dir", dir, "error", err)
+	}
 }
 
 // backupBeforeRestoreAction 在还原前执行备份操作
-func backupBeforeRestoreAction(ctx context.Context, cfg *Config, quiet bool) error {
+func backupBeforeRestoreAction(ctx context.Context, cfg *Config, quiet bool, throttle ioThrottle) error {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		return fmt.Errorf("获取用户主目录失败: %w", err)
+		return fmt.Errorf(tr("获取用户主目录失败: %w"), err)
 	}
 
 	restoreDirName := filepath.Join(homeDir, ".backup_restore")
 	if err := os.MkdirAll(restoreDirName, 0755); err != nil {
-		return fmt.Errorf("创建还原目录失败: %w", err)
+		return fmt.Errorf(tr("创建还原目录失败: %w"), err)
 	}
 
 	backupPath := filepath.Join(restoreDirName, fmt.Sprintf("restore_%s.zip", time.Now().Format("20060102150405")))
-	log.Printf("正在还原前备份当前文件，备份文件: %s", backupPath)
+	slog.Info(tr("正在还原前备份当前文件"), "output", backupPath)
+
+	// 备份包中的快照和通知配置属于原来的主机，还原前备份直接读取当前文件，也不发送通知
+	local := *cfg
+	local.Snapshot = nil
+	local.Notify = NotifyConfig{}
 
-	configBytes, err := yaml.Marshal(cfg)
+	configBytes, err := yaml.Marshal(&local)
 	if err != nil {
-		return fmt.Errorf("序列化配置失败: %w", err)
+		return fmt.Errorf(tr("序列化配置失败: %w"), err)
 	}
 
-	if err := backup(ctx, cfg, configBytes, backupPath, quiet); err != nil {
-		return fmt.Errorf("还原前备份失败: %w", err)
+	if err := backup(ctx, &local, configBytes, backupPath, backupOptions{Format: formatZip, Quiet: quiet, Throttle: throttle, DiscardInterrupted: true}); err != nil {
+		return fmt.Errorf(tr("还原前备份失败: %w"), err)
 	}
-	log.Printf("还原前备份完成: %s", backupPath)
+	slog.Info(tr("还原前备份完成"), "output", backupPath)
 	// 删除旧备份，只保留最新的3个
 	cleanupOldBackups(restoreDirName, retainBackupCount)
 
@@ -1,9 +1,12 @@
 package main
 
 import (
+	"io/fs"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
+	"time"
 )
 
 func Test_restore(t *testing.T) {
@@ -43,7 +46,7 @@ func Test_restore(t *testing.T) {
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
 			tempDir := t.TempDir()
-			if err := restore(t.Context(), tt.args.zipPath, tempDir, false, false, true); (err != nil) != tt.wantErr {
+			if err := restore(t.Context(), tt.args.zipPath, restoreOptions{RootDir: tempDir, Quiet: true}); (err != nil) != tt.wantErr {
 				t.Errorf("restore() error = %v, wantErr %v", err, tt.wantErr)
 			}
 			if gotExists := filesMatchContent(tt.args.fileDataMap, tempDir); gotExists != tt.wantExists {
@@ -69,3 +72,104 @@ func filesMatchContent(expectedFiles map[string]string, baseDir string) bool {
 	}
 	return true
 }
+
+func Test_restoreFormats(t *testing.T) {
+	tests := []struct {
+		name        string
+		format      archiveFormat
+		compression string
+	}{
+		{name: "zip", format: formatZip},
+		{name: "zip zstd", format: formatZip, compression: compressionZstd},
+		{name: "tar.zst", format: formatTarZst},
+		{name: "tar.gz", format: formatTarGz},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, configBytes, err := loadConfig("testdata/config.yaml", "")
+			if err != nil {
+				t.Fatalf("loadConfig() error = %v", err)
+			}
+			cfg.Compression.Algorithm = tt.compression
+
+			outputPath := filepath.Join(t.TempDir(), "output."+string(tt.format))
+			if err := backup(t.Context(), cfg, configBytes, outputPath, backupOptions{Format: tt.format, Quiet: true}); err != nil {
+				t.Fatalf("backup() error = %v", err)
+			}
+
+			tempDir := t.TempDir()
+			if err := restore(t.Context(), outputPath, restoreOptions{RootDir: tempDir, Quiet: true}); err != nil {
+				t.Fatalf("restore() error = %v", err)
+			}
+
+			absPath, _ := filepath.Abs("testdata/backup/data1/data5.txt")
+			if !filesMatchContent(map[string]string{absPath: "test5"}, tempDir) {
+				t.Errorf("restore() %s content mismatch", absPath)
+			}
+		})
+	}
+}
+
+func Test_extractFile_metadata(t *testing.T) {
+	modTime := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	for _, format := range []archiveFormat{formatZip, formatTarZst, formatTarGz} {
+		t.Run(string(format), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "backup."+string(format))
+			f, err := os.Create(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			aw, err := newArchiveWriter(f, format, 0, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := aw.Create(&entryHeader{Name: "data/bin", Mode: fs.ModeDir | 0750, ModTime: modTime}); err != nil {
+				t.Fatal(err)
+			}
+			w, err := aw.Create(&entryHeader{Name: "data/bin/run.sh", Size: 9, Mode: 0755, ModTime: modTime})
+			if err != nil {
+				t.Fatal(err)
+			}
+			w.Write([]byte("#!/bin/sh"))
+			if err := aw.Close(); err != nil {
+				t.Fatal(err)
+			}
+			f.Close()
+
+			// 已存在的目录和文件同样设置为条目的权限
+			root := t.TempDir()
+			dir, file := filepath.Join(root, "bin"), filepath.Join(root, "bin", "run.sh")
+			if err := os.Mkdir(dir, 0700); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(file, []byte("old"), 0600); err != nil {
+				t.Fatal(err)
+			}
+
+			ar, err := openArchive(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ar.Close()
+			targets := map[string]string{"data/bin": dir, "data/bin/run.sh": file}
+			if err := ar.Walk(func(e *archiveEntry) error {
+				return extractFile(e, targets[e.Name], ioThrottle{})
+			}); err != nil {
+				t.Fatalf("extractFile() error = %v", err)
+			}
+
+			for target, want := range map[string]fs.FileMode{dir: 0750, file: 0755} {
+				info, err := os.Stat(target)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if runtime.GOOS != "windows" && info.Mode().Perm() != want {
+					t.Errorf("%s mode = %v, want %v", target, info.Mode().Perm(), want)
+				}
+			}
+			if info, _ := os.Stat(file); !info.ModTime().Equal(modTime) {
+				t.Errorf("run.sh mtime = %v, want %v", info.ModTime(), modTime)
+			}
+		})
+	}
+}
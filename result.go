@@ -0,0 +1,195 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+const (
+	outputFormatText = "text"
+	outputFormatJSON = "json"
+)
+
+// 退出码，文本和 JSON 输出相同
+const (
+	exitOK          = 0   // 成功
+	exitFailure     = 1   // 运行失败
+	exitUsage       = 2   // 参数、配置或权限错误，命令没有开始运行
+	exitPartial     = 3   // 备份完成，但有文件备份失败
+	exitInterrupted = 130 // 被 SIGINT 或 SIGTERM 中断
+)
+
+const (
+	statusOK      = "ok"
+	statusPartial = "partial"
+	statusError   = "error"
+)
+
+// commandResult --output-format json 时输出的结果对象，字段名保持稳定，没有值的字段省略
+type commandResult struct {
+	Command         string    `json:"command"` // 如 "backup"、"config export"
+	Status          string    `json:"status"`  // ok、partial 或 error
+	ExitCode        int       `json:"exit_code"`
+	Error           string    `json:"error,omitempty"`
+	StartTime       time.Time `json:"start_time"`
+	EndTime         time.Time `json:"end_time"`
+	DurationSeconds float64   `json:"duration_seconds"`
+
+	Input    string            `json:"input,omitempty"`    // 读取的备份包或配置文件
+	Output   string            `json:"output,omitempty"`   // 写入的备份包或配置文件
+	Volumes  []string          `json:"volumes,omitempty"`  // 分卷路径
+	RootDir  string            `json:"root_dir,omitempty"` // 还原根目录
+	Stats    *resultStats      `json:"stats,omitempty"`
+	Failures []ManifestFailure `json:"failures,omitempty"` // 备份失败的文件，最多 maxManifestFailures 条
+	Script   *resultScript     `json:"script,omitempty"`
+	Changes  []string          `json:"changes,omitempty"`  // config import 的变化
+	Warnings []string          `json:"warnings,omitempty"` // config validate 的警告
+	Manifest *Manifest         `json:"manifest,omitempty"` // info 读取的清单
+	Config   json.RawMessage   `json:"config,omitempty"`   // config resolve 解析后的配置，字段名与 YAML 相同
+	Backups  []resultBackup    `json:"backups,omitempty"`  // prune 的处理结果
+	Files    []string          `json:"files,omitempty"`    // install-timer 写入的 unit 文件
+}
+
+// resultStats 备份或还原的计数
+type resultStats struct {
+	Files        int64 `json:"files"`
+	Bytes        int64 `json:"bytes"`
+	SkippedFiles int64 `json:"skipped_files"`
+	SkippedDirs  int64 `json:"skipped_dirs"`
+	FailedFiles  int64 `json:"failed_files"`
+	ArchiveSize  int64 `json:"archive_size,omitempty"` // 备份包大小，还原时省略
+}
+
+// resultScript 执行的脚本
+type resultScript struct {
+	Type   string `json:"type"` // before 或 after
+	Ran    bool   `json:"ran"`  // 配置中没有脚本时为 false
+	Output string `json:"output,omitempty"`
+}
+
+// resultBackup prune 中单个备份的处理结果
+type resultBackup struct {
+	Path    string    `json:"path"`
+	Time    time.Time `json:"time"`
+	Keep    bool      `json:"keep"`
+	Reasons []string  `json:"reasons,omitempty"`
+}
+
+// result 当前命令的结果，由命令在运行时填写
+var result = &commandResult{}
+
+// setRun 记录备份或还原的计数
+func (r *commandResult) setRun(m *runMetrics) {
+	r.Stats = &resultStats{
+		Files:        m.Files,
+		Bytes:        m.Bytes,
+		SkippedFiles: m.SkippedFiles,
+		SkippedDirs:  m.SkippedDirs,
+		FailedFiles:  m.Failed,
+		ArchiveSize:  m.ArchiveSize,
+	}
+	r.Volumes = m.Volumes
+	r.Failures = m.Failures
+}
+
+// setRetention 记录 prune 的处理结果
+func (r *commandResult) setRetention(decisions []retentionDecision) {
+	r.Backups = make([]resultBackup, 0, len(decisions))
+	for _, d := range decisions {
+		r.Backups = append(r.Backups, resultBackup{Path: d.Path, Time: d.Time, Keep: d.Keep, Reasons: d.Reasons})
+	}
+}
+
+// finish 记录命令、退出码和耗时
+func (r *commandResult) finish(cmd *cobra.Command, start, end time.Time, code int, err error) {
+	if cmd != nil {
+		r.Command = strings.TrimSpace(strings.TrimPrefix(cmd.CommandPath(), cmd.Root().Name()))
+	}
+	r.ExitCode = code
+	switch code {
+	case exitOK:
+		r.Status = statusOK
+	case exitPartial:
+		r.Status = statusPartial
+	default:
+		r.Status = statusError
+	}
+	if err != nil {
+		r.Error = err.Error()
+	}
+	r.StartTime, r.EndTime = start, end
+	r.DurationSeconds = end.Sub(start).Seconds()
+}
+
+// write 输出一行 JSON
+func (r *commandResult) write(w io.Writer) error {
+	return json.NewEncoder(w).Encode(r)
+}
+
+// exitCode 根据命令的错误和结果返回退出码。
+// 命令在开始运行前设置 SilenceUsage，因此未设置时的错误是参数或配置错误
+func exitCode(ctx context.Context, cmd *cobra.Command, err error, r *commandResult) int {
+	switch {
+	case err == nil && r.Stats != nil && r.Stats.FailedFiles > 0:
+		return exitPartial
+	case err == nil:
+		return exitOK
+	case errors.Is(err, context.Canceled) || ctx.Err() != nil:
+		return exitInterrupted
+	case cmd != nil && !cmd.SilenceUsage:
+		return exitUsage
+	default:
+		return exitFailure
+	}
+}
+
+// checkOutputFormat 检查 --output-format 参数
+func checkOutputFormat(cmd *cobra.Command) error {
+	format, _ := cmd.Flags().GetString("output-format")
+	if format != outputFormatText && format != outputFormatJSON {
+		return fmt.Errorf(tr("无效的输出格式 %q，可选 text、json"), format)
+	}
+	return nil
+}
+
+// outputJSON 是否以 JSON 输出结果，此时命令不向标准输出写入文本
+func outputJSON(cmd *cobra.Command) bool {
+	if cmd == nil {
+		return false
+	}
+	format, _ := cmd.Flags().GetString("output-format")
+	return format == outputFormatJSON
+}
+
+// textOutput 返回命令文本输出的位置，JSON 输出时丢弃
+func textOutput(cmd *cobra.Command) io.Writer {
+	if outputJSON(cmd) {
+		return io.Discard
+	}
+	return cmd.OutOrStdout()
+}
+
+// execute 运行命令并返回退出码，--output-format json 时向 stdout 写入结果对象
+func execute(ctx context.Context, args []string, stdout io.Writer) int {
+	result = &commandResult{}
+	start := time.Now()
+
+	rootCmd.SetArgs(args)
+	cmd, err := rootCmd.ExecuteContextC(ctx)
+	code := exitCode(ctx, cmd, err, result)
+
+	if outputJSON(cmd) {
+		result.finish(cmd, start, time.Now(), code, err)
+		if werr := result.write(stdout); werr != nil && code == exitOK {
+			code = exitFailure
+		}
+	}
+	return code
+}
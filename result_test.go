@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func Test_exitCode(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := []struct {
+		name         string
+		ctx          context.Context
+		silenceUsage bool
+		err          error
+		result       commandResult
+		want         int
+	}{
+		{name: "ok", ctx: context.Background(), want: exitOK},
+		{name: "ok with stats", ctx: context.Background(), result: commandResult{Stats: &resultStats{Files: 3}}, want: exitOK},
+		{name: "partial", ctx: context.Background(), result: commandResult{Stats: &resultStats{Files: 3, FailedFiles: 1}}, want: exitPartial},
+		{name: "usage", ctx: context.Background(), err: errors.New("bad flag"), want: exitUsage},
+		{name: "failure", ctx: context.Background(), silenceUsage: true, err: errors.New("disk full"), want: exitFailure},
+		{name: "interrupted", ctx: canceled, silenceUsage: true, err: errors.New("stopped"), want: exitInterrupted},
+		{name: "canceled error", ctx: context.Background(), silenceUsage: true, err: fmt.Errorf("备份中断: %w", context.Canceled), want: exitInterrupted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := &cobra.Command{Use: "backup", SilenceUsage: tt.silenceUsage}
+			if got := exitCode(tt.ctx, cmd, tt.err, &tt.result); got != tt.want {
+				t.Errorf("exitCode() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_commandResult_finish(t *testing.T) {
+	root := &cobra.Command{Use: "backtrack"}
+	parent := &cobra.Command{Use: "config"}
+	sub := &cobra.Command{Use: "export"}
+	root.AddCommand(parent)
+	parent.AddCommand(sub)
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		code       int
+		err        error
+		wantStatus string
+	}{
+		{name: "ok", code: exitOK, wantStatus: statusOK},
+		{name: "partial", code: exitPartial, wantStatus: statusPartial},
+		{name: "error", code: exitFailure, err: errors.New("boom"), wantStatus: statusError},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r commandResult
+			r.finish(sub, start, start.Add(1500*time.Millisecond), tt.code, tt.err)
+
+			if r.Command != "config export" {
+				t.Errorf("Command = %q, want %q", r.Command, "config export")
+			}
+			if r.Status != tt.wantStatus || r.ExitCode != tt.code {
+				t.Errorf("Status, ExitCode = %q, %d, want %q, %d", r.Status, r.ExitCode, tt.wantStatus, tt.code)
+			}
+			if (r.Error != "") != (tt.err != nil) {
+				t.Errorf("Error = %q, want error %v", r.Error, tt.err)
+			}
+			if r.DurationSeconds != 1.5 {
+				t.Errorf("DurationSeconds = %v, want 1.5", r.DurationSeconds)
+			}
+		})
+	}
+}
+
+func Test_execute(t *testing.T) {
+	t.Cleanup(func() { rootCmd.PersistentFlags().Set("output-format", outputFormatText) })
+
+	tests := []struct {
+		name       string
+		args       []string
+		wantCode   int
+		wantFields map[string]any
+	}{
+		{
+			name:       "validate",
+			args:       []string{"config", "validate", "testdata/config.yaml", "--output-format", "json"},
+			wantCode:   exitOK,
+			wantFields: map[string]any{"command": "config validate", "status": statusOK, "input": "testdata/config.yaml"},
+		},
+		{
+			name:       "usage error",
+			args:       []string{"config", "validate", "--output-format", "json"},
+			wantCode:   exitUsage,
+			wantFields: map[string]any{"command": "config validate", "status": statusError, "exit_code": float64(exitUsage)},
+		},
+		{
+			name:       "runtime error",
+			args:       []string{"info", "testdata/missing.zip", "--output-format", "json"},
+			wantCode:   exitFailure,
+			wantFields: map[string]any{"command": "info", "status": statusError, "exit_code": float64(exitFailure)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout bytes.Buffer
+			if code := execute(t.Context(), tt.args, &stdout); code != tt.wantCode {
+				t.Errorf("execute() = %d, want %d", code, tt.wantCode)
+			}
+
+			var got map[string]any
+			if err := json.Unmarshal(stdout.Bytes(), &got); err != nil {
+				t.Fatalf("stdout is not a JSON object: %v\n%s", err, stdout.String())
+			}
+			for k, want := range tt.wantFields {
+				if got[k] != want {
+					t.Errorf("%s = %v, want %v", k, got[k], want)
+				}
+			}
+			for _, k := range []string{"start_time", "end_time", "duration_seconds"} {
+				if _, ok := got[k]; !ok {
+					t.Errorf("missing field %s", k)
+				}
+			}
+		})
+	}
+}
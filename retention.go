@@ -0,0 +1,344 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune <dir>",
+	Short: "按保留策略删除目录中的旧备份",
+	Long: `按保留策略删除目录中的旧备份，各规则保留的备份取并集。
+备份时间取自备份包头部记录的开始时间，旧版本创建的备份包读取 zip 清单，tar 格式和没有清单的使用文件修改时间。
+参数也可以是通配符模式，如 "/backup/etc_*.zip"。`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var p RetentionPolicy
+		p.KeepLast, _ = cmd.Flags().GetInt("keep-last")
+		p.KeepHourly, _ = cmd.Flags().GetInt("keep-hourly")
+		p.KeepDaily, _ = cmd.Flags().GetInt("keep-daily")
+		p.KeepWeekly, _ = cmd.Flags().GetInt("keep-weekly")
+		p.KeepMonthly, _ = cmd.Flags().GetInt("keep-monthly")
+		p.KeepYearly, _ = cmd.Flags().GetInt("keep-yearly")
+		p.KeepWithin, _ = cmd.Flags().GetString("keep-within")
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+
+		if err := p.validate(); err != nil {
+			return err
+		}
+		if p.empty() {
+			return errors.New(tr("至少需要指定一个 --keep-* 保留规则"))
+		}
+
+		pattern := args[0]
+		if info, err := os.Stat(pattern); err == nil && info.IsDir() {
+			pattern = filepath.Join(pattern, "*")
+		}
+
+		cmd.SilenceUsage = true
+		decisions, err := pruneBackups(pattern, nil, p, dryRun)
+		result.Input = pattern
+		result.setRetention(decisions)
+		printRetention(textOutput(cmd), decisions, dryRun)
+		return err
+	},
+}
+
+func init() {
+	pruneCmd.Flags().Int("keep-last", 0, "保留最近的 N 个备份")
+	pruneCmd.Flags().Int("keep-hourly", 0, "最近 N 个小时每小时保留最新的一个")
+	pruneCmd.Flags().Int("keep-daily", 0, "最近 N 天每天保留最新的一个")
+	pruneCmd.Flags().Int("keep-weekly", 0, "最近 N 周每周保留最新的一个")
+	pruneCmd.Flags().Int("keep-monthly", 0, "最近 N 个月每月保留最新的一个")
+	pruneCmd.Flags().Int("keep-yearly", 0, "最近 N 年每年保留最新的一个")
+	pruneCmd.Flags().String("keep-within", "", "保留距最新备份这段时间内的所有备份，如 7d、1y6m")
+	pruneCmd.Flags().BoolP("dry-run", "n", false, "只显示结果，不删除文件")
+
+	rootCmd.AddCommand(pruneCmd)
+}
+
+// RetentionPolicy 备份保留策略，各规则保留的备份取并集，全部为 0 时保留所有备份
+type RetentionPolicy struct {
+	KeepLast    int    `yaml:"keep_last,omitempty"`    // 保留最近的 N 个
+	KeepHourly  int    `yaml:"keep_hourly,omitempty"`  // 最近 N 个小时每小时保留最新的一个
+	KeepDaily   int    `yaml:"keep_daily,omitempty"`   // 最近 N 天每天保留最新的一个
+	KeepWeekly  int    `yaml:"keep_weekly,omitempty"`  // 最近 N 周每周保留最新的一个
+	KeepMonthly int    `yaml:"keep_monthly,omitempty"` // 最近 N 个月每月保留最新的一个
+	KeepYearly  int    `yaml:"keep_yearly,omitempty"`  // 最近 N 年每年保留最新的一个
+	KeepWithin  string `yaml:"keep_within,omitempty"`  // 保留距最新备份这段时间内的所有备份，如 "7d"、"1y6m"
+}
+
+// empty 是否没有任何保留规则
+func (p RetentionPolicy) empty() bool {
+	return p == RetentionPolicy{}
+}
+
+// validate 检查保留数量不能为负数，keep_within 格式正确
+func (p RetentionPolicy) validate() error {
+	for _, n := range []int{p.KeepLast, p.KeepHourly, p.KeepDaily, p.KeepWeekly, p.KeepMonthly, p.KeepYearly} {
+		if n < 0 {
+			return errors.New(tr("保留数量不能为负数"))
+		}
+	}
+	if p.KeepWithin != "" {
+		if _, err := parseRetentionPeriod(p.KeepWithin); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// retentionPeriod 以年、月、天、小时表示的时间段，按日历计算
+type retentionPeriod struct {
+	years, months, days, hours int
+}
+
+var retentionPeriodPattern = regexp.MustCompile(`(\d+)([ymwdh])`)
+
+// parseRetentionPeriod 解析由数字和单位 y（年）、m（月）、w（周）、d（天）、h（小时）组成的时间段，如 "1y6m"、"36h"
+func parseRetentionPeriod(s string) (retentionPeriod, error) {
+	var p retentionPeriod
+	matches := retentionPeriodPattern.FindAllStringSubmatchIndex(s, -1)
+	end := 0
+	for _, m := range matches {
+		if m[0] != end {
+			break
+		}
+		end = m[1]
+		n, err := strconv.Atoi(s[m[2]:m[3]])
+		if err != nil {
+			return p, fmt.Errorf(tr("无效的保留时间段 %q: %w"), s, err)
+		}
+		switch s[m[4]] {
+		case 'y':
+			p.years += n
+		case 'm':
+			p.months += n
+		case 'w':
+			p.days += 7 * n
+		case 'd':
+			p.days += n
+		case 'h':
+			p.hours += n
+		}
+	}
+	if len(matches) == 0 || end != len(s) {
+		return p, fmt.Errorf(tr("无效的保留时间段 %q: 应为数字加单位 y、m、w、d、h，如 \"7d\"、\"1y6m\""), s)
+	}
+	return p, nil
+}
+
+// before 返回 t 往前推该时间段后的时间
+func (p retentionPeriod) before(t time.Time) time.Time {
+	return t.AddDate(-p.years, -p.months, -p.days).Add(-time.Duration(p.hours) * time.Hour)
+}
+
+// backupFile 备份目录中的一个备份包
+type backupFile struct {
+	Path string
+	Time time.Time // 备份开始时间，见 backupTime
+}
+
+// retentionDecision 单个备份的保留结果
+type retentionDecision struct {
+	backupFile
+	Keep    bool
+	Reasons []string // 保留的原因，如 "last 1"、"daily 2026-01-02"
+}
+
+// retentionRule 按时间分桶的保留规则
+type retentionRule struct {
+	name  string
+	count int
+	key   func(time.Time) string
+}
+
+// rules 返回策略中的规则，keep_last 的每个备份单独成桶
+func (p RetentionPolicy) rules() []retentionRule {
+	return []retentionRule{
+		{name: "last", count: p.KeepLast},
+		{name: "hourly", count: p.KeepHourly, key: func(t time.Time) string { return t.Format("2006-01-02 15h") }},
+		{name: "daily", count: p.KeepDaily, key: func(t time.Time) string { return t.Format(time.DateOnly) }},
+		{name: "weekly", count: p.KeepWeekly, key: func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{name: "monthly", count: p.KeepMonthly, key: func(t time.Time) string { return t.Format("2006-01") }},
+		{name: "yearly", count: p.KeepYearly, key: func(t time.Time) string { return t.Format("2006") }},
+	}
+}
+
+// applyRetention 按策略决定保留哪些备份，每个时间桶保留最新的备份，结果按时间从新到旧排列
+func applyRetention(backups []backupFile, p RetentionPolicy) []retentionDecision {
+	decisions := make([]retentionDecision, len(backups))
+	for i, b := range backups {
+		decisions[i] = retentionDecision{backupFile: b}
+	}
+	slices.SortStableFunc(decisions, func(a, b retentionDecision) int {
+		return b.Time.Compare(a.Time)
+	})
+
+	if p.empty() {
+		for i := range decisions {
+			decisions[i].Keep = true
+			decisions[i].Reasons = []string{"no policy"}
+		}
+		return decisions
+	}
+
+	for _, rule := range p.rules() {
+		kept, last := 0, ""
+		for i := range decisions {
+			if kept >= rule.count {
+				break
+			}
+			bucket := fmt.Sprint(i)
+			if rule.key != nil {
+				bucket = rule.key(decisions[i].Time.Local())
+				if bucket == last {
+					continue
+				}
+			}
+			last = bucket
+			kept++
+			decisions[i].Keep = true
+			if rule.key == nil {
+				decisions[i].Reasons = append(decisions[i].Reasons, fmt.Sprintf("%s %d", rule.name, kept))
+			} else {
+				decisions[i].Reasons = append(decisions[i].Reasons, fmt.Sprintf("%s %s", rule.name, bucket))
+			}
+		}
+	}
+
+	// keep_within 以最新的备份为基准，备份停止一段时间后不会删光所有备份
+	if p.KeepWithin != "" && len(decisions) > 0 {
+		period, _ := parseRetentionPeriod(p.KeepWithin)
+		since := period.before(decisions[0].Time)
+		for i := range decisions {
+			if decisions[i].Time.Before(since) {
+				break
+			}
+			decisions[i].Keep = true
+			decisions[i].Reasons = append(decisions[i].Reasons, "within "+p.KeepWithin)
+		}
+	}
+	return decisions
+}
+
+// listBackupFiles 返回匹配 pattern 的备份包及其备份时间，无法识别的文件被忽略。
+// 分卷备份的各个分卷合并为一个备份，路径为分卷的基础名。match 不为空时只返回路径同时匹配 match 的备份包
+func listBackupFiles(pattern string, match *regexp.Regexp) ([]backupFile, error) {
+	paths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf(tr("无效的备份文件模式 (%s): %w"), pattern, err)
+	}
+
+	var backups []backupFile
+	seen := make(map[string]bool)
+	for _, path := range paths {
+		if m := volumeSuffix.FindStringSubmatch(path); m != nil {
+			path = strings.TrimSuffix(path, "."+m[1])
+		}
+		if seen[path] {
+			continue
+		}
+		seen[path] = true
+
+		if strings.HasSuffix(path, ".journal") || strings.Contains(filepath.Base(path), ".tmp") {
+			continue
+		}
+		if match != nil && !match.MatchString(path) {
+			continue
+		}
+		if _, err := os.Stat(journalPath(path)); err == nil {
+			continue // 正在进行或中断等待续传的备份
+		}
+		t, ok := backupTime(path)
+		if !ok {
+			continue
+		}
+		backups = append(backups, backupFile{Path: path, Time: t})
+	}
+	return backups, nil
+}
+
+// backupTime 读取备份包头部记录的开始时间，不是备份包时返回 false，path 可以是分卷的基础名。
+// 旧版本创建的备份包没有记录时，zip 格式读取清单，tar 格式的清单位于末尾，为避免解压整个备份包使用文件修改时间
+func backupTime(path string) (time.Time, bool) {
+	volumes, err := findVolumes(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	info, err := os.Stat(volumes[0])
+	if err != nil || !info.Mode().IsRegular() || !isArchiveFile(path) {
+		return time.Time{}, false
+	}
+	if t, ok := readStartTime(volumes[0]); ok {
+		return t, true
+	}
+
+	ar, err := openArchive(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	defer ar.Close()
+	if !ar.RandomAccess() {
+		return info.ModTime(), true
+	}
+
+	if m, err := readManifest(ar); err == nil && !m.StartTime.IsZero() {
+		return m.StartTime, true
+	}
+	return info.ModTime(), true
+}
+
+// pruneBackups 按策略删除 pattern 匹配的旧备份，返回保留结果，match 见 listBackupFiles
+func pruneBackups(pattern string, match *regexp.Regexp, p RetentionPolicy, dryRun bool) ([]retentionDecision, error) {
+	backups, err := listBackupFiles(pattern, match)
+	if err != nil {
+		return nil, err
+	}
+
+	decisions := applyRetention(backups, p)
+	if dryRun {
+		return decisions, nil
+	}
+	var errs []error
+	for _, d := range decisions {
+		if d.Keep {
+			continue
+		}
+		if err := removeArchive(d.Path); err != nil {
+			errs = append(errs, fmt.Errorf(tr("删除旧备份失败 (%s): %w"), d.Path, err))
+		}
+	}
+	return decisions, errors.Join(errs...)
+}
+
+// printRetention 输出每个备份的保留结果和原因
+func printRetention(w io.Writer, decisions []retentionDecision, dryRun bool) {
+	removeLabel := tr("删除")
+	if dryRun {
+		removeLabel = tr("将删除")
+	}
+	removed := 0
+	for _, d := range decisions {
+		if d.Keep {
+			fmt.Fprintf(w, "%-6s  %s  %s  (%s)\n", tr("保留"), d.Time.Local().Format(time.DateTime), d.Path, strings.Join(d.Reasons, ", "))
+		} else {
+			removed++
+			fmt.Fprintf(w, "%-6s  %s  %s\n", removeLabel, d.Time.Local().Format(time.DateTime), d.Path)
+		}
+	}
+	fmt.Fprintf(w, tr("共 %d 个备份，保留 %d 个，%s %d 个\n"), len(decisions), len(decisions)-removed, removeLabel, removed)
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func Test_parseRetentionPeriod(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    retentionPeriod
+		wantErr bool
+	}{
+		{name: "days", s: "7d", want: retentionPeriod{days: 7}},
+		{name: "weeks", s: "2w", want: retentionPeriod{days: 14}},
+		{name: "combined", s: "1y6m3d12h", want: retentionPeriod{years: 1, months: 6, days: 3, hours: 12}},
+		{name: "empty", s: "", wantErr: true},
+		{name: "no unit", s: "7", wantErr: true},
+		{name: "unknown unit", s: "7s", wantErr: true},
+		{name: "trailing garbage", s: "7dx", wantErr: true},
+		{name: "leading garbage", s: "x7d", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRetentionPeriod(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRetentionPeriod(%q) error = %v, wantErr %v", tt.s, err, tt.wantErr)
+			}
+			if !tt.wantErr && got != tt.want {
+				t.Errorf("parseRetentionPeriod(%q) = %+v, want %+v", tt.s, got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_applyRetention(t *testing.T) {
+	at := func(month time.Month, day, hour int) backupFile {
+		ts := time.Date(2026, month, day, hour, 0, 0, 0, time.Local)
+		return backupFile{Path: ts.Format("0102-15"), Time: ts}
+	}
+	// 乱序输入，结果按时间从新到旧
+	backups := []backupFile{
+		at(1, 5, 1), at(1, 20, 3), at(1, 20, 1), at(2, 3, 1), at(1, 19, 1), at(1, 31, 1),
+	}
+
+	tests := []struct {
+		name   string
+		policy RetentionPolicy
+		want   map[string][]string // 保留的备份及原因
+	}{
+		{
+			name:   "no policy",
+			policy: RetentionPolicy{},
+			want: map[string][]string{
+				"0203-01": {"no policy"}, "0131-01": {"no policy"}, "0120-03": {"no policy"},
+				"0120-01": {"no policy"}, "0119-01": {"no policy"}, "0105-01": {"no policy"},
+			},
+		},
+		{
+			name:   "last",
+			policy: RetentionPolicy{KeepLast: 2},
+			want:   map[string][]string{"0203-01": {"last 1"}, "0131-01": {"last 2"}},
+		},
+		{
+			name:   "daily keeps newest per day",
+			policy: RetentionPolicy{KeepDaily: 4},
+			want: map[string][]string{
+				"0203-01": {"daily 2026-02-03"}, "0131-01": {"daily 2026-01-31"},
+				"0120-03": {"daily 2026-01-20"}, "0119-01": {"daily 2026-01-19"},
+			},
+		},
+		{
+			name:   "monthly",
+			policy: RetentionPolicy{KeepMonthly: 12},
+			want:   map[string][]string{"0203-01": {"monthly 2026-02"}, "0131-01": {"monthly 2026-01"}},
+		},
+		{
+			name:   "hourly",
+			policy: RetentionPolicy{KeepHourly: 2},
+			want:   map[string][]string{"0203-01": {"hourly 2026-02-03 01h"}, "0131-01": {"hourly 2026-01-31 01h"}},
+		},
+		{
+			name:   "yearly",
+			policy: RetentionPolicy{KeepYearly: 5},
+			want:   map[string][]string{"0203-01": {"yearly 2026"}},
+		},
+		{
+			name:   "within newest backup",
+			policy: RetentionPolicy{KeepWithin: "2w"},
+			want: map[string][]string{
+				"0203-01": {"within 2w"}, "0131-01": {"within 2w"}, "0120-03": {"within 2w"},
+				"0120-01": {"within 2w"}, // 恰好在边界上
+			},
+		},
+		{
+			name:   "union of rules",
+			policy: RetentionPolicy{KeepLast: 1, KeepWeekly: 3},
+			want: map[string][]string{
+				"0203-01": {"last 1", "weekly 2026-W06"}, "0131-01": {"weekly 2026-W05"},
+				"0120-03": {"weekly 2026-W04"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			decisions := applyRetention(backups, tt.policy)
+			if len(decisions) != len(backups) {
+				t.Fatalf("len(decisions) = %d, want %d", len(decisions), len(backups))
+			}
+			got := make(map[string][]string)
+			for i, d := range decisions {
+				if i > 0 && d.Time.After(decisions[i-1].Time) {
+					t.Errorf("decisions not sorted newest first: %s after %s", d.Path, decisions[i-1].Path)
+				}
+				if d.Keep {
+					got[d.Path] = d.Reasons
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("applyRetention() kept = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_pruneBackups(t *testing.T) {
+	cfg, configBytes, err := loadConfig("testdata/config.yaml", "")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	dir := t.TempDir()
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.Local)
+	var paths []string
+	for i := range 4 {
+		// 按顺序备份，时间取自清单，与文件名和修改时间无关
+		path := filepath.Join(dir, base.AddDate(0, 0, 3-i).Format("20060102")+".tar.zst")
+		if err := backup(t.Context(), cfg, configBytes, path, backupOptions{Format: formatTarZst, Quiet: true}); err != nil {
+			t.Fatalf("backup() error = %v", err)
+		}
+		if err := os.Chtimes(path, base.AddDate(0, 0, 3-i), base.AddDate(0, 0, 3-i)); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	// 中断的备份和无关文件不参与清理
+	if err := os.WriteFile(journalPath(paths[0]), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pattern := filepath.Join(dir, "*")
+	decisions, err := pruneBackups(pattern, nil, RetentionPolicy{KeepLast: 1}, true)
+	if err != nil {
+		t.Fatalf("pruneBackups(dryRun) error = %v", err)
+	}
+	if len(decisions) != 3 {
+		t.Fatalf("len(decisions) = %d, want 3", len(decisions))
+	}
+	for _, p := range paths {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("dry run removed %s", p)
+		}
+	}
+
+	decisions, err = pruneBackups(pattern, nil, RetentionPolicy{KeepLast: 1}, false)
+	if err != nil {
+		t.Fatalf("pruneBackups() error = %v", err)
+	}
+	var out bytes.Buffer
+	printRetention(&out, decisions, false)
+	if !strings.Contains(out.String(), "(last 1)") || !strings.Contains(out.String(), "保留 1 个，删除 2 个") {
+		t.Errorf("printRetention() = %q", out.String())
+	}
+	for i, p := range paths {
+		_, err := os.Stat(p)
+		if exists := err == nil; exists != (i == 0 || i == 3) {
+			t.Errorf("%s exists = %v", filepath.Base(p), exists)
+		}
+	}
+}
+
+func Test_pruneBackups_volumes(t *testing.T) {
+	cfg, configBytes, err := loadConfig("testdata/config.yaml", "")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	dir := t.TempDir()
+	var bases []string
+	for _, name := range []string{"a.zip", "b.zip"} {
+		path := filepath.Join(dir, name)
+		if err := backup(t.Context(), cfg, configBytes, path, backupOptions{Format: formatZip, VolumeSize: 128, Quiet: true}); err != nil {
+			t.Fatalf("backup() error = %v", err)
+		}
+		bases = append(bases, path)
+	}
+	volumes, err := findVolumes(bases[0])
+	if err != nil || len(volumes) < 2 {
+		t.Fatalf("findVolumes() = %v, %v, want several volumes", volumes, err)
+	}
+
+	// 每组分卷是一个备份，删除时删除所有分卷
+	decisions, err := pruneBackups(filepath.Join(dir, "*"), nil, RetentionPolicy{KeepLast: 1}, false)
+	if err != nil {
+		t.Fatalf("pruneBackups() error = %v", err)
+	}
+	if len(decisions) != 2 {
+		t.Fatalf("decisions = %+v, want 2 backups", decisions)
+	}
+	for _, d := range decisions {
+		if d.Keep != (d.Path == bases[1]) {
+			t.Errorf("decision %s keep = %v", d.Path, d.Keep)
+		}
+	}
+	if matches, _ := filepath.Glob(bases[0] + ".*"); len(matches) != 0 {
+		t.Errorf("volumes left after prune = %v", matches)
+	}
+	if kept, err := findVolumes(bases[1]); err != nil || len(kept) < 2 {
+		t.Errorf("kept volumes = %v, %v", kept, err)
+	}
+}
+
+func Test_backupTime(t *testing.T) {
+	cfg, configBytes, err := loadConfig("testdata/config.yaml", "")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	for _, format := range []archiveFormat{formatZip, formatTarZst, formatTarGz} {
+		t.Run(string(format), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "renamed_19990101."+string(format))
+			start := time.Now().Add(-time.Second)
+			if err := backup(t.Context(), cfg, configBytes, path, backupOptions{Format: format, Quiet: true}); err != nil {
+				t.Fatalf("backup() error = %v", err)
+			}
+			// 时间来自清单，与文件名和修改时间无关
+			old := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+			if err := os.Chtimes(path, old, old); err != nil {
+				t.Fatal(err)
+			}
+
+			got, ok := backupTime(path)
+			if !ok || got.Before(start) {
+				t.Errorf("backupTime() = %v, %v, want manifest start time after %v", got, ok, start)
+			}
+			// 头部记录的时间与清单一致
+			ar, err := openArchive(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer ar.Close()
+			if m, err := readManifest(ar); err != nil || !m.StartTime.Equal(got) {
+				t.Errorf("manifest start time = %v, %v, want %v", m, err, got)
+			}
+		})
+	}
+
+	// 旧版本创建的 tar 备份包头部没有记录，不解压整个备份包，使用修改时间
+	legacy := filepath.Join(t.TempDir(), "legacy.tar.zst")
+	f, err := os.Create(legacy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	aw, err := newArchiveWriter(f, formatTarZst, 0, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeArchiveFile(aw, backupManifestName, []byte(`{"format_version": 1, "start_time": "2026-01-01T00:00:00Z"}`), &sync.Mutex{}); err != nil {
+		t.Fatal(err)
+	}
+	aw.Close()
+	f.Close()
+	old := time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(legacy, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := backupTime(legacy); !ok || !got.Equal(old) {
+		t.Errorf("backupTime(legacy tar) = %v, %v, want %v", got, ok, old)
+	}
+	if _, ok := backupTime(filepath.Join("testdata", "config.yaml")); ok {
+		t.Error("backupTime(config.yaml) ok = true")
+	}
+}
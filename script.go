@@ -1,14 +1,10 @@
 package main
 
 import (
-	"archive/zip"
+	"errors"
 	"fmt"
-	"io"
-	"log"
-	"os"
+	"log/slog"
 
-	"github.com/goccy/go-yaml"
-	"github.com/klauspost/compress/flate"
 	"github.com/spf13/cobra"
 )
 
@@ -21,13 +17,13 @@ var scriptCmd = &cobra.Command{
 		inputPath, _ := cmd.Flags().GetString("input")
 
 		if configPath == "" && inputPath == "" {
-			return fmt.Errorf("必须提供 config 或 input 参数")
+			return errors.New(tr("必须提供 config 或 input 参数"))
 		}
 		if configPath != "" && inputPath != "" {
-			return fmt.Errorf("不能同时提供 config 和 input 参数")
+			return errors.New(tr("不能同时提供 config 和 input 参数"))
 		}
 		if scriptType != "before" && scriptType != "after" {
-			return fmt.Errorf("type 必须是 'before' 或 'after'")
+			return errors.New(tr("type 必须是 'before' 或 'after'"))
 		}
 
 		return checkRoot(cmd, args)
@@ -43,20 +39,26 @@ var scriptCmd = &cobra.Command{
 		)
 
 		if configPath != "" {
+			result.Input = configPath
 			scriptContent, err = getScriptFromConfig(configPath, scriptType)
 		} else {
+			result.Input = inputPath
 			scriptContent, err = getScriptFromBackup(inputPath, scriptType)
 		}
 		if err != nil {
 			return err
 		}
 
+		result.Script = &resultScript{Type: scriptType}
 		if scriptContent == "" {
-			log.Printf("未找到 %s 脚本", scriptType)
+			slog.Info(tr("未找到脚本"), "script", scriptType)
 			return nil
 		}
 
-		return runScript(scriptContent, scriptType)
+		cmd.SilenceUsage = true
+		output, err := runScript(scriptContent, scriptType)
+		result.Script.Ran, result.Script.Output = true, output
+		return err
 	},
 }
 
@@ -71,14 +73,9 @@ func init() {
 
 // getScriptFromConfig 从YAML配置文件中读取脚本
 func getScriptFromConfig(configPath, scriptType string) (string, error) {
-	data, err := os.ReadFile(configPath)
+	cfg, _, err := loadConfig(configPath, "")
 	if err != nil {
-		return "", fmt.Errorf("读取配置文件失败 (%s): %w", configPath, err)
-	}
-
-	var cfg Config
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return "", fmt.Errorf("解析YAML配置失败 (%s): %w", configPath, err)
+		return "", fmt.Errorf(tr("加载配置失败: %w"), err)
 	}
 
 	if scriptType == "before" {
@@ -90,20 +87,16 @@ func getScriptFromConfig(configPath, scriptType string) (string, error) {
 // getScriptFromBackup 从备份包中读取脚本
 func getScriptFromBackup(zipPath, scriptType string) (string, error) {
 	// 打开备份文件
-	r, err := zip.OpenReader(zipPath)
+	ar, err := openArchive(zipPath)
 	if err != nil {
-		return "", fmt.Errorf("打开备份文件失败 (%s): %w", zipPath, err)
+		return "", err
 	}
-	defer r.Close()
+	defer ar.Close()
 
-	r.RegisterDecompressor(zip.Deflate, func(r io.Reader) io.ReadCloser {
-		return flate.NewReader(r)
-	})
-
-	// 读取配置
-	var cfg Config
-	if err := readYAMLFromZip(r.File, backupConfigName, &cfg); err != nil {
-		return "", fmt.Errorf("从备份包读取配置失败: %w", err)
+	// 读取配置，同时检查备份包结构版本
+	cfg, _, err := readBackupMetadata(ar)
+	if err != nil {
+		return "", fmt.Errorf(tr("从备份包读取配置失败: %w"), err)
 	}
 
 	if scriptType == "before" {
@@ -112,15 +105,14 @@ func getScriptFromBackup(zipPath, scriptType string) (string, error) {
 	return cfg.AfterScript, nil
 }
 
-// runScript 执行脚本
-func runScript(scriptContent, scriptType string) error {
-	result, err := runCommand("sh", "-c", scriptContent)
+// runScript 执行脚本，返回脚本的输出
+func runScript(scriptContent, scriptType string) (string, error) {
+	output, err := runCommand("sh", "-c", scriptContent)
 	if err != nil {
-		return fmt.Errorf("执行 %s 脚本失败: %w", scriptType, err)
+		return output, fmt.Errorf(tr("执行 %s 脚本失败: %w"), scriptType, err)
 	}
 
-	log.Printf("%s 脚本输出:\n%s", scriptType, result)
-	log.Printf("%s 脚本执行完成", scriptType)
+	slog.Info(tr("脚本执行完成"), "script", scriptType, "output", output)
 
-	return nil
+	return output, nil
 }
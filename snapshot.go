@@ -0,0 +1,310 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	snapshotBtrfs   = "btrfs"
+	snapshotLVM     = "lvm"
+	snapshotZFS     = "zfs"
+	snapshotCommand = "command"
+)
+
+const (
+	defaultSnapshotSize         = "1G"                       // lvm 快照默认的写时复制空间
+	defaultSnapshotMountOptions = "ro"                       // lvm 快照默认的挂载选项
+	snapshotMountDir            = "/run/backtrack/snapshots" // lvm 快照默认挂载在该目录下
+	btrfsSnapshotName           = ".backtrack-snapshot"      // btrfs 快照默认创建在子卷下的该目录中
+)
+
+// SnapshotConfig 备份前创建的文件系统快照，path 下的文件从快照中读取，文件映射中仍记录原路径
+type SnapshotConfig struct {
+	Type         string `yaml:"type"`                    // btrfs、lvm、zfs 或 command
+	Path         string `yaml:"path"`                    // 快照对应的原目录：btrfs 子卷、lvm 或 zfs 的挂载点
+	Volume       string `yaml:"volume,omitempty"`        // lvm 逻辑卷 vg/lv 或 zfs 数据集 pool/fs
+	Size         string `yaml:"size,omitempty"`          // lvm 快照的写时复制空间，默认 1G
+	Mount        string `yaml:"mount,omitempty"`         // btrfs、lvm 存放快照的目录，command 类型快照所在的目录，zfs 不需要指定
+	MountOptions string `yaml:"mount_options,omitempty"` // lvm 快照的挂载选项，默认 ro，xfs 需要加上 nouuid
+	Create       string `yaml:"create,omitempty"`        // command 类型创建快照的命令，$1 为 path，$2 为 mount
+	Remove       string `yaml:"remove,omitempty"`        // command 类型删除快照的命令，参数同 create
+}
+
+// snapshotDir 返回 btrfs、lvm 存放快照的目录，每次备份在其下创建以 snapshotTag 命名的快照，
+// 未指定 mount 时按类型使用默认目录
+func (c SnapshotConfig) snapshotDir() string {
+	if c.Mount != "" {
+		return c.Mount
+	}
+	switch c.Type {
+	case snapshotBtrfs:
+		return filepath.Join(c.Path, btrfsSnapshotName)
+	case snapshotLVM:
+		return snapshotMountDir
+	}
+	return ""
+}
+
+// parentDir 返回创建快照时需要写入的目录，为空表示不需要写入文件系统
+func (c SnapshotConfig) parentDir() string {
+	switch c.Type {
+	case snapshotBtrfs, snapshotLVM:
+		return c.snapshotDir()
+	case snapshotCommand:
+		return c.Mount
+	}
+	return ""
+}
+
+// validate 检查快照配置
+func (c SnapshotConfig) validate() error {
+	if _, ok := snapshotProviders[c.Type]; !ok {
+		return fmt.Errorf(tr("不支持的快照类型 %q，可选 btrfs、lvm、zfs、command"), c.Type)
+	}
+	if !filepath.IsAbs(c.Path) {
+		return fmt.Errorf(tr("快照的 path 必须是绝对路径: %q"), c.Path)
+	}
+	if c.Mount != "" && !filepath.IsAbs(c.Mount) {
+		return fmt.Errorf(tr("快照的 mount 必须是绝对路径: %q"), c.Mount)
+	}
+
+	switch c.Type {
+	case snapshotLVM:
+		if vg, lv, ok := strings.Cut(c.Volume, "/"); !ok || vg == "" || lv == "" || strings.Contains(lv, "/") {
+			return fmt.Errorf(tr("lvm 快照的 volume 应为 卷组/逻辑卷: %q"), c.Volume)
+		}
+		if _, err := parseSize(c.Size); err != nil {
+			return fmt.Errorf("size: %w", err)
+		}
+	case snapshotZFS:
+		if c.Volume == "" {
+			return errors.New(tr("zfs 快照需要指定 volume（数据集）"))
+		}
+		if c.Mount != "" {
+			return errors.New(tr("zfs 快照通过 .zfs/snapshot 读取，不能指定 mount"))
+		}
+	case snapshotCommand:
+		if strings.TrimSpace(c.Create) == "" {
+			return errors.New(tr("command 快照需要指定 create 命令"))
+		}
+	}
+	return nil
+}
+
+// snapshotProvider 创建和删除一个快照
+type snapshotProvider interface {
+	// create 创建快照，返回快照中与原目录对应的目录，为空表示仍读取原目录。
+	// 失败时自行清理已创建的部分
+	create() (string, error)
+	// remove 删除 create 成功创建的快照
+	remove() error
+}
+
+// snapshotProviders 各类型快照的实现，测试中可以注册假的实现
+var snapshotProviders = map[string]func(SnapshotConfig) snapshotProvider{
+	snapshotBtrfs:   func(c SnapshotConfig) snapshotProvider { return &btrfsSnapshot{cfg: c} },
+	snapshotLVM:     func(c SnapshotConfig) snapshotProvider { return &lvmSnapshot{cfg: c} },
+	snapshotZFS:     func(c SnapshotConfig) snapshotProvider { return &zfsSnapshot{cfg: c} },
+	snapshotCommand: func(c SnapshotConfig) snapshotProvider { return &commandSnapshot{cfg: c} },
+}
+
+// runSnapshotCommand 执行快照命令，测试中替换以检查调用的命令
+var runSnapshotCommand = runCommand
+
+// snapshotTag 返回本次快照的名称，包含创建时间和随机数，
+// 同一卷上同时运行的备份或被强制终止后遗留的快照不会与之冲突。测试中替换为固定名称
+var snapshotTag = func() string {
+	b := make([]byte, 4)
+	rand.Read(b)
+	return "backtrack-" + time.Now().Format("20060102150405") + "-" + hex.EncodeToString(b)
+}
+
+// btrfsSnapshot 用 btrfs subvolume snapshot 创建只读快照，快照必须与子卷位于同一文件系统
+type btrfsSnapshot struct {
+	cfg  SnapshotConfig
+	dest string
+}
+
+func (s *btrfsSnapshot) create() (string, error) {
+	dir := s.cfg.snapshotDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+	s.dest = filepath.Join(dir, snapshotTag())
+	if _, err := runSnapshotCommand("btrfs", "subvolume", "snapshot", "-r", s.cfg.Path, s.dest); err != nil {
+		return "", err
+	}
+	return s.dest, nil
+}
+
+func (s *btrfsSnapshot) remove() error {
+	_, err := runSnapshotCommand("btrfs", "subvolume", "delete", s.dest)
+	return err
+}
+
+// lvmSnapshot 用 lvcreate --snapshot 创建快照卷并只读挂载
+type lvmSnapshot struct {
+	cfg   SnapshotConfig
+	vg    string
+	name  string // 快照卷名称
+	mount string
+}
+
+func (s *lvmSnapshot) create() (string, error) {
+	vg, lv, _ := strings.Cut(s.cfg.Volume, "/")
+	s.vg, s.name = vg, lv+"_"+snapshotTag()
+	s.mount = filepath.Join(s.cfg.snapshotDir(), vg+"-"+s.name)
+	size := s.cfg.Size
+	if size == "" {
+		size = defaultSnapshotSize
+	}
+	options := s.cfg.MountOptions
+	if options == "" {
+		options = defaultSnapshotMountOptions
+	}
+
+	if _, err := runSnapshotCommand("lvcreate", "--snapshot", "--name", s.name, "--size", size, s.cfg.Volume); err != nil {
+		return "", err
+	}
+	err := os.MkdirAll(s.mount, 0700)
+	if err == nil {
+		_, err = runSnapshotCommand("mount", "-o", options, "/dev/"+s.vg+"/"+s.name, s.mount)
+	}
+	if err != nil {
+		_, rerr := runSnapshotCommand("lvremove", "-f", s.vg+"/"+s.name)
+		return "", errors.Join(err, rerr)
+	}
+	return s.mount, nil
+}
+
+func (s *lvmSnapshot) remove() error {
+	if _, err := runSnapshotCommand("umount", s.mount); err != nil {
+		return err // 卸载失败时保留快照卷，避免删除仍在使用的设备
+	}
+	os.Remove(s.mount)
+	_, err := runSnapshotCommand("lvremove", "-f", s.vg+"/"+s.name)
+	return err
+}
+
+// zfsSnapshot 用 zfs snapshot 创建快照，从挂载点下的 .zfs/snapshot 读取
+type zfsSnapshot struct {
+	cfg  SnapshotConfig
+	name string // dataset@snapshot
+}
+
+func (s *zfsSnapshot) create() (string, error) {
+	tag := snapshotTag()
+	s.name = s.cfg.Volume + "@" + tag
+	if _, err := runSnapshotCommand("zfs", "snapshot", s.name); err != nil {
+		return "", err
+	}
+	return filepath.Join(s.cfg.Path, ".zfs", "snapshot", tag), nil
+}
+
+func (s *zfsSnapshot) remove() error {
+	_, err := runSnapshotCommand("zfs", "destroy", s.name)
+	return err
+}
+
+// commandSnapshot 执行用户提供的命令，未指定 mount 时仍读取原目录，可用于冻结应用的写入
+type commandSnapshot struct {
+	cfg SnapshotConfig
+}
+
+func (s *commandSnapshot) create() (string, error) {
+	if _, err := runSnapshotCommand("sh", "-c", s.cfg.Create, "backtrack", s.cfg.Path, s.cfg.Mount); err != nil {
+		return "", err
+	}
+	return s.cfg.Mount, nil
+}
+
+func (s *commandSnapshot) remove() error {
+	if s.cfg.Remove == "" {
+		return nil
+	}
+	_, err := runSnapshotCommand("sh", "-c", s.cfg.Remove, "backtrack", s.cfg.Path, s.cfg.Mount)
+	return err
+}
+
+// snapshotMount 原目录与快照中对应目录的映射
+type snapshotMount struct {
+	Path string // 原目录
+	Root string // 快照中的目录
+}
+
+// snapshotMounts 备份时使用的快照，按原目录从长到短排列，为空时直接读取原路径
+type snapshotMounts []snapshotMount
+
+// source 返回读取原路径时实际使用的路径
+func (m snapshotMounts) source(path string) string {
+	for _, s := range m {
+		rel, err := filepath.Rel(s.Path, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		return filepath.Join(s.Root, rel)
+	}
+	return path
+}
+
+// isRoot 判断路径是否为快照所在的目录，遍历原文件时跳过，避免重复备份
+func (m snapshotMounts) isRoot(path string) bool {
+	return slices.ContainsFunc(m, func(s snapshotMount) bool { return s.Root == path })
+}
+
+// nested 返回位于 root 之内的快照原目录，遍历 root 时这些目录仍读取原文件
+func (m snapshotMounts) nested(root string) []string {
+	var paths []string
+	for _, s := range m {
+		rel, err := filepath.Rel(root, s.Path)
+		if err == nil && rel != "." && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			paths = append(paths, s.Path)
+		}
+	}
+	return paths
+}
+
+// createSnapshots 依次创建快照，返回路径映射和删除所有快照的函数。
+// 任一快照创建失败时删除已创建的快照并返回错误
+func createSnapshots(cfgs []SnapshotConfig) (snapshotMounts, func(), error) {
+	type created struct {
+		cfg SnapshotConfig
+		p   snapshotProvider
+	}
+	var done []created
+	release := func() {
+		for i := len(done) - 1; i >= 0; i-- {
+			if err := done[i].p.remove(); err != nil {
+				slog.Error(tr("删除快照失败"), "type", done[i].cfg.Type, "path", done[i].cfg.Path, "error", err)
+				continue
+			}
+			slog.Info(tr("已删除快照"), "type", done[i].cfg.Type, "path", done[i].cfg.Path)
+		}
+	}
+
+	var mounts snapshotMounts
+	for _, c := range cfgs {
+		p := snapshotProviders[c.Type](c)
+		root, err := p.create()
+		if err != nil {
+			release()
+			return nil, nil, fmt.Errorf(tr("创建快照失败 (%s): %w"), c.Path, err)
+		}
+		done = append(done, created{cfg: c, p: p})
+		slog.Info(tr("已创建快照"), "type", c.Type, "path", c.Path, "root", root)
+		if root != "" {
+			mounts = append(mounts, snapshotMount{Path: filepath.Clean(c.Path), Root: filepath.Clean(root)})
+		}
+	}
+	slices.SortStableFunc(mounts, func(a, b snapshotMount) int { return len(b.Path) - len(a.Path) })
+	return mounts, release, nil
+}
@@ -0,0 +1,323 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func Test_snapshotMounts_source(t *testing.T) {
+	mounts := snapshotMounts{
+		{Path: "/data/db", Root: "/snap/db"},
+		{Path: "/data", Root: "/snap/data"},
+		{Path: "/", Root: "/snap/root"},
+	}
+	tests := []struct {
+		path string
+		want string
+	}{
+		{path: "/data", want: "/snap/data"},
+		{path: "/data/a.txt", want: "/snap/data/a.txt"},
+		{path: "/data/db/x", want: "/snap/db/x"},
+		{path: "/database", want: "/snap/root/database"},
+		{path: "/etc/hosts", want: "/snap/root/etc/hosts"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			if got := mounts.source(tt.path); got != tt.want {
+				t.Errorf("source() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	if got := (snapshotMounts)(nil).source("/data/a"); got != "/data/a" {
+		t.Errorf("source() without snapshots = %q", got)
+	}
+	if got := mounts[:2].nested("/"); !slices.Equal(got, []string{"/data/db", "/data"}) {
+		t.Errorf("nested() = %v", got)
+	}
+	if got := mounts[:2].nested("/data"); !slices.Equal(got, []string{"/data/db"}) {
+		t.Errorf("nested() = %v", got)
+	}
+}
+
+func Test_SnapshotConfig_validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     SnapshotConfig
+		wantErr bool
+	}{
+		{name: "btrfs", cfg: SnapshotConfig{Type: snapshotBtrfs, Path: "/home"}},
+		{name: "lvm", cfg: SnapshotConfig{Type: snapshotLVM, Path: "/srv", Volume: "vg0/srv", Size: "2G"}},
+		{name: "zfs", cfg: SnapshotConfig{Type: snapshotZFS, Path: "/tank/data", Volume: "tank/data"}},
+		{name: "command without mount", cfg: SnapshotConfig{Type: snapshotCommand, Path: "/var/lib/db", Create: "fsfreeze -f $1"}},
+		{name: "unknown type", cfg: SnapshotConfig{Type: "xfs", Path: "/"}, wantErr: true},
+		{name: "relative path", cfg: SnapshotConfig{Type: snapshotBtrfs, Path: "home"}, wantErr: true},
+		{name: "relative mount", cfg: SnapshotConfig{Type: snapshotBtrfs, Path: "/home", Mount: "snap"}, wantErr: true},
+		{name: "lvm without group", cfg: SnapshotConfig{Type: snapshotLVM, Path: "/srv", Volume: "srv"}, wantErr: true},
+		{name: "lvm invalid size", cfg: SnapshotConfig{Type: snapshotLVM, Path: "/srv", Volume: "vg0/srv", Size: "big"}, wantErr: true},
+		{name: "zfs without dataset", cfg: SnapshotConfig{Type: snapshotZFS, Path: "/tank"}, wantErr: true},
+		{name: "zfs with mount", cfg: SnapshotConfig{Type: snapshotZFS, Path: "/tank", Volume: "tank", Mount: "/mnt"}, wantErr: true},
+		{name: "command without create", cfg: SnapshotConfig{Type: snapshotCommand, Path: "/srv"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// recordSnapshotCommands 替换快照命令的执行，记录每条命令，fail 中的命令返回错误。
+// 快照名称固定为 backtrack-test
+func recordSnapshotCommands(t *testing.T, fail string) *[]string {
+	t.Helper()
+	var calls []string
+	prevTag := snapshotTag
+	snapshotTag = func() string { return "backtrack-test" }
+	t.Cleanup(func() { snapshotTag = prevTag })
+	prev := runSnapshotCommand
+	runSnapshotCommand = func(name string, args ...string) (string, error) {
+		calls = append(calls, strings.Join(append([]string{name}, args...), " "))
+		if name == fail {
+			return "", errors.New("exit status 1")
+		}
+		return "", nil
+	}
+	t.Cleanup(func() { runSnapshotCommand = prev })
+	return &calls
+}
+
+func Test_snapshotProviders(t *testing.T) {
+	lvmMount := filepath.Join(t.TempDir(), "lvm")
+	subvol := filepath.Join(t.TempDir(), "home")
+	tests := []struct {
+		name     string
+		cfg      SnapshotConfig
+		fail     string
+		wantRoot string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "btrfs",
+			cfg:      SnapshotConfig{Type: snapshotBtrfs, Path: subvol},
+			wantRoot: filepath.Join(subvol, ".backtrack-snapshot", "backtrack-test"),
+			want: []string{
+				"btrfs subvolume snapshot -r " + subvol + " " + filepath.Join(subvol, ".backtrack-snapshot", "backtrack-test"),
+				"btrfs subvolume delete " + filepath.Join(subvol, ".backtrack-snapshot", "backtrack-test"),
+			},
+		},
+		{
+			name:     "lvm",
+			cfg:      SnapshotConfig{Type: snapshotLVM, Path: "/srv", Volume: "vg0/srv", Mount: lvmMount, MountOptions: "ro,nouuid"},
+			wantRoot: filepath.Join(lvmMount, "vg0-srv_backtrack-test"),
+			want: []string{
+				"lvcreate --snapshot --name srv_backtrack-test --size 1G vg0/srv",
+				"mount -o ro,nouuid /dev/vg0/srv_backtrack-test " + filepath.Join(lvmMount, "vg0-srv_backtrack-test"),
+				"umount " + filepath.Join(lvmMount, "vg0-srv_backtrack-test"),
+				"lvremove -f vg0/srv_backtrack-test",
+			},
+		},
+		{
+			name: "lvm mount failure removes volume",
+			cfg:  SnapshotConfig{Type: snapshotLVM, Path: "/srv", Volume: "vg0/srv", Mount: lvmMount},
+			fail: "mount",
+			want: []string{
+				"lvcreate --snapshot --name srv_backtrack-test --size 1G vg0/srv",
+				"mount -o ro /dev/vg0/srv_backtrack-test " + filepath.Join(lvmMount, "vg0-srv_backtrack-test"),
+				"lvremove -f vg0/srv_backtrack-test",
+			},
+			wantErr: true,
+		},
+		{
+			name: "command",
+			cfg:  SnapshotConfig{Type: snapshotCommand, Path: "/db", Mount: "/mnt/db", Create: "make-snap", Remove: "drop-snap"},
+			want: []string{
+				"sh -c make-snap backtrack /db /mnt/db",
+				"sh -c drop-snap backtrack /db /mnt/db",
+			},
+			wantRoot: "/mnt/db",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls := recordSnapshotCommands(t, tt.fail)
+			p := snapshotProviders[tt.cfg.Type](tt.cfg)
+			root, err := p.create()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("create() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil {
+				if root != tt.wantRoot {
+					t.Errorf("create() = %q, want %q", root, tt.wantRoot)
+				}
+				if err := p.remove(); err != nil {
+					t.Fatalf("remove() error = %v", err)
+				}
+			}
+			if !slices.Equal(*calls, tt.want) {
+				t.Errorf("commands = %q, want %q", *calls, tt.want)
+			}
+		})
+	}
+
+	t.Run("zfs", func(t *testing.T) {
+		calls := recordSnapshotCommands(t, "")
+		p := snapshotProviders[snapshotZFS](SnapshotConfig{Type: snapshotZFS, Path: "/tank/data", Volume: "tank/data"})
+		root, err := p.create()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := p.remove(); err != nil {
+			t.Fatal(err)
+		}
+		want := []string{"zfs snapshot tank/data@backtrack-test", "zfs destroy tank/data@backtrack-test"}
+		if root != "/tank/data/.zfs/snapshot/backtrack-test" || !slices.Equal(*calls, want) {
+			t.Errorf("root = %q, commands = %q", root, *calls)
+		}
+	})
+
+	// 每次备份使用不同的快照名称，遗留的快照或同一卷上的其他备份不会导致创建失败
+	if a, b := snapshotTag(), snapshotTag(); a == b || !strings.HasPrefix(a, "backtrack-") {
+		t.Errorf("snapshotTag() = %q, %q", a, b)
+	}
+}
+
+// fakeSnapshot 假的快照，mount 指向测试预先准备好的目录，create 为 fail 时创建失败
+type fakeSnapshot struct {
+	cfg     SnapshotConfig
+	removed *[]string
+}
+
+func (s *fakeSnapshot) create() (string, error) {
+	if s.cfg.Create == "fail" {
+		return "", errors.New("snapshot failed")
+	}
+	return s.cfg.Mount, nil
+}
+
+func (s *fakeSnapshot) remove() error {
+	*s.removed = append(*s.removed, s.cfg.Path)
+	return nil
+}
+
+// useFakeSnapshots 注册 fake 类型的快照，返回已删除快照的原目录
+func useFakeSnapshots(t *testing.T) *[]string {
+	t.Helper()
+	var removed []string
+	snapshotProviders["fake"] = func(c SnapshotConfig) snapshotProvider {
+		return &fakeSnapshot{cfg: c, removed: &removed}
+	}
+	t.Cleanup(func() { delete(snapshotProviders, "fake") })
+	return &removed
+}
+
+func Test_backupFromSnapshot(t *testing.T) {
+	removed := useFakeSnapshots(t)
+
+	dir := t.TempDir()
+	live, snap := filepath.Join(dir, "live"), filepath.Join(dir, "snap")
+	// 原目录中的文件在备份过程中被修改，快照中保留一致的版本
+	writeTestFiles(t, live, map[string]string{"a.txt": "live", "only-live.txt": "new"})
+	writeTestFiles(t, snap, map[string]string{"a.txt": "snapshot", "skip.txt": "x", ignoreFileName: "skip.txt\n"})
+
+	cfg := &Config{
+		BackupPaths: []BackupPath{{Path: live}},
+		Snapshot:    []SnapshotConfig{{Type: "fake", Path: live, Mount: snap}},
+	}
+	if err := cfg.validate(); err != nil {
+		t.Fatalf("validate() error = %v", err)
+	}
+	outputPath := filepath.Join(dir, "out.zip")
+	if err := backup(t.Context(), cfg, nil, outputPath, backupOptions{Quiet: true}); err != nil {
+		t.Fatalf("backup() error = %v", err)
+	}
+	if !slices.Equal(*removed, []string{live}) {
+		t.Errorf("removed snapshots = %v", *removed)
+	}
+
+	ar, err := openArchive(outputPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ar.Close()
+	_, fileMap, err := readBackupMetadata(ar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var paths []string
+	for _, p := range fileMap {
+		paths = append(paths, p)
+	}
+	slices.Sort(paths)
+	if want := []string{filepath.Join(live, ignoreFileName), filepath.Join(live, "a.txt")}; !slices.Equal(paths, want) {
+		t.Errorf("fileMap paths = %v, want %v", paths, want)
+	}
+
+	rootDir := t.TempDir()
+	if err := restore(t.Context(), outputPath, restoreOptions{RootDir: rootDir, Quiet: true}); err != nil {
+		t.Fatalf("restore() error = %v", err)
+	}
+	if !filesMatchContent(map[string]string{filepath.Join(live, "a.txt"): "snapshot"}, rootDir) {
+		t.Errorf("restored content is not from the snapshot")
+	}
+}
+
+func Test_backupSnapshotFailure(t *testing.T) {
+	removed := useFakeSnapshots(t)
+
+	dir := t.TempDir()
+	writeTestFiles(t, filepath.Join(dir, "a"), map[string]string{"a.txt": "a"})
+	cfg := &Config{
+		BackupPaths: []BackupPath{{Path: filepath.Join(dir, "a")}},
+		Snapshot: []SnapshotConfig{
+			{Type: "fake", Path: filepath.Join(dir, "a"), Mount: filepath.Join(dir, "a")},
+			{Type: "fake", Path: filepath.Join(dir, "b"), Create: "fail"},
+		},
+	}
+	outputPath := filepath.Join(dir, "out.zip")
+	if err := backup(t.Context(), cfg, nil, outputPath, backupOptions{Quiet: true}); err == nil {
+		t.Fatal("backup() error = nil")
+	}
+	if !slices.Equal(*removed, []string{filepath.Join(dir, "a")}) {
+		t.Errorf("removed snapshots = %v", *removed)
+	}
+	if _, err := os.Stat(outputPath); !os.IsNotExist(err) {
+		t.Errorf("output created after snapshot failure: %v", err)
+	}
+}
+
+func Test_backupBeforeRestore_noSnapshot(t *testing.T) {
+	removed := useFakeSnapshots(t)
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+
+	dir := t.TempDir()
+	live := filepath.Join(dir, "live")
+	writeTestFiles(t, live, map[string]string{"a.txt": "live"})
+	// 备份包中的快照配置来自原来的主机，还原前备份不应创建快照
+	cfg := &Config{
+		BackupPaths: []BackupPath{{Path: live}},
+		Snapshot:    []SnapshotConfig{{Type: "fake", Path: live, Mount: filepath.Join(dir, "missing")}},
+	}
+	if err := backupBeforeRestoreAction(t.Context(), cfg, true, ioThrottle{}); err != nil {
+		t.Fatalf("backupBeforeRestoreAction() error = %v", err)
+	}
+	if len(*removed) != 0 {
+		t.Errorf("snapshots created = %v", *removed)
+	}
+	if len(cfg.Snapshot) != 1 {
+		t.Errorf("cfg.Snapshot modified: %v", cfg.Snapshot)
+	}
+	backups, _ := filepath.Glob(filepath.Join(home, ".backup_restore", "restore_*.zip"))
+	if len(backups) != 1 {
+		t.Errorf("pre-restore backups = %v", backups)
+	}
+}
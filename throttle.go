@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// rateLimitChunk 限速读写的最大分块，分块越小等待越平滑
+const rateLimitChunk = 32 << 10
+
+// rateLimiter 按字节数限速，多个 worker 共享同一个限速器时限制的是总速率。
+// 为 nil 时不限速
+type rateLimiter struct {
+	mu   sync.Mutex
+	rate float64   // 每秒字节数
+	next time.Time // 之前的读写按速率应完成的时间
+}
+
+// newRateLimiter 创建限速器，bytesPerSec 不大于 0 时返回 nil 表示不限速
+func newRateLimiter(bytesPerSec int64) *rateLimiter {
+	if bytesPerSec <= 0 {
+		return nil
+	}
+	return &rateLimiter{rate: float64(bytesPerSec)}
+}
+
+// wait 为 n 个字节排队，等待到按速率可以继续的时间。空闲的时间不会累积
+func (l *rateLimiter) wait(n int) {
+	if l == nil || n <= 0 {
+		return
+	}
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(float64(n) / l.rate * float64(time.Second)))
+	delay := l.next.Sub(now)
+	l.mu.Unlock()
+
+	time.Sleep(delay)
+}
+
+// reader 返回限速的 reader，不限速时返回 r 本身
+func (l *rateLimiter) reader(r io.Reader) io.Reader {
+	if l == nil {
+		return r
+	}
+	return &rateLimitedReader{r: r, l: l}
+}
+
+// writer 返回限速的 writer，不限速时返回 w 本身
+func (l *rateLimiter) writer(w io.Writer) io.Writer {
+	if l == nil {
+		return w
+	}
+	return &rateLimitedWriter{w: w, l: l}
+}
+
+type rateLimitedReader struct {
+	r io.Reader
+	l *rateLimiter
+}
+
+func (r *rateLimitedReader) Read(p []byte) (int, error) {
+	if len(p) > rateLimitChunk {
+		p = p[:rateLimitChunk]
+	}
+	n, err := r.r.Read(p)
+	r.l.wait(n)
+	return n, err
+}
+
+type rateLimitedWriter struct {
+	w io.Writer
+	l *rateLimiter
+}
+
+func (w *rateLimitedWriter) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		chunk := p[:min(len(p), rateLimitChunk)]
+		w.l.wait(len(chunk))
+		n, err := w.w.Write(chunk)
+		written += n
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// parseRate 解析每秒字节数，如 10M、512K/s，为空表示不限速
+func parseRate(s string) (int64, error) {
+	trimmed := strings.TrimSpace(s)
+	if len(trimmed) >= 2 && strings.EqualFold(trimmed[len(trimmed)-2:], "/s") {
+		trimmed = trimmed[:len(trimmed)-2]
+	}
+	n, err := parseSize(trimmed)
+	if err != nil {
+		return 0, fmt.Errorf(tr("无法解析速率: %q"), s)
+	}
+	return n, nil
+}
+
+// ioThrottle 备份和还原的并发数和读写限速
+type ioThrottle struct {
+	Workers int          // worker 数量，0 表示 CPU 核数
+	Read    *rateLimiter // 备份时限制读取源文件，还原时限制读取备份条目
+	Write   *rateLimiter // 备份时限制写入备份包，还原时限制写入还原的文件
+}
+
+// workers 返回 worker 数量
+func (t ioThrottle) workers() int {
+	if t.Workers > 0 {
+		return t.Workers
+	}
+	return runtime.NumCPU()
+}
+
+// addThrottleFlags 添加并发、限速和进程优先级参数，backup 和 restore 共用
+func addThrottleFlags(cmd *cobra.Command) {
+	cmd.Flags().Int("workers", 0, "并发处理文件的 worker 数量，0 表示 CPU 核数")
+	cmd.Flags().String("read-rate-limit", "", "读取限速（字节/秒），如 10M，为空表示不限速；备份时限制读取源文件，还原时限制读取备份条目")
+	cmd.Flags().String("write-rate-limit", "", "写入限速（字节/秒），如 10M，为空表示不限速；备份时限制写入备份包，还原时限制写入还原的文件")
+	cmd.Flags().Int("nice", 0, "进程的 CPU nice 值 (-20~19)，未指定时不修改")
+	cmd.Flags().String("ionice", "", "进程的 I/O 调度类别 (idle|best-effort[:0-7]|realtime[:0-7])，未指定时不修改")
+}
+
+// throttleFromFlags 根据命令行参数创建并发和限速设置
+func throttleFromFlags(cmd *cobra.Command) (ioThrottle, error) {
+	var t ioThrottle
+	t.Workers, _ = cmd.Flags().GetInt("workers")
+	if t.Workers < 0 {
+		return t, fmt.Errorf(tr("worker 数量不能为负数: %d"), t.Workers)
+	}
+
+	readRate, _ := cmd.Flags().GetString("read-rate-limit")
+	n, err := parseRate(readRate)
+	if err != nil {
+		return t, err
+	}
+	t.Read = newRateLimiter(n)
+
+	writeRate, _ := cmd.Flags().GetString("write-rate-limit")
+	if n, err = parseRate(writeRate); err != nil {
+		return t, err
+	}
+	t.Write = newRateLimiter(n)
+	return t, nil
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func Test_parseRate(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    int64
+		wantErr bool
+	}{
+		{name: "empty", s: "", want: 0},
+		{name: "bytes", s: "4096", want: 4096},
+		{name: "megabytes", s: "10M", want: 10 << 20},
+		{name: "per second", s: "512K/s", want: 512 << 10},
+		{name: "per second upper", s: "1MB/S", want: 1 << 20},
+		{name: "invalid", s: "fast", wantErr: true},
+		{name: "negative", s: "-1M", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseRate(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseRate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseRate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_rateLimiter(t *testing.T) {
+	data := bytes.Repeat([]byte("x"), 96<<10)
+	tests := []struct {
+		name string
+		copy func(l *rateLimiter, dst *bytes.Buffer) error
+	}{
+		{name: "reader", copy: func(l *rateLimiter, dst *bytes.Buffer) error {
+			_, err := io.Copy(dst, l.reader(bytes.NewReader(data)))
+			return err
+		}},
+		{name: "writer", copy: func(l *rateLimiter, dst *bytes.Buffer) error {
+			_, err := l.writer(dst).Write(data)
+			return err
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// 96K 按 384K/s 约需 250ms
+			l := newRateLimiter(384 << 10)
+			var dst bytes.Buffer
+			start := time.Now()
+			if err := tt.copy(l, &dst); err != nil {
+				t.Fatal(err)
+			}
+			if elapsed := time.Since(start); elapsed < 200*time.Millisecond {
+				t.Errorf("elapsed = %v, want >= 200ms", elapsed)
+			}
+			if !bytes.Equal(dst.Bytes(), data) {
+				t.Errorf("data mismatch")
+			}
+		})
+	}
+
+	// 不限速时返回原始的 reader 和 writer
+	var l *rateLimiter
+	r := strings.NewReader("a")
+	if l.reader(r) != io.Reader(r) || newRateLimiter(0) != nil {
+		t.Errorf("nil limiter should not wrap")
+	}
+}
+
+func Test_backupRestoreThrottled(t *testing.T) {
+	cfg, configBytes, err := loadConfig("testdata/config.yaml", "")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	throttle := ioThrottle{Workers: 1, Read: newRateLimiter(1 << 20), Write: newRateLimiter(1 << 20)}
+
+	outputPath := filepath.Join(t.TempDir(), "output.zip")
+	if err := backup(t.Context(), cfg, configBytes, outputPath, backupOptions{Format: formatZip, Quiet: true, Throttle: throttle}); err != nil {
+		t.Fatalf("backup() error = %v", err)
+	}
+
+	tempDir := t.TempDir()
+	if err := restore(t.Context(), outputPath, restoreOptions{RootDir: tempDir, Quiet: true, Throttle: throttle}); err != nil {
+		t.Fatalf("restore() error = %v", err)
+	}
+	absPath, _ := filepath.Abs("testdata/backup/data1/data5.txt")
+	if !filesMatchContent(map[string]string{absPath: "test5"}, tempDir) {
+		t.Errorf("restore() %s content mismatch", absPath)
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+var installTimerCmd = &cobra.Command{
+	Use:   "install-timer",
+	Short: "生成定时备份的 systemd service 和 timer 文件",
+	Long: `生成定时备份的 systemd service 和 timer 文件并写入 unit 目录。
+备份以低优先级运行，文件系统只读，只有输出目录、配置中快照所在的目录和 --read-write-path 指定的路径可写。
+只生成备份的 service：还原需要写入任意路径并执行备份包中的脚本，不适合在只读的 service 中定时运行，应手动执行 restore。`,
+	PreRunE: checkRoot,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		opts, err := timerOptionsFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		unitDir, _ := cmd.Flags().GetString("unit-dir")
+		enable, _ := cmd.Flags().GetBool("enable")
+
+		cmd.SilenceUsage = true
+		paths, err := installTimer(unitDir, opts)
+		if err != nil {
+			return err
+		}
+		result.Input, result.Files = opts.Config, paths
+		for _, p := range paths {
+			fmt.Fprintf(textOutput(cmd), tr("已写入 %s\n"), p)
+		}
+
+		if !enable {
+			fmt.Fprintf(textOutput(cmd), tr("启用定时器: systemctl daemon-reload && systemctl enable --now %s.timer\n"), opts.Name)
+			return nil
+		}
+		if _, err := runCommand("systemctl", "daemon-reload"); err != nil {
+			return err
+		}
+		if _, err := runCommand("systemctl", "enable", "--now", opts.Name+".timer"); err != nil {
+			return err
+		}
+		fmt.Fprintf(textOutput(cmd), tr("已启用 %s.timer\n"), opts.Name)
+		return nil
+	},
+}
+
+func init() {
+	installTimerCmd.Flags().StringP("config", "c", "config.yaml", "备份配置文件路径")
+	installTimerCmd.Flags().String("profile", "", "使用配置文件中的命名配置")
+	installTimerCmd.Flags().String("name", "", "unit 名称，默认为 backtrack 或 backtrack-<profile>")
+	installTimerCmd.Flags().String("on-calendar", "daily", "systemd OnCalendar 表达式，如 daily、*-*-* 03:30:00")
+	installTimerCmd.Flags().String("randomized-delay", "", "随机延迟启动的最长时间，如 30min")
+	installTimerCmd.Flags().String("output-dir", "/var/backups/backtrack", "备份输出目录")
+	installTimerCmd.Flags().StringP("format", "f", string(formatZip), "备份格式 (zip|tar.zst|tar.gz)")
+	installTimerCmd.Flags().StringArray("read-write-path", nil, "额外允许写入的路径，如前后置脚本写入的目录，可重复指定")
+	installTimerCmd.Flags().String("unit-dir", "/etc/systemd/system", "unit 文件写入目录")
+	installTimerCmd.Flags().Bool("enable", false, "写入后执行 systemctl daemon-reload 并启用定时器")
+
+	rootCmd.AddCommand(installTimerCmd)
+}
+
+// timerOptions 生成 unit 文件的参数
+type timerOptions struct {
+	Name            string
+	Executable      string // backtrack 可执行文件的绝对路径
+	Config          string
+	Profile         string
+	OnCalendar      string
+	RandomizedDelay string
+	OutputDir       string
+	Format          archiveFormat
+	ReadWritePaths  []string
+	SnapshotDirs    []string // 配置中创建快照时需要写入的目录
+}
+
+// timerOptionsFromFlags 读取命令行参数，路径转换为绝对路径
+func timerOptionsFromFlags(cmd *cobra.Command) (timerOptions, error) {
+	var opts timerOptions
+	opts.Config, _ = cmd.Flags().GetString("config")
+	opts.Profile, _ = cmd.Flags().GetString("profile")
+	opts.Name, _ = cmd.Flags().GetString("name")
+	opts.OnCalendar, _ = cmd.Flags().GetString("on-calendar")
+	opts.RandomizedDelay, _ = cmd.Flags().GetString("randomized-delay")
+	opts.OutputDir, _ = cmd.Flags().GetString("output-dir")
+	opts.ReadWritePaths, _ = cmd.Flags().GetStringArray("read-write-path")
+
+	formatName, _ := cmd.Flags().GetString("format")
+	var err error
+	if opts.Format, err = parseArchiveFormat(formatName); err != nil {
+		return opts, err
+	}
+	if opts.Executable, err = os.Executable(); err != nil {
+		return opts, fmt.Errorf(tr("获取程序路径失败: %w"), err)
+	}
+
+	// 配置必须能加载，避免定时器每次都失败
+	cfg, _, err := loadConfig(opts.Config, opts.Profile)
+	if err != nil {
+		return opts, fmt.Errorf(tr("加载配置失败: %w"), err)
+	}
+	for _, c := range cfg.Snapshot {
+		if dir := c.parentDir(); dir != "" && !slices.Contains(opts.SnapshotDirs, dir) {
+			opts.SnapshotDirs = append(opts.SnapshotDirs, dir)
+		}
+	}
+	if opts.Config, err = filepath.Abs(opts.Config); err != nil {
+		return opts, err
+	}
+	if opts.OutputDir, err = filepath.Abs(opts.OutputDir); err != nil {
+		return opts, err
+	}
+	for i, p := range opts.ReadWritePaths {
+		if opts.ReadWritePaths[i], err = filepath.Abs(p); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// validate 补全 unit 名称并检查参数
+func (o *timerOptions) validate() error {
+	if o.Name == "" {
+		o.Name = "backtrack"
+		if o.Profile != "" {
+			o.Name += "-" + o.Profile
+		}
+	}
+	if !jobNamePattern.MatchString(o.Name) {
+		return fmt.Errorf(tr("unit 名称只能包含字母、数字、_、. 和 -: %q"), o.Name)
+	}
+	if strings.TrimSpace(o.OnCalendar) == "" {
+		return errors.New(tr("--on-calendar 不能为空"))
+	}
+	for _, p := range slices.Concat([]string{o.Executable, o.Config, o.OutputDir}, o.ReadWritePaths, o.SnapshotDirs) {
+		if !filepath.IsAbs(p) {
+			return fmt.Errorf(tr("unit 文件中的路径必须是绝对路径: %q"), p)
+		}
+	}
+	return nil
+}
+
+// ExecStart 返回 service 的启动命令，备份以默认的带时间戳的文件名写入工作目录
+func (o timerOptions) ExecStart() string {
+	args := []string{o.Executable, "backup", "--quiet", "--config", o.Config, "--format", string(o.Format)}
+	if o.Profile != "" {
+		args = append(args, "--profile", o.Profile)
+	}
+	for i, a := range args {
+		args[i] = systemdQuote(a)
+	}
+	return strings.Join(args, " ")
+}
+
+// runtimeDirRoot 开机时清空的目录，其中的快照目录由 systemd 作为 RuntimeDirectory 在启动时创建
+const runtimeDirRoot = "/run"
+
+// WritablePaths 返回 ReadWritePaths 的值：输出目录、快照所在的目录和额外指定的路径
+func (o timerOptions) WritablePaths() string {
+	paths := []string{o.OutputDir}
+	for _, dir := range o.SnapshotDirs {
+		if _, ok := runtimeDirectory(dir); !ok {
+			paths = append(paths, dir)
+		}
+	}
+	paths = append(paths, o.ReadWritePaths...)
+	for i, p := range paths {
+		paths[i] = systemdQuote(p)
+	}
+	return strings.Join(paths, " ")
+}
+
+// RuntimeDirectories 返回 RuntimeDirectory 的值，/run 下的快照目录开机后不存在，由 systemd 创建并允许写入
+func (o timerOptions) RuntimeDirectories() string {
+	var dirs []string
+	for _, dir := range o.SnapshotDirs {
+		if rel, ok := runtimeDirectory(dir); ok {
+			dirs = append(dirs, systemdQuote(rel))
+		}
+	}
+	return strings.Join(dirs, " ")
+}
+
+// runtimeDirectory 返回 /run 下的目录相对于 /run 的路径，不在 /run 下时返回 false
+func runtimeDirectory(dir string) (string, bool) {
+	rel, err := filepath.Rel(runtimeDirRoot, dir)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return rel, true
+}
+
+// systemdQuote 按 systemd 的规则转义命令行参数，% 转义为 %% 避免被当作说明符展开
+func systemdQuote(s string) string {
+	s = strings.ReplaceAll(s, "%", "%%")
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\;") {
+		return s
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`)
+	return `"` + r.Replace(s) + `"`
+}
+
+// serviceTemplate 备份任务，以低 CPU 和 IO 优先级运行，除输出目录、快照所在的目录和额外指定的路径外文件系统只读。
+// 多个 unit 可能共用 /run 下的快照目录，停止时保留，避免删除其他任务正在使用的快照
+var serviceTemplate = template.Must(template.New("service").Funcs(template.FuncMap{"quote": systemdQuote}).Parse(`[Unit]
+Description=BackTrack backup ({{.Name}})
+Documentation=https://github.com/leijux/back-track
+Wants=network-online.target
+After=network-online.target
+
+[Service]
+Type=oneshot
+WorkingDirectory={{quote .OutputDir}}
+ExecStart={{.ExecStart}}
+Nice=10
+IOSchedulingClass=idle
+CPUSchedulingPolicy=batch
+ProtectSystem=strict
+ProtectHome=read-only
+ReadWritePaths={{.WritablePaths}}
+{{- with .RuntimeDirectories}}
+RuntimeDirectory={{.}}
+RuntimeDirectoryPreserve=yes
+{{- end}}
+PrivateTmp=yes
+NoNewPrivileges=yes
+ProtectKernelTunables=yes
+ProtectKernelModules=yes
+ProtectControlGroups=yes
+RestrictRealtime=yes
+LockPersonality=yes
+`))
+
+// timerTemplate 按日历触发备份，错过的触发在开机后补上
+var timerTemplate = template.Must(template.New("timer").Parse(`[Unit]
+Description=BackTrack backup timer ({{.Name}})
+
+[Timer]
+OnCalendar={{.OnCalendar}}
+Persistent=true
+{{- if .RandomizedDelay}}
+RandomizedDelaySec={{.RandomizedDelay}}
+{{- end}}
+Unit={{.Name}}.service
+
+[Install]
+WantedBy=timers.target
+`))
+
+// renderUnits 生成 service 和 timer 文件内容
+func renderUnits(opts timerOptions) (service, timer []byte, err error) {
+	if err := opts.validate(); err != nil {
+		return nil, nil, err
+	}
+	var sb, tb bytes.Buffer
+	if err := serviceTemplate.Execute(&sb, opts); err != nil {
+		return nil, nil, fmt.Errorf(tr("生成 service 文件失败: %w"), err)
+	}
+	if err := timerTemplate.Execute(&tb, opts); err != nil {
+		return nil, nil, fmt.Errorf(tr("生成 timer 文件失败: %w"), err)
+	}
+	return sb.Bytes(), tb.Bytes(), nil
+}
+
+// installTimer 将 unit 文件写入 dir 并创建输出目录，返回写入的文件路径
+func installTimer(dir string, opts timerOptions) ([]string, error) {
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+	service, timer, err := renderUnits(opts)
+	if err != nil {
+		return nil, err
+	}
+	// WorkingDirectory 和 ReadWritePaths 在 service 启动前必须存在
+	if err := os.MkdirAll(opts.OutputDir, 0700); err != nil {
+		return nil, fmt.Errorf(tr("创建备份输出目录失败 (%s): %w"), opts.OutputDir, err)
+	}
+	for _, dir := range opts.SnapshotDirs {
+		if _, ok := runtimeDirectory(dir); ok {
+			continue
+		}
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return nil, fmt.Errorf(tr("创建快照目录失败 (%s): %w"), dir, err)
+		}
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf(tr("创建 unit 目录失败 (%s): %w"), dir, err)
+	}
+
+	paths := []string{filepath.Join(dir, opts.Name+".service"), filepath.Join(dir, opts.Name+".timer")}
+	for i, data := range [][]byte{service, timer} {
+		if err := os.WriteFile(paths[i], data, 0644); err != nil {
+			return nil, fmt.Errorf(tr("写入 unit 文件失败 (%s): %w"), paths[i], err)
+		}
+	}
+	return paths, nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_systemdQuote(t *testing.T) {
+	tests := []struct {
+		s    string
+		want string
+	}{
+		{s: "/usr/bin/backtrack", want: "/usr/bin/backtrack"},
+		{s: "/srv/my backups", want: `"/srv/my backups"`},
+		{s: `a"b\c`, want: `"a\"b\\c"`},
+		{s: "100%", want: "100%%"},
+		{s: "", want: `""`},
+	}
+	for _, tt := range tests {
+		if got := systemdQuote(tt.s); got != tt.want {
+			t.Errorf("systemdQuote(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+	}
+}
+
+func Test_renderUnits(t *testing.T) {
+	opts := timerOptions{
+		Executable:      "/usr/local/bin/backtrack",
+		Config:          "/etc/backtrack/config.yaml",
+		Profile:         "prod",
+		OnCalendar:      "*-*-* 03:30:00",
+		RandomizedDelay: "30min",
+		OutputDir:       "/srv/my backups",
+		Format:          formatTarZst,
+		ReadWritePaths:  []string{"/var/lib/app"},
+	}
+	service, timer, err := renderUnits(opts)
+	if err != nil {
+		t.Fatalf("renderUnits() error = %v", err)
+	}
+
+	for _, line := range []string{
+		"Type=oneshot",
+		`WorkingDirectory="/srv/my backups"`,
+		"ExecStart=/usr/local/bin/backtrack backup --quiet --config /etc/backtrack/config.yaml --format tar.zst --profile prod",
+		"Nice=10",
+		"IOSchedulingClass=idle",
+		"ProtectSystem=strict",
+		`ReadWritePaths="/srv/my backups" /var/lib/app`,
+	} {
+		if !strings.Contains(string(service), line+"\n") {
+			t.Errorf("service missing %q:\n%s", line, service)
+		}
+	}
+	for _, line := range []string{
+		"OnCalendar=*-*-* 03:30:00",
+		"Persistent=true",
+		"RandomizedDelaySec=30min",
+		"Unit=backtrack-prod.service",
+		"WantedBy=timers.target",
+	} {
+		if !strings.Contains(string(timer), line+"\n") {
+			t.Errorf("timer missing %q:\n%s", line, timer)
+		}
+	}
+
+	opts.RandomizedDelay = ""
+	if _, timer, _ = renderUnits(opts); strings.Contains(string(timer), "RandomizedDelaySec") {
+		t.Errorf("timer without delay:\n%s", timer)
+	}
+	if strings.Contains(string(service), "RuntimeDirectory") {
+		t.Errorf("service without snapshots:\n%s", service)
+	}
+
+	// 快照所在的目录可写，/run 下的目录开机后不存在，由 systemd 创建
+	btrfs := SnapshotConfig{Type: snapshotBtrfs, Path: "/home"}
+	lvm := SnapshotConfig{Type: snapshotLVM, Path: "/var/lib/mysql", Volume: "vg0/mysql"}
+	opts.SnapshotDirs = []string{btrfs.parentDir(), lvm.parentDir()}
+	service, _, err = renderUnits(opts)
+	if err != nil {
+		t.Fatalf("renderUnits() error = %v", err)
+	}
+	for _, line := range []string{
+		`ReadWritePaths="/srv/my backups" /home/.backtrack-snapshot /var/lib/app`,
+		"RuntimeDirectory=backtrack/snapshots",
+		"RuntimeDirectoryPreserve=yes",
+	} {
+		if !strings.Contains(string(service), line+"\n") {
+			t.Errorf("service missing %q:\n%s", line, service)
+		}
+	}
+
+	for name, bad := range map[string]timerOptions{
+		"relative config": {Executable: "/bin/bt", Config: "config.yaml", OnCalendar: "daily", OutputDir: "/srv"},
+		"empty calendar":  {Executable: "/bin/bt", Config: "/c.yaml", OutputDir: "/srv"},
+		"bad name":        {Name: "a/b", Executable: "/bin/bt", Config: "/c.yaml", OnCalendar: "daily", OutputDir: "/srv"},
+	} {
+		if _, _, err := renderUnits(bad); err == nil {
+			t.Errorf("renderUnits(%s) error = nil", name)
+		}
+	}
+}
+
+func Test_installTimer(t *testing.T) {
+	dir := t.TempDir()
+	opts := timerOptions{
+		Executable:   "/usr/local/bin/backtrack",
+		Config:       "/etc/backtrack/config.yaml",
+		OnCalendar:   "daily",
+		OutputDir:    filepath.Join(dir, "backups"),
+		Format:       formatZip,
+		SnapshotDirs: []string{filepath.Join(dir, "snapshots")},
+	}
+	unitDir := filepath.Join(dir, "units")
+	paths, err := installTimer(unitDir, opts)
+	if err != nil {
+		t.Fatalf("installTimer() error = %v", err)
+	}
+	want := []string{filepath.Join(unitDir, "backtrack.service"), filepath.Join(unitDir, "backtrack.timer")}
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Errorf("installTimer() = %v, want %v", paths, want)
+	}
+	for _, p := range append(want, opts.OutputDir, opts.SnapshotDirs[0]) {
+		if _, err := os.Stat(p); err != nil {
+			t.Error(err)
+		}
+	}
+}
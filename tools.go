@@ -1,9 +1,14 @@
 package main
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/schollz/progressbar/v3"
 	"github.com/spf13/cobra"
@@ -11,7 +16,7 @@ import (
 
 func checkRoot(cmd *cobra.Command, args []string) error {
 	if os.Geteuid() != 0 {
-		return fmt.Errorf("请以root权限运行")
+		return errors.New(tr("请以root权限运行"))
 	}
 	return nil
 }
@@ -21,15 +26,93 @@ func runCommand(name string, args ...string) (string, error) {
 	cmd := exec.Command(name, args...)
 	output, err := cmd.CombinedOutput()
 	if err != nil {
-		return "", fmt.Errorf("命令执行失败 (%s %v): %w, 输出: %s",
+		return "", fmt.Errorf(tr("命令执行失败 (%s %v): %w, 输出: %s"),
 			name, args, err, string(output))
 	}
 	return string(output), nil
 }
 
+// formatSize 将字节数格式化为带单位的大小，单位按1024换算
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%dB", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%c", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// progressReporter 进度显示，终端进度条和 config 查看器的还原进度视图都实现该接口
+type progressReporter interface {
+	Add(num int) error
+	Describe(description string)
+}
+
 func newProgressBar(filesToRestoreCount int64, quiet bool, description string) *progressbar.ProgressBar {
 	if quiet {
 		return progressbar.DefaultSilent(filesToRestoreCount, description)
 	}
 	return progressbar.Default(filesToRestoreCount, description)
 }
+
+// parseSize 解析带单位的大小（如 4G、512M、100K），单位按1024换算，空字符串返回0
+func parseSize(size string) (int64, error) {
+	s := strings.ToUpper(strings.TrimSpace(size))
+	if s == "" {
+		return 0, nil
+	}
+
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+	multiplier := int64(1)
+	if i := len(s) - 1; i >= 0 {
+		switch s[i] {
+		case 'K':
+			multiplier = 1 << 10
+		case 'M':
+			multiplier = 1 << 20
+		case 'G':
+			multiplier = 1 << 30
+		case 'T':
+			multiplier = 1 << 40
+		}
+		if multiplier > 1 {
+			s = s[:i]
+		}
+	}
+
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf(tr("无法解析大小: %q"), size)
+	}
+	return int64(n * float64(multiplier)), nil
+}
+
+var dayDuration = regexp.MustCompile(`^(\d+)([dw])`)
+
+// parseDuration 解析时长，在 time.ParseDuration 基础上支持 d(天) 和 w(周)，如 7d、2w、1d12h
+func parseDuration(s string) (time.Duration, error) {
+	s = strings.TrimSpace(s)
+
+	var d time.Duration
+	if m := dayDuration.FindStringSubmatch(s); m != nil {
+		n, _ := strconv.Atoi(m[1])
+		d = time.Duration(n) * 24 * time.Hour
+		if m[2] == "w" {
+			d *= 7
+		}
+		s = s[len(m[0]):]
+		if s == "" {
+			return d, nil
+		}
+	}
+
+	rest, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf(tr("无法解析时长: %w"), err)
+	}
+	return d + rest, nil
+}
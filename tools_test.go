@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func Test_parseSize(t *testing.T) {
+	tests := []struct {
+		name    string
+		size    string
+		want    int64
+		wantErr bool
+	}{
+		{name: "empty", size: "", want: 0},
+		{name: "bytes", size: "1024", want: 1024},
+		{name: "kilobytes", size: "100K", want: 100 << 10},
+		{name: "megabytes", size: "700MB", want: 700 << 20},
+		{name: "gigabytes", size: "4G", want: 4 << 30},
+		{name: "gibibytes", size: "1.5GiB", want: 3 << 29},
+		{name: "invalid", size: "big", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseSize(tt.size)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseSize() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseSize() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func Test_parseDuration(t *testing.T) {
+	tests := []struct {
+		name    string
+		s       string
+		want    time.Duration
+		wantErr bool
+	}{
+		{name: "hours", s: "36h", want: 36 * time.Hour},
+		{name: "days", s: "7d", want: 7 * 24 * time.Hour},
+		{name: "weeks", s: "2w", want: 14 * 24 * time.Hour},
+		{name: "days and hours", s: "1d12h", want: 36 * time.Hour},
+		{name: "invalid", s: "soon", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseDuration(tt.s)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseDuration() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("parseDuration() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
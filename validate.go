@@ -0,0 +1,300 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/goccy/go-yaml"
+	"github.com/spf13/cobra"
+)
+
+// configValidateCmd 校验配置文件或备份包中的配置
+var configValidateCmd = &cobra.Command{
+	Use:   "validate <file|archive>",
+	Short: "校验配置文件或备份包中的配置",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		profile, _ := cmd.Flags().GetString("profile")
+
+		warnings, err := validateConfigSource(args[0], profile)
+		result.Input, result.Warnings = args[0], warnings
+		for _, w := range warnings {
+			fmt.Fprintf(cmd.ErrOrStderr(), tr("警告: %s\n"), w)
+		}
+		if err != nil {
+			cmd.SilenceUsage = true
+			return fmt.Errorf(tr("配置无效 (%s):\n%w"), args[0], err)
+		}
+
+		fmt.Fprintf(textOutput(cmd), tr("配置有效: %s\n"), args[0])
+		return nil
+	},
+}
+
+func init() {
+	configValidateCmd.Flags().String("profile", "", "使用配置文件中的命名配置")
+	configCmd.AddCommand(configValidateCmd)
+}
+
+// decodeConfig 严格解析配置，未知字段和类型错误带行列号
+func decodeConfig(path string, data []byte) (*Config, error) {
+	var cfg Config
+	if err := yaml.UnmarshalWithOptions(data, &cfg, yaml.Strict()); err != nil {
+		return nil, fmt.Errorf(tr("解析YAML配置失败 (%s):\n%s"), path, yaml.FormatError(err, false, true))
+	}
+	return &cfg, nil
+}
+
+// validate 检查配置语义：备份路径、排除规则、筛选条件、压缩配置和脚本
+func (c *Config) validate() error {
+	var errs []error
+	if len(c.BackupPaths) == 0 {
+		errs = append(errs, errors.New(tr("backup_paths 不能为空")))
+	}
+	for _, bp := range c.BackupPaths {
+		if err := bp.validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if _, err := globalIgnoreRules(c); err != nil {
+		errs = append(errs, fmt.Errorf(tr("排除规则: %w"), err))
+	}
+	for p, lines := range c.PathRules {
+		if _, err := parseIgnoreRules(p, lines, true); err != nil {
+			errs = append(errs, fmt.Errorf("path_rules %s: %w", p, err))
+		}
+	}
+
+	// one_file_system 需要访问备份路径，这里只检查筛选参数
+	filterCfg := *c
+	filterCfg.OneFileSystem = false
+	if _, err := newFileFilter(&filterCfg, "", time.Now()); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := c.Compression.validate(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if err := validateScript("before_script", c.BeforeScript); err != nil {
+		errs = append(errs, err)
+	}
+	if err := validateScript("after_script", c.AfterScript); err != nil {
+		errs = append(errs, err)
+	}
+	for i, s := range c.Snapshot {
+		if err := s.validate(); err != nil {
+			errs = append(errs, fmt.Errorf("snapshot[%d]: %w", i, err))
+		}
+	}
+	if err := c.Notify.validate(); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// checkPaths 检查备份路径是否存在，缺少 required 路径返回错误，其他缺少的路径作为警告
+func (c *Config) checkPaths() (warnings []string, err error) {
+	var errs []error
+	for _, bp := range c.BackupPaths {
+		if _, err := os.Stat(bp.Path); err != nil {
+			if bp.Required {
+				errs = append(errs, fmt.Errorf(tr("必需的备份路径无法访问 (%s): %w"), bp.Path, err))
+			} else {
+				warnings = append(warnings, fmt.Sprintf(tr("备份路径无法访问，备份时将跳过 (%s): %v"), bp.Path, err))
+			}
+		}
+	}
+	return warnings, errors.Join(errs...)
+}
+
+// validateScript 检查脚本不是空白内容，并用 sh -n 检查语法
+func validateScript(name, script string) error {
+	if script == "" {
+		return nil
+	}
+	if strings.TrimSpace(script) == "" {
+		return fmt.Errorf(tr("%s 只包含空白字符"), name)
+	}
+
+	var stderr bytes.Buffer
+	cmd := exec.Command("sh", "-n", "-c", script)
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return nil // 没有可用的 sh 时跳过语法检查
+		}
+		return fmt.Errorf(tr("%s 语法错误: %s"), name, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// validateConfigSource 校验配置文件，或备份包中的配置和文件映射
+func validateConfigSource(path, profile string) (warnings []string, err error) {
+	if isArchiveFile(path) {
+		return nil, validateArchiveConfig(path)
+	}
+
+	cfg, _, err := loadConfig(path, profile)
+	if err != nil {
+		return nil, err
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return cfg.checkPaths()
+}
+
+// isArchiveFile 根据文件头判断是否为备份包
+func isArchiveFile(path string) bool {
+	paths, err := findVolumes(path)
+	if err != nil {
+		return false
+	}
+	f, err := os.Open(paths[0])
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+
+	_, err = detectArchiveFormat(f)
+	return err == nil
+}
+
+// validateArchiveConfig 校验备份包中的配置和文件映射
+func validateArchiveConfig(path string) error {
+	ar, err := openArchive(path)
+	if err != nil {
+		return err
+	}
+	defer ar.Close()
+
+	entries, files, err := readArchiveIndex(ar)
+	if err != nil {
+		return err
+	}
+
+	if _, err := archiveVersion(files); err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, name := range []string{backupConfigName, backupFileMapName} {
+		data, ok := files[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf(tr("备份文件中未找到 %s"), name))
+			continue
+		}
+		if err := validateMetadataFile(name, data); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if name == backupFileMapName {
+			fileMap, _ := decodeFileMap(data)
+			if err := checkFileMapEntries(fileMap, entries); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// readArchiveIndex 一次遍历返回备份包中的数据条目名称和元数据文件内容
+func readArchiveIndex(ar archiveReader) (entries []string, metadata map[string][]byte, err error) {
+	metadata = make(map[string][]byte)
+	err = ar.Walk(func(e *archiveEntry) error {
+		if !isMetadataFile(e.Name) {
+			entries = append(entries, e.Name)
+			return nil
+		}
+		data, err := readEntry(e.Open)
+		if err != nil {
+			return fmt.Errorf(tr("读取 %s 失败: %w"), e.Name, err)
+		}
+		metadata[e.Name] = data
+		return nil
+	})
+	return entries, metadata, err
+}
+
+// decodeFileMap 严格解析文件映射
+func decodeFileMap(data []byte) (FileMap, error) {
+	var fileMap FileMap
+	if err := yaml.UnmarshalWithOptions(data, &fileMap, yaml.Strict()); err != nil {
+		return nil, fmt.Errorf(tr("解析文件映射失败 (%s):\n%s"), backupFileMapName, yaml.FormatError(err, false, true))
+	}
+	return fileMap, nil
+}
+
+// checkFileMapEntries 对照备份包条目检查文件映射：引用不存在的条目会导致还原失败，
+// 遗漏的条目在还原时会被跳过，多个条目还原到同一路径会互相覆盖
+func checkFileMapEntries(fileMap FileMap, entries []string) error {
+	var errs []error
+
+	inArchive := make(map[string]bool, len(entries))
+	for _, name := range entries {
+		inArchive[name] = true
+		if _, ok := fileMap[name]; !ok {
+			errs = append(errs, fmt.Errorf(tr("条目 %s 不在文件映射中，还原时将被跳过"), name))
+		}
+	}
+
+	targets := make(map[string]string, len(fileMap))
+	for _, name := range slices.Sorted(maps.Keys(fileMap)) {
+		path := fileMap[name]
+		if !inArchive[name] {
+			errs = append(errs, fmt.Errorf(tr("文件映射引用了备份包中不存在的条目 %s"), name))
+		}
+		if other, ok := targets[path]; ok {
+			errs = append(errs, fmt.Errorf(tr("条目 %s 和 %s 还原到同一路径 %s"), other, name, path))
+		}
+		targets[path] = name
+	}
+	return errors.Join(errs...)
+}
+
+// validateMetadataFile 校验备份包中的元数据文件，其他文件只检查能否解析
+func validateMetadataFile(name string, data []byte) error {
+	switch name {
+	case backupConfigName:
+		cfg, err := decodeConfig(name, data)
+		if err != nil {
+			return err
+		}
+		if err := cfg.validate(); err != nil {
+			return fmt.Errorf("%s:\n%w", name, err)
+		}
+	case backupFileMapName:
+		fileMap, err := decodeFileMap(data)
+		if err != nil {
+			return err
+		}
+		var errs []error
+		for _, entry := range slices.Sorted(maps.Keys(fileMap)) {
+			switch path := fileMap[entry]; {
+			case isMetadataFile(entry):
+				errs = append(errs, fmt.Errorf(tr("%s: 不能映射元数据文件 %s"), name, entry))
+			case path == "":
+				errs = append(errs, fmt.Errorf(tr("%s: %s 缺少原路径"), name, entry))
+			case !filepath.IsAbs(path):
+				errs = append(errs, fmt.Errorf(tr("%s: %s 的原路径必须是绝对路径: %s"), name, entry, path))
+			}
+		}
+		return errors.Join(errs...)
+	default:
+		var v map[string]any
+		if err := yaml.Unmarshal(data, &v); err != nil {
+			return fmt.Errorf(tr("配置文件格式无效 (%s): %w"), name, err)
+		}
+	}
+	return nil
+}
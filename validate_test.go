@@ -0,0 +1,174 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func Test_decodeConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		wantErr string
+	}{
+		{name: "valid", data: "backup_paths:\n  - /etc\nmax_file_size: 1G\n"},
+		{name: "unknown key", data: "backup_paths:\n  - /etc\nexclude_file: [\"*.log\"]\n", wantErr: `[3:1] unknown field "exclude_file"`},
+		{name: "unknown path option", data: "backup_paths:\n  - path: /etc\n    exclude: [x]\n", wantErr: `[3:5] unknown field "exclude"`},
+		{name: "wrong type", data: "one_file_system: maybe\n", wantErr: "[1:18]"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decodeConfig("config.yaml", []byte(tt.data))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("decodeConfig() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("decodeConfig() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_Config_validate(t *testing.T) {
+	paths := []BackupPath{{Path: "/etc"}}
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{name: "valid", cfg: Config{BackupPaths: paths, Rules: []string{"*.log"}, BeforeScript: "echo ok"}},
+		{name: "empty backup_paths", cfg: Config{}, wantErr: true},
+		{name: "bad rule", cfg: Config{BackupPaths: paths, Rules: []string{"[a-"}}, wantErr: true},
+		{name: "bad path rule", cfg: Config{BackupPaths: paths, PathRules: map[string][]string{"/etc": {"[a-"}}}, wantErr: true},
+		{name: "bad per-path exclude", cfg: Config{BackupPaths: []BackupPath{{Path: "/etc", Excludes: []string{"[a-"}}}}, wantErr: true},
+		{name: "bad size", cfg: Config{BackupPaths: paths, MaxFileSize: "huge"}, wantErr: true},
+		{name: "bad compression", cfg: Config{BackupPaths: paths, Compression: CompressionConfig{Algorithm: "lzma"}}, wantErr: true},
+		{name: "blank script", cfg: Config{BackupPaths: paths, AfterScript: "  \n"}, wantErr: true},
+		{name: "script syntax", cfg: Config{BackupPaths: paths, BeforeScript: "if true; then echo"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.cfg.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func Test_validateConfigSource(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		"ok.yaml":       "backup_paths:\n  - " + dir + "\n  - " + filepath.Join(dir, "missing") + "\n",
+		"required.yaml": "backup_paths:\n  - path: " + filepath.Join(dir, "missing") + "\n    required: true\n",
+		"typo.yaml":     "backup_paths:\n  - " + dir + "\nexclude_file: [x]\n",
+	})
+
+	cfg, configBytes, err := loadConfig("testdata/config.yaml", "")
+	if err != nil {
+		t.Fatalf("loadConfig() error = %v", err)
+	}
+	archivePath := filepath.Join(dir, "backup.tar.zst")
+	if err := backup(t.Context(), cfg, configBytes, archivePath, backupOptions{Format: formatTarZst, Quiet: true}); err != nil {
+		t.Fatalf("backup() error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		path         string
+		wantWarnings int
+		wantErr      bool
+	}{
+		{name: "valid with missing optional path", path: "ok.yaml", wantWarnings: 1},
+		{name: "missing required path", path: "required.yaml", wantErr: true},
+		{name: "unknown key", path: "typo.yaml", wantErr: true},
+		{name: "archive", path: "backup.tar.zst"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			warnings, err := validateConfigSource(filepath.Join(dir, tt.path), "")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("validateConfigSource() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(warnings) != tt.wantWarnings {
+				t.Errorf("validateConfigSource() warnings = %v, want %d", warnings, tt.wantWarnings)
+			}
+		})
+	}
+}
+
+// Test_configSchema 确保 JSON Schema 覆盖配置中的所有字段
+func Test_configSchema(t *testing.T) {
+	data, err := os.ReadFile("config.schema.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type object struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	var schema struct {
+		object
+		Defs struct {
+			Compression object `json:"compression"`
+			BackupPath  struct {
+				OneOf []object `json:"oneOf"`
+			} `json:"backupPath"`
+			Snapshot      object `json:"snapshot"`
+			Notify        object `json:"notify"`
+			WebhookNotify object `json:"webhookNotify"`
+			EmailNotify   object `json:"emailNotify"`
+			CommandNotify object `json:"commandNotify"`
+		} `json:"$defs"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatalf("解析 schema 失败: %v", err)
+	}
+
+	check := func(typ reflect.Type, props map[string]json.RawMessage) {
+		for i := range typ.NumField() {
+			name, _, _ := strings.Cut(typ.Field(i).Tag.Get("yaml"), ",")
+			if _, ok := props[name]; !ok {
+				t.Errorf("schema 缺少 %s.%s (%s)", typ.Name(), typ.Field(i).Name, name)
+			}
+		}
+	}
+	check(reflect.TypeFor[Config](), schema.Properties)
+	check(reflect.TypeFor[CompressionConfig](), schema.Defs.Compression.Properties)
+	if len(schema.Defs.BackupPath.OneOf) != 2 {
+		t.Fatalf("schema backupPath 应包含字符串和对象两种形式")
+	}
+	check(reflect.TypeFor[BackupPath](), schema.Defs.BackupPath.OneOf[1].Properties)
+	check(reflect.TypeFor[SnapshotConfig](), schema.Defs.Snapshot.Properties)
+	check(reflect.TypeFor[NotifyConfig](), schema.Defs.Notify.Properties)
+	check(reflect.TypeFor[WebhookNotify](), schema.Defs.WebhookNotify.Properties)
+	check(reflect.TypeFor[EmailNotify](), schema.Defs.EmailNotify.Properties)
+	check(reflect.TypeFor[CommandNotify](), schema.Defs.CommandNotify.Properties)
+}
+
+func Test_checkFileMapEntries(t *testing.T) {
+	entries := []string{"data/a", "data/b"}
+	tests := []struct {
+		name    string
+		fileMap FileMap
+		wantErr bool
+	}{
+		{name: "consistent", fileMap: FileMap{"data/a": "/a", "data/b": "/b"}},
+		{name: "missing entry", fileMap: FileMap{"data/a": "/a"}, wantErr: true},
+		{name: "unknown entry", fileMap: FileMap{"data/a": "/a", "data/b": "/b", "data/c": "/c"}, wantErr: true},
+		{name: "same target", fileMap: FileMap{"data/a": "/a", "data/b": "/a"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := checkFileMapEntries(tt.fileMap, entries); (err != nil) != tt.wantErr {
+				t.Errorf("checkFileMapEntries() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,332 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// volumeSuffix 分卷文件后缀，如 backup.zip.001
+var volumeSuffix = regexp.MustCompile(`\.(\d{3})$`)
+
+// volumeCountMagic 第一个分卷头部预留的分卷数量字段的标记，后跟4字节小端序的数量。
+// 字段由各格式写入允许附加数据的位置（zip条目扩展字段、gzip头部扩展字段、zstd可跳过帧），写完后填入实际数量
+var volumeCountMagic = []byte("BTVOLCNT")
+
+// headerFieldScan 读取头部字段（分卷数量、开始时间）时查找的长度
+const headerFieldScan = 512
+
+// volumeCountField 返回未填写数量的分卷数量字段
+func volumeCountField() []byte {
+	return append(bytes.Clone(volumeCountMagic), 0, 0, 0, 0)
+}
+
+// outputFile 备份输出，普通文件或分卷
+type outputFile interface {
+	io.WriteCloser
+	// Remove 删除已写入的所有文件
+	Remove() error
+}
+
+// createOutputFile 创建备份输出，volumeSize 大于0时按大小分卷
+func createOutputFile(path string, volumeSize int64) (outputFile, error) {
+	if volumeSize > 0 {
+		return &volumeWriter{base: path, size: volumeSize}, nil
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, err
+	}
+	return singleOutput{f}, nil
+}
+
+type singleOutput struct {
+	*os.File
+}
+
+func (o singleOutput) Remove() error {
+	o.Close()
+	return os.Remove(o.Name())
+}
+
+// volumeWriter 按固定大小切分输出，依次写入 base.001、base.002 ...
+type volumeWriter struct {
+	base    string
+	size    int64
+	cur     *os.File
+	written int64 // 当前分卷已写入字节数
+	paths   []string
+	closed  bool
+}
+
+func (v *volumeWriter) Write(p []byte) (int, error) {
+	var n int
+	for len(p) > 0 {
+		if v.cur == nil || v.written >= v.size {
+			if err := v.next(); err != nil {
+				return n, err
+			}
+		}
+
+		chunk := p[:min(int64(len(p)), v.size-v.written)]
+		m, err := v.cur.Write(chunk)
+		n += m
+		v.written += int64(m)
+		if err != nil {
+			return n, err
+		}
+		p = p[m:]
+	}
+	return n, nil
+}
+
+// next 关闭当前分卷并创建下一个
+func (v *volumeWriter) next() error {
+	if v.cur != nil {
+		if err := v.cur.Close(); err != nil {
+			return fmt.Errorf(tr("关闭分卷失败 (%s): %w"), v.cur.Name(), err)
+		}
+	}
+
+	path := volumePath(v.base, len(v.paths)+1)
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf(tr("创建分卷失败 (%s): %w"), path, err)
+	}
+
+	v.cur = f
+	v.written = 0
+	v.paths = append(v.paths, path)
+	return nil
+}
+
+// Close 关闭最后一个分卷，并在第一个分卷中填入分卷数量
+func (v *volumeWriter) Close() error {
+	if v.closed {
+		return nil
+	}
+	v.closed = true
+	if err := v.closeCurrent(); err != nil {
+		return err
+	}
+	if len(v.paths) == 0 {
+		return nil
+	}
+	if err := writeVolumeCount(v.paths[0], len(v.paths)); err != nil {
+		return fmt.Errorf(tr("写入分卷数量失败 (%s): %w"), v.paths[0], err)
+	}
+	return nil
+}
+
+// closeCurrent 关闭当前分卷
+func (v *volumeWriter) closeCurrent() error {
+	if v.cur == nil {
+		return nil
+	}
+	err := v.cur.Close()
+	v.cur = nil
+	return err
+}
+
+func (v *volumeWriter) Remove() error {
+	v.closed = true
+	v.closeCurrent()
+	var errs []error
+	for _, path := range v.paths {
+		errs = append(errs, os.Remove(path))
+	}
+	return errors.Join(errs...)
+}
+
+// Volumes 返回已创建的分卷路径
+func (v *volumeWriter) Volumes() []string {
+	return v.paths
+}
+
+// writeVolumeCount 在第一个分卷预留的字段中写入分卷数量
+func writeVolumeCount(path string, count int) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	head := make([]byte, headerFieldScan)
+	n, err := f.ReadAt(head, 0)
+	if err != nil && err != io.EOF {
+		return err
+	}
+	i := bytes.Index(head[:n], volumeCountMagic)
+	if i < 0 {
+		return errors.New(tr("未找到预留的分卷数量字段"))
+	}
+	_, err = f.WriteAt(binary.LittleEndian.AppendUint32(nil, uint32(count)), int64(i+len(volumeCountMagic)))
+	return err
+}
+
+// readVolumeCount 读取第一个分卷中记录的分卷数量，未记录时返回0
+func readVolumeCount(path string) int {
+	field := readHeaderField(path, volumeCountMagic, 4)
+	if field == nil {
+		return 0
+	}
+	return int(binary.LittleEndian.Uint32(field))
+}
+
+// readHeaderField 在文件头部查找以 magic 标记的字段，返回标记后的 size 字节，未找到时返回 nil
+func readHeaderField(path string, magic []byte, size int) []byte {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	head := make([]byte, headerFieldScan)
+	n, _ := io.ReadFull(f, head)
+	head = head[:n]
+	i := bytes.Index(head, magic)
+	if i < 0 || len(head) < i+len(magic)+size {
+		return nil
+	}
+	return head[i+len(magic) : i+len(magic)+size]
+}
+
+// volumePath 返回第 n 个分卷的路径
+func volumePath(base string, n int) string {
+	return fmt.Sprintf("%s.%03d", base, n)
+}
+
+// archiveSource 备份包数据源，单个文件或按顺序拼接的分卷
+type archiveSource struct {
+	files []*os.File
+	sizes []int64
+	size  int64
+}
+
+// openArchiveSource 打开备份文件，自动识别分卷
+func openArchiveSource(path string) (*archiveSource, error) {
+	paths, err := findVolumes(path)
+	if err != nil {
+		return nil, err
+	}
+
+	src := &archiveSource{}
+	for _, p := range paths {
+		f, err := os.Open(p)
+		if err != nil {
+			src.Close()
+			return nil, fmt.Errorf(tr("打开备份文件失败 (%s): %w"), p, err)
+		}
+		info, err := f.Stat()
+		if err != nil {
+			f.Close()
+			src.Close()
+			return nil, fmt.Errorf(tr("获取文件信息失败 (%s): %w"), p, err)
+		}
+
+		src.files = append(src.files, f)
+		src.sizes = append(src.sizes, info.Size())
+		src.size += info.Size()
+	}
+	return src, nil
+}
+
+// findVolumes 返回组成备份包的文件列表，path 可以是分卷的基础名或第一个分卷
+func findVolumes(path string) ([]string, error) {
+	base := path
+	if m := volumeSuffix.FindStringSubmatch(path); m != nil {
+		base = strings.TrimSuffix(path, "."+m[1])
+	} else if _, err := os.Stat(volumePath(path, 1)); err != nil {
+		return []string{path}, nil
+	}
+
+	matches, err := filepath.Glob(globEscape(base) + ".[0-9][0-9][0-9]")
+	if err != nil {
+		return nil, fmt.Errorf(tr("查找分卷失败 (%s): %w"), base, err)
+	}
+
+	var numbers []int
+	for _, m := range matches {
+		n, _ := strconv.Atoi(m[len(m)-3:])
+		numbers = append(numbers, n)
+	}
+	slices.Sort(numbers)
+
+	// 第一个分卷记录了分卷数量，据此发现缺少的末尾分卷，多出的分卷不属于该备份
+	count := readVolumeCount(volumePath(base, 1))
+	if count > 0 {
+		numbers = slices.DeleteFunc(numbers, func(n int) bool { return n > count })
+	}
+
+	paths := make([]string, 0, len(numbers))
+	for i, n := range numbers {
+		if n != i+1 {
+			return nil, fmt.Errorf(tr("分卷不完整，缺少 %s"), volumePath(base, i+1))
+		}
+		paths = append(paths, volumePath(base, n))
+	}
+	if len(paths) == 0 || len(paths) < count {
+		return nil, fmt.Errorf(tr("分卷不完整，缺少 %s"), volumePath(base, len(paths)+1))
+	}
+	return paths, nil
+}
+
+// globEscape 转义路径中的通配符
+func globEscape(path string) string {
+	var b strings.Builder
+	for _, r := range path {
+		if strings.ContainsRune(`*?[\`, r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// Volumes 返回分卷数量
+func (s *archiveSource) Volumes() int {
+	return len(s.files)
+}
+
+func (s *archiveSource) Size() int64 {
+	return s.size
+}
+
+// ReadAt 跨分卷读取数据
+func (s *archiveSource) ReadAt(p []byte, off int64) (int, error) {
+	var n int
+	for i, f := range s.files {
+		if off >= s.sizes[i] {
+			off -= s.sizes[i]
+			continue
+		}
+
+		m, err := f.ReadAt(p[n:min(len(p), n+int(s.sizes[i]-off))], off)
+		n += m
+		if err != nil && err != io.EOF {
+			return n, err
+		}
+		if n == len(p) {
+			return n, nil
+		}
+		off = 0
+	}
+	return n, io.EOF
+}
+
+func (s *archiveSource) Close() error {
+	var errs []error
+	for _, f := range s.files {
+		errs = append(errs, f.Close())
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func Test_volumeBackup(t *testing.T) {
+	tests := []struct {
+		name    string
+		format  archiveFormat
+		missing int // 删除的分卷序号，0 表示不删除，-1 表示最后一个分卷
+		wantErr bool
+	}{
+		{name: "zip", format: formatZip},
+		{name: "tar.zst", format: formatTarZst},
+		{name: "tar.gz", format: formatTarGz},
+		{name: "zip missing volume", format: formatZip, missing: 2, wantErr: true},
+		{name: "tar.gz missing volume", format: formatTarGz, missing: 1, wantErr: true},
+		{name: "zip missing last volume", format: formatZip, missing: -1, wantErr: true},
+		{name: "tar.zst missing last volume", format: formatTarZst, missing: -1, wantErr: true},
+		{name: "tar.gz missing last volume", format: formatTarGz, missing: -1, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, configBytes, err := loadConfig("testdata/config.yaml", "")
+			if err != nil {
+				t.Fatalf("loadConfig() error = %v", err)
+			}
+
+			outputPath := filepath.Join(t.TempDir(), "output."+string(tt.format))
+			opts := backupOptions{Format: tt.format, VolumeSize: 128, Quiet: true}
+			if err := backup(t.Context(), cfg, configBytes, outputPath, opts); err != nil {
+				t.Fatalf("backup() error = %v", err)
+			}
+			if _, err := os.Stat(volumePath(outputPath, 3)); err != nil {
+				t.Fatalf("expected at least 3 volumes: %v", err)
+			}
+			volumes, err := findVolumes(outputPath)
+			if err != nil {
+				t.Fatalf("findVolumes() error = %v", err)
+			}
+			if got := readVolumeCount(volumes[0]); got != len(volumes) {
+				t.Fatalf("readVolumeCount() = %d, want %d", got, len(volumes))
+			}
+			if tt.missing > 0 {
+				os.Remove(volumePath(outputPath, tt.missing))
+			}
+			if tt.missing < 0 {
+				last := volumes[len(volumes)-1]
+				os.Remove(last)
+				if _, err := findVolumes(outputPath); err == nil || !strings.Contains(err.Error(), last) {
+					t.Errorf("findVolumes() error = %v, want missing %s", err, last)
+				}
+			}
+
+			tempDir := t.TempDir()
+			err = restore(t.Context(), outputPath, restoreOptions{RootDir: tempDir, Quiet: true})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("restore() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			absPath, _ := filepath.Abs("testdata/backup/data3.txt")
+			if !filesMatchContent(map[string]string{absPath: "test3"}, tempDir) {
+				t.Errorf("restore() %s content mismatch", absPath)
+			}
+		})
+	}
+}
+
+func Test_findVolumes(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "backup.zip")
+	for _, n := range []int{1, 2, 3} {
+		os.WriteFile(volumePath(base, n), nil, 0644)
+	}
+	single := filepath.Join(dir, "single.zip")
+	os.WriteFile(single, nil, 0644)
+
+	// 第一个分卷记录的数量多于或少于实际存在的分卷
+	counted := func(name string, count, present int) string {
+		base := filepath.Join(dir, name)
+		for n := 1; n <= present; n++ {
+			os.WriteFile(volumePath(base, n), nil, 0644)
+		}
+		os.WriteFile(volumePath(base, 1), append([]byte("PK\x03\x04"), volumeCountField()...), 0644)
+		if err := writeVolumeCount(volumePath(base, 1), count); err != nil {
+			t.Fatal(err)
+		}
+		return base
+	}
+	truncated := counted("truncated.zip", 3, 2)
+	stale := counted("stale.zip", 2, 3)
+
+	tests := []struct {
+		name    string
+		path    string
+		want    int
+		wantErr bool
+	}{
+		{name: "single file", path: single, want: 1},
+		{name: "base name", path: base, want: 3},
+		{name: "first volume", path: volumePath(base, 1), want: 3},
+		{name: "missing last volume", path: truncated, wantErr: true},
+		{name: "extra volume", path: stale, want: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := findVolumes(tt.path)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("findVolumes() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if len(got) != tt.want {
+				t.Errorf("findVolumes() = %v, want %d volumes", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,245 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+// zip 目录结构的签名和固定长度
+const (
+	zipCentralSig   = 0x02014b50
+	zipEndSig       = 0x06054b50
+	zip64EndSig     = 0x06064b50
+	zip64LocatorSig = 0x07064b50
+
+	zipCentralLen   = 46
+	zipEndLen       = 22
+	zip64EndLen     = 56
+	zip64LocatorLen = 20
+)
+
+// zipDirectory zip 中央目录的位置和备份包注释
+type zipDirectory struct {
+	offset  int64
+	size    int64
+	records int64
+	comment []byte
+}
+
+// zipRecord 中央目录中一个条目的原始记录
+type zipRecord struct {
+	name string
+	raw  []byte
+}
+
+// readZipDirectory 从 [start, size) 末尾的目录结束记录读取中央目录位置，支持 zip64
+func readZipDirectory(r io.ReaderAt, start, size int64) (zipDirectory, error) {
+	var dir zipDirectory
+
+	n := min(size-start, zipEndLen+0xffff)
+	buf := make([]byte, n)
+	if _, err := r.ReadAt(buf, size-n); err != nil && err != io.EOF {
+		return dir, fmt.Errorf(tr("读取目录结束记录失败: %w"), err)
+	}
+
+	i := len(buf) - zipEndLen
+	for ; i >= 0; i-- {
+		if binary.LittleEndian.Uint32(buf[i:]) == zipEndSig &&
+			i+zipEndLen+int(binary.LittleEndian.Uint16(buf[i+20:])) <= len(buf) {
+			break
+		}
+	}
+	if i < 0 {
+		return dir, errors.New(tr("未找到zip目录结束记录"))
+	}
+	end := buf[i:]
+	dir.records = int64(binary.LittleEndian.Uint16(end[10:]))
+	dir.size = int64(binary.LittleEndian.Uint32(end[12:]))
+	dir.offset = int64(binary.LittleEndian.Uint32(end[16:]))
+	dir.comment = bytes.Clone(end[zipEndLen : zipEndLen+int(binary.LittleEndian.Uint16(end[20:]))])
+	if dir.records != 0xffff && dir.size != 0xffffffff && dir.offset != 0xffffffff {
+		return dir, nil
+	}
+
+	// zip64 目录结束记录由紧挨着目录结束记录之前的定位记录指出
+	endOffset := size - n + int64(i)
+	if endOffset-start < zip64LocatorLen {
+		return dir, errors.New(tr("未找到zip64目录定位记录"))
+	}
+	loc := make([]byte, zip64LocatorLen)
+	if _, err := r.ReadAt(loc, endOffset-zip64LocatorLen); err != nil {
+		return dir, fmt.Errorf(tr("读取zip64目录定位记录失败: %w"), err)
+	}
+	if binary.LittleEndian.Uint32(loc) != zip64LocatorSig {
+		return dir, errors.New(tr("未找到zip64目录定位记录"))
+	}
+	rec := make([]byte, zip64EndLen)
+	if _, err := r.ReadAt(rec, int64(binary.LittleEndian.Uint64(loc[8:]))); err != nil {
+		return dir, fmt.Errorf(tr("读取zip64目录结束记录失败: %w"), err)
+	}
+	if binary.LittleEndian.Uint32(rec) != zip64EndSig {
+		return dir, errors.New(tr("zip64目录结束记录损坏"))
+	}
+	dir.records = int64(binary.LittleEndian.Uint64(rec[32:]))
+	dir.size = int64(binary.LittleEndian.Uint64(rec[40:]))
+	dir.offset = int64(binary.LittleEndian.Uint64(rec[48:]))
+	return dir, nil
+}
+
+// readCentralRecords 读取中央目录并拆分为各条目的记录
+func readCentralRecords(r io.ReaderAt, dir zipDirectory) ([]zipRecord, error) {
+	cd := make([]byte, dir.size)
+	if _, err := r.ReadAt(cd, dir.offset); err != nil {
+		return nil, fmt.Errorf(tr("读取中央目录失败: %w"), err)
+	}
+
+	records := make([]zipRecord, 0, dir.records)
+	for len(cd) > 0 {
+		if len(cd) < zipCentralLen || binary.LittleEndian.Uint32(cd) != zipCentralSig {
+			return nil, errors.New(tr("中央目录损坏"))
+		}
+		nameLen := int(binary.LittleEndian.Uint16(cd[28:]))
+		n := zipCentralLen + nameLen + int(binary.LittleEndian.Uint16(cd[30:])) + int(binary.LittleEndian.Uint16(cd[32:]))
+		if n > len(cd) {
+			return nil, errors.New(tr("中央目录损坏"))
+		}
+		records = append(records, zipRecord{name: string(cd[zipCentralLen : zipCentralLen+nameLen]), raw: cd[:n]})
+		cd = cd[n:]
+	}
+	if int64(len(records)) != dir.records {
+		return nil, fmt.Errorf(tr("中央目录条目数不一致: %d != %d"), len(records), dir.records)
+	}
+	return records, nil
+}
+
+// writeZipEnd 写入目录结束记录，条目数或偏移超出范围时先写入 zip64 记录
+func writeZipEnd(w *bytes.Buffer, dir zipDirectory) {
+	le := binary.LittleEndian
+	records, size, offset := dir.records, dir.size, dir.offset
+	if records >= 0xffff || size >= 0xffffffff || offset >= 0xffffffff {
+		end64 := offset + size // 紧接在中央目录之后
+		w.Write(le.AppendUint32(nil, zip64EndSig))
+		w.Write(le.AppendUint64(nil, zip64EndLen-12))
+		w.Write(le.AppendUint16(nil, 45)) // version made by
+		w.Write(le.AppendUint16(nil, 45)) // version needed
+		w.Write(make([]byte, 8))          // 磁盘编号
+		w.Write(le.AppendUint64(nil, uint64(records)))
+		w.Write(le.AppendUint64(nil, uint64(records)))
+		w.Write(le.AppendUint64(nil, uint64(size)))
+		w.Write(le.AppendUint64(nil, uint64(offset)))
+
+		w.Write(le.AppendUint32(nil, zip64LocatorSig))
+		w.Write(make([]byte, 4))
+		w.Write(le.AppendUint64(nil, uint64(end64)))
+		w.Write(le.AppendUint32(nil, 1))
+
+		records, size, offset = min(records, 0xffff), min(size, 0xffffffff), min(offset, 0xffffffff)
+	}
+
+	w.Write(le.AppendUint32(nil, zipEndSig))
+	w.Write(make([]byte, 4)) // 磁盘编号
+	w.Write(le.AppendUint16(nil, uint16(records)))
+	w.Write(le.AppendUint16(nil, uint16(records)))
+	w.Write(le.AppendUint32(nil, uint32(size)))
+	w.Write(le.AppendUint32(nil, uint32(offset)))
+	w.Write(le.AppendUint16(nil, uint16(len(dir.comment))))
+	w.Write(dir.comment)
+}
+
+// offsetReaderAt 把从 base 开始的绝对偏移映射到缓冲区
+type offsetReaderAt struct {
+	r    io.ReaderAt
+	base int64
+}
+
+func (o offsetReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	return o.r.ReadAt(p, off-o.base)
+}
+
+// replaceZipEntry 原地替换zip备份包中的条目：在文件末尾追加新条目和新的中央目录，
+// 其他条目的数据不动，被替换条目的旧数据留在文件中。写入失败时截断回原大小
+func replaceZipEntry(path string, hdr *entryHeader, data []byte) (err error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return fmt.Errorf(tr("打开备份文件失败: %w"), err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	size := info.Size()
+
+	dir, err := readZipDirectory(f, 0, size)
+	if err != nil {
+		return err
+	}
+	if dir.offset+dir.size > size {
+		return errors.New(tr("中央目录超出文件范围"))
+	}
+	records, err := readCentralRecords(f, dir)
+	if err != nil {
+		return err
+	}
+	idx := slices.IndexFunc(records, func(r zipRecord) bool { return r.name == hdr.Name })
+	if idx < 0 {
+		return fmt.Errorf(tr("备份文件中未找到 %s"), hdr.Name)
+	}
+
+	// 新条目以文件末尾为起始偏移写入缓冲区，再从中取出本地记录和中央目录记录
+	var buf bytes.Buffer
+	w, err := newArchiveWriter(&buf, formatZip, 0, nil)
+	if err != nil {
+		return err
+	}
+	w.(*zipArchiveWriter).zw.SetOffset(size)
+	ew, err := w.Create(hdr)
+	if err != nil {
+		return fmt.Errorf(tr("创建备份条目失败: %w"), err)
+	}
+	if _, err := ew.Write(data); err != nil {
+		return fmt.Errorf(tr("写入备份条目失败: %w"), err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf(tr("写入备份条目失败: %w"), err)
+	}
+	newEntry := offsetReaderAt{bytes.NewReader(buf.Bytes()), size}
+	newDir, err := readZipDirectory(newEntry, size, size+int64(buf.Len()))
+	if err != nil {
+		return err
+	}
+	newRecords, err := readCentralRecords(newEntry, newDir)
+	if err != nil {
+		return err
+	}
+	records[idx] = newRecords[0]
+
+	// 新条目之后写入保持原顺序的中央目录
+	var tail bytes.Buffer
+	tail.Write(buf.Bytes()[:newDir.offset-size])
+	cd := zipDirectory{offset: newDir.offset, records: int64(len(records)), comment: dir.comment}
+	for _, r := range records {
+		tail.Write(r.raw)
+		cd.size += int64(len(r.raw))
+	}
+	writeZipEnd(&tail, cd)
+
+	defer func() {
+		if err != nil {
+			f.Truncate(size)
+		}
+	}()
+	if _, err := f.WriteAt(tail.Bytes(), size); err != nil {
+		return fmt.Errorf(tr("写入备份文件失败: %w"), err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf(tr("写入备份文件失败: %w"), err)
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func Test_replaceZipEntry(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries int
+		comment string
+	}{
+		{name: "small", entries: 3, comment: "backtrack"},
+		{name: "zip64 record count", entries: 0x10000},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "backup.zip")
+			var buf bytes.Buffer
+			zw := zip.NewWriter(&buf)
+			for i := range tt.entries {
+				w, _ := zw.Create(fmt.Sprintf("data/%d", i))
+				fmt.Fprintf(w, "content %d", i)
+			}
+			w, _ := zw.Create(backupConfigName)
+			w.Write([]byte("backup_paths: [/old]\n"))
+			zw.SetComment(tt.comment)
+			if err := zw.Close(); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			dir, err := readZipDirectory(bytes.NewReader(buf.Bytes()), 0, int64(buf.Len()))
+			if err != nil {
+				t.Fatalf("readZipDirectory() error = %v", err)
+			}
+
+			// 连续替换两次，第二次读取第一次追加的中央目录
+			for _, content := range []string{"backup_paths: [/new]\n", "backup_paths: [/newer]\n"} {
+				if err := replaceZipEntry(path, &entryHeader{Name: backupConfigName, Method: zip.Deflate}, []byte(content)); err != nil {
+					t.Fatalf("replaceZipEntry() error = %v", err)
+				}
+
+				data, _ := os.ReadFile(path)
+				if !bytes.HasPrefix(data, buf.Bytes()[:dir.offset]) {
+					t.Errorf("原有条目数据被修改")
+				}
+				r, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+				if err != nil {
+					t.Fatalf("zip.NewReader() error = %v", err)
+				}
+				if len(r.File) != tt.entries+1 || r.Comment != tt.comment {
+					t.Fatalf("entries = %d comment = %q, want %d %q", len(r.File), r.Comment, tt.entries+1, tt.comment)
+				}
+				last := r.File[tt.entries]
+				if last.Name != backupConfigName {
+					t.Errorf("条目顺序改变: 最后一个条目为 %s", last.Name)
+				}
+				if got := readZipFile(t, last); got != content {
+					t.Errorf("%s = %q, want %q", backupConfigName, got, content)
+				}
+				if got := readZipFile(t, r.File[tt.entries-1]); got != fmt.Sprintf("content %d", tt.entries-1) {
+					t.Errorf("其他条目内容改变: %q", got)
+				}
+			}
+		})
+	}
+}
+
+func Test_replaceZipEntry_missing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backup.zip")
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	zw.Create("data/a")
+	zw.Close()
+	os.WriteFile(path, buf.Bytes(), 0644)
+
+	if err := replaceZipEntry(path, &entryHeader{Name: backupConfigName}, []byte("x")); err == nil {
+		t.Fatal("replaceZipEntry() 应返回未找到条目的错误")
+	}
+	if data, _ := os.ReadFile(path); !bytes.Equal(data, buf.Bytes()) {
+		t.Errorf("失败后备份文件被修改")
+	}
+}
+
+func readZipFile(t *testing.T, f *zip.File) string {
+	t.Helper()
+	rc, err := f.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}